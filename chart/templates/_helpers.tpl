@@ -66,6 +66,13 @@ Get the image tag
 {{- .Values.image.tag | default .Chart.AppVersion }}
 {{- end }}
 
+{{/*
+Whether the repository is stored on the local volume rather than a remote storage
+*/}}
+{{- define "firmirror.localStorage" -}}
+{{- if not (or .Values.storage.s3.enabled .Values.storage.gcs.enabled .Values.storage.azure.enabled .Values.storage.sftp.enabled .Values.storage.webdav.enabled) }}true{{- end }}
+{{- end }}
+
 {{/*
 Build the firmirror command arguments
 */}}
@@ -85,6 +92,66 @@ Build the firmirror command arguments
 {{- if .Values.storage.s3.endpoint }}
 - {{ printf "--s3.endpoint=%s" .Values.storage.s3.endpoint | quote }}
 {{- end }}
+{{- if .Values.storage.s3.storageClass }}
+- {{ printf "--s3.storage-class=%s" .Values.storage.s3.storageClass | quote }}
+{{- end }}
+{{- if .Values.storage.s3.kmsKeyId }}
+- {{ printf "--s3.kms-key-id=%s" .Values.storage.s3.kmsKeyId | quote }}
+{{- end }}
+{{- if .Values.storage.s3.metadataCacheControl }}
+- {{ printf "--s3.metadata-cache-control=%s" .Values.storage.s3.metadataCacheControl | quote }}
+{{- end }}
+{{- else if .Values.storage.gcs.enabled }}
+- "--gcs.enable"
+{{- if .Values.storage.gcs.bucket }}
+- {{ printf "--gcs.bucket=%s" .Values.storage.gcs.bucket | quote }}
+{{- end }}
+{{- if .Values.storage.gcs.prefix }}
+- {{ printf "--gcs.prefix=%s" .Values.storage.gcs.prefix | quote }}
+{{- end }}
+{{- if .Values.storage.gcs.endpoint }}
+- {{ printf "--gcs.endpoint=%s" .Values.storage.gcs.endpoint | quote }}
+{{- end }}
+{{- else if .Values.storage.azure.enabled }}
+- "--azure.enable"
+{{- if .Values.storage.azure.account }}
+- {{ printf "--azure.account=%s" .Values.storage.azure.account | quote }}
+{{- end }}
+{{- if .Values.storage.azure.serviceUrl }}
+- {{ printf "--azure.service-url=%s" .Values.storage.azure.serviceUrl | quote }}
+{{- end }}
+{{- if .Values.storage.azure.container }}
+- {{ printf "--azure.container=%s" .Values.storage.azure.container | quote }}
+{{- end }}
+{{- if .Values.storage.azure.prefix }}
+- {{ printf "--azure.prefix=%s" .Values.storage.azure.prefix | quote }}
+{{- end }}
+{{- if .Values.storage.azure.clientId }}
+- {{ printf "--azure.client-id=%s" .Values.storage.azure.clientId | quote }}
+{{- end }}
+{{- else if .Values.storage.sftp.enabled }}
+- "--sftp.enable"
+{{- if .Values.storage.sftp.address }}
+- {{ printf "--sftp.address=%s" .Values.storage.sftp.address | quote }}
+{{- end }}
+{{- if .Values.storage.sftp.user }}
+- {{ printf "--sftp.user=%s" .Values.storage.sftp.user | quote }}
+{{- end }}
+{{- if .Values.storage.sftp.path }}
+- {{ printf "--sftp.path=%s" .Values.storage.sftp.path | quote }}
+{{- end }}
+- {{ printf "--sftp.known-hosts=/var/secrets/sftp/%s" .Values.storage.sftp.knownHostsKey | quote }}
+{{- if .Values.storage.sftp.privateKeyKey }}
+- {{ printf "--sftp.private-key=/var/secrets/sftp/%s" .Values.storage.sftp.privateKeyKey | quote }}
+{{- end }}
+{{- else if .Values.storage.webdav.enabled }}
+- "--webdav.enable"
+{{- if .Values.storage.webdav.url }}
+- {{ printf "--webdav.url=%s" .Values.storage.webdav.url | quote }}
+{{- end }}
+{{- if .Values.storage.webdav.user }}
+- {{ printf "--webdav.user=%s" .Values.storage.webdav.user | quote }}
+{{- end }}
 {{- else }}
 - {{ printf "--output-dir=%s" .Values.storage.outputDir | quote }}
 {{- end }}
@@ -92,16 +159,60 @@ Build the firmirror command arguments
 - --sign.certificate=/secrets/signing.cert
 - --sign.private-key=/secrets/signing.key
 {{- end }}
+{{- if .Values.advisories }}
+- --advisories=/config/advisories.json
+{{- end }}
+{{- if .Values.replication.replicas }}
+- --replicas=/config/replicas.json
+{{- end }}
+{{- if .Values.verifyWrites }}
+- "--verify-writes"
+{{- end }}
+{{- if .Values.cabLayout }}
+- {{ printf "--cab-layout=%s" .Values.cabLayout | quote }}
+{{- end }}
 {{- if .Values.vendors.dell.enabled }}
 - "--dell.enable"
 {{- if .Values.vendors.dell.machinesId }}
 - {{ printf "--dell.machines-id=%s" .Values.vendors.dell.machinesId | quote }}
 {{- end }}
+{{- if .Values.vendors.dell.bundles }}
+- {{ printf "--dell.bundles=%s" .Values.vendors.dell.bundles | quote }}
+{{- end }}
+{{- if .Values.vendors.dell.latestBundle }}
+- "--dell.latest-bundle"
+{{- end }}
+{{- if .Values.vendors.dell.versionFormats }}
+- {{ printf "--dell.version-formats=%s" .Values.vendors.dell.versionFormats | quote }}
+{{- end }}
 {{- end }}
 {{- if .Values.vendors.hpe.enabled }}
 - "--hpe.enable"
-{{- if .Values.vendors.hpe.gens }}
+{{- if eq .Values.vendors.hpe.gens "none" }}
+- "--hpe.gens="
+{{- else if .Values.vendors.hpe.gens }}
 - {{ printf "--hpe.gens=%s" .Values.vendors.hpe.gens | quote }}
 {{- end }}
+{{- if .Values.vendors.hpe.repos }}
+- {{ printf "--hpe.repos=%s" .Values.vendors.hpe.repos | quote }}
+{{- end }}
+{{- if .Values.vendors.hpe.baseUrl }}
+- {{ printf "--hpe.base-url=%s" .Values.vendors.hpe.baseUrl | quote }}
+{{- end }}
+{{- if .Values.vendors.hpe.release }}
+- {{ printf "--hpe.release=%s" .Values.vendors.hpe.release | quote }}
+{{- end }}
+{{- if .Values.vendors.hpe.products }}
+- {{ printf "--hpe.products=%s" .Values.vendors.hpe.products | quote }}
+{{- end }}
+{{- if .Values.vendors.hpe.deviceClasses }}
+- {{ printf "--hpe.device-classes=%s" .Values.vendors.hpe.deviceClasses | quote }}
+{{- end }}
+{{- if .Values.vendors.hpe.urgencies }}
+- {{ printf "--hpe.urgencies=%s" .Values.vendors.hpe.urgencies | quote }}
+{{- end }}
+{{- if .Values.vendors.hpe.versionFormats }}
+- {{ printf "--hpe.version-formats=%s" .Values.vendors.hpe.versionFormats | quote }}
+{{- end }}
 {{- end }}
 {{- end }}
@@ -2,27 +2,43 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"slices"
 	"syscall"
+	"time"
 
 	"github.com/alecthomas/kong"
 
 	"github.com/criteo/firmirror/pkg/firmirror"
+	"github.com/criteo/firmirror/pkg/lvfs"
 	"github.com/criteo/firmirror/pkg/vendors/dell"
 	"github.com/criteo/firmirror/pkg/vendors/hpe"
 )
 
 type DellFlags struct {
-	Enable     bool     `help:"Enable Dell firmware fetching." default:"false"`
-	MachinesID []string `help:"List of machine IDs to fetch firmware for. They are composed of 4 characters representing the machine type, followed by 4 digits representing the hexadecimal machine ID. For example: \"0C60\" for \"3168\" corresponding to the C6615 series of servers. You can also specify \"*\" to fetch all the firmware, but this may take a very long time."`
+	Enable         bool              `help:"Enable Dell firmware fetching." default:"false"`
+	MachinesID     []string          `help:"List of machine IDs to fetch firmware for. They are composed of 4 characters representing the machine type, followed by 4 digits representing the hexadecimal machine ID. For example: \"0C60\" for \"3168\" corresponding to the C6615 series of servers. You can also specify \"*\" to fetch all the firmware, but this may take a very long time."`
+	Bundles        []string          `help:"List of Dell software bundle IDs to restrict firmware to."`
+	LatestBundle   bool              `help:"Only fetch the firmware of the latest software bundle of each selected machine." default:"false"`
+	VersionFormats map[string]string `help:"Version formats overriding the detected ones, keyed on LU category. For example: \"BIOS=triplet,SAS Drive=plain\"." mapsep:","`
 }
 
 type HPEFlags struct {
-	Enable bool     `help:"Enable HPE firmware fetching." default:"false"`
-	Gens   []string `help:"List of generations to fetch firmware for." default:"gen10,gen11,gen12" enum:"gen10,gen11,gen12"`
+	Enable         bool              `help:"Enable HPE firmware fetching." default:"false"`
+	Gens           []string          `help:"List of generations to fetch firmware for from the fwpp repositories. Use --hpe.gens= to disable them." default:"gen10,gen11,gen12" enum:"gen10,gen11,gen12"`
+	Repos          []string          `help:"List of additional SDR repositories to fetch firmware for. For example: \"spp-gen11,ilo6\"."`
+	BaseURL        string            `help:"Base URL of the HPE Software Delivery Repository." default:"${hpe_base_url}"`
+	Release        string            `help:"Release directory of the repositories to fetch firmware from, instead of the current one. For example: \"2024.09.00.00\"."`
+	Products       []string          `help:"List of server models to fetch firmware for, matched against the supported products of each package. For example: \"DL360 Gen10 Plus,DL380 Gen11\"."`
+	DeviceClasses  []string          `help:"List of device class GUIDs to fetch firmware for."`
+	Urgencies      map[string]string `help:"Release urgencies overriding the package criticality, keyed on sw_key, category key or category name. For example: \"ilo6=critical,Firmware - Network=low\"." mapsep:","`
+	VersionFormats map[string]string `help:"Version formats overriding the detected ones, keyed on sw_key, category key or category name. For example: \"Firmware - System ROM=plain\"." mapsep:","`
 }
 
 type S3 struct {
@@ -31,6 +47,45 @@ type S3 struct {
 	Prefix   string `help:"Optional prefix for all S3 keys" default:""`
 	Region   string `help:"AWS region" default:"us-east-1"`
 	Endpoint string `help:"Custom S3 endpoint URL (for S3-compatible services like MinIO)" default:""`
+
+	StorageClass         string `help:"Storage class of the firmware CABs (e.g., STANDARD_IA), defaults to the bucket's" default:""`
+	KMSKeyID             string `name:"kms-key-id" help:"KMS key ID or alias to encrypt the objects with (SSE-KMS)" default:""`
+	MetadataCacheControl string `help:"Cache-Control of the metadata files, which change on every refresh" default:"no-cache"`
+}
+
+type GCS struct {
+	Enable   bool   `help:"Use Google Cloud Storage backend instead of local filesystem. Uses the Application Default Credentials, e.g. GOOGLE_APPLICATION_CREDENTIALS" default:"false"`
+	Bucket   string `help:"GCS bucket name for storing firmware files"`
+	Prefix   string `help:"Optional prefix for all GCS object names" default:""`
+	Endpoint string `help:"Custom GCS endpoint URL, used without authentication (for emulators like fake-gcs-server)" default:""`
+}
+
+type Azure struct {
+	Enable     bool   `help:"Use Azure Blob Storage backend instead of local filesystem. Authenticates with the account key, else the SAS token, else the managed identity or default Azure credentials" default:"false"`
+	Account    string `help:"Azure storage account name"`
+	ServiceURL string `help:"Custom blob service URL (for emulators like Azurite), defaults to https://<account>.blob.core.windows.net" default:""`
+	Container  string `help:"Azure container name for storing firmware files"`
+	Prefix     string `help:"Optional prefix for all Azure blob names" default:""`
+	AccountKey string `help:"Azure storage account key" env:"AZURE_STORAGE_KEY" default:""`
+	SASToken   string `help:"Azure SAS token granting access to the container" env:"AZURE_STORAGE_SAS_TOKEN" default:""`
+	ClientID   string `help:"Client ID of the user-assigned managed identity to authenticate with" default:""`
+}
+
+type SFTP struct {
+	Enable     bool   `help:"Use SFTP storage backend instead of local filesystem. Files are uploaded to a temporary name then renamed" default:"false"`
+	Address    string `help:"SFTP server address as host:port, the port defaults to 22"`
+	User       string `help:"SFTP user name"`
+	Password   string `help:"SFTP password" env:"SFTP_PASSWORD" default:""`
+	PrivateKey string `help:"Path to the SSH private key to authenticate with" type:"existingfile"`
+	KnownHosts string `help:"Path to the known_hosts file used to verify the server" type:"existingfile"`
+	Path       string `help:"Directory of the repository on the server" default:""`
+}
+
+type WebDAV struct {
+	Enable   bool   `help:"Use WebDAV storage backend instead of local filesystem. Files are uploaded to a temporary name then moved" default:"false"`
+	URL      string `help:"URL of the WebDAV collection holding the repository"`
+	User     string `help:"WebDAV basic authentication user name" default:""`
+	Password string `help:"WebDAV basic authentication password" env:"WEBDAV_PASSWORD" default:""`
 }
 
 type Signature struct {
@@ -39,22 +94,343 @@ type Signature struct {
 }
 
 var args struct {
-	DellFlags `embed:"" prefix:"dell." group:"Dell" help:"Dell firmware fetching."`
-	HPEFlags  `embed:"" prefix:"hpe." group:"HPE" help:"HPE firmware fetching."`
-	S3        `embed:"" prefix:"s3." group:"S3 Storage" help:"S3 storage backend configuration."`
-	Signature `embed:"" prefix:"sign." group:"Signature" help:"Metadata signing configuration."`
-	OutputDir string `help:"Output directory for the LVFS-compatible firmware repository (ignored when using S3)" type:"path"`
-	Refresh   struct {
+	DellFlags    `embed:"" prefix:"dell." group:"Dell" help:"Dell firmware fetching."`
+	HPEFlags     `embed:"" prefix:"hpe." group:"HPE" help:"HPE firmware fetching."`
+	S3           `embed:"" prefix:"s3." group:"S3 Storage" help:"S3 storage backend configuration."`
+	GCS          `embed:"" prefix:"gcs." group:"GCS Storage" help:"Google Cloud Storage backend configuration."`
+	Azure        `embed:"" prefix:"azure." group:"Azure Storage" help:"Azure Blob Storage backend configuration."`
+	SFTP         `embed:"" prefix:"sftp." group:"SFTP Storage" help:"SFTP storage backend configuration."`
+	WebDAV       `embed:"" prefix:"webdav." group:"WebDAV Storage" help:"WebDAV storage backend configuration."`
+	Signature    `embed:"" prefix:"sign." group:"Signature" help:"Metadata signing configuration."`
+	OutputDir    string `help:"Output directory for the LVFS-compatible firmware repository (ignored when using a remote storage backend)" type:"path"`
+	Advisories   string `help:"Path to a JSON file adding security advisories to the releases" type:"existingfile"`
+	Replicas     string `help:"Path to a JSON file of storage backends to replicate the repository to, on top of the selected one" type:"existingfile"`
+	VerifyWrites bool   `help:"Check the sha256 of the files once written to storage, using the checksums of the backend or by reading them back"`
+	CABLayout    string `help:"Storage key of the CABs, from the {vendor}, {component}, {version}, {sha256} and {filename} placeholders, e.g. {vendor}/{sha256}.cab" default:"{filename}.cab"`
+	Refresh      struct {
 	} `cmd:"" help:"Refresh all the firmware from the repositories. Note: this will not replace the already-existing firmware, even if the vendor pushed an updated version. You will need to delete the firmware manually."`
+	Validate struct {
+	} `cmd:"" help:"Validate the repository in storage: check the metadata of every component and that its firmware is stored. Exits with an error if the repository is invalid."`
+	Verify struct {
+	} `cmd:"" help:"Verify the firmware in storage against the checksums of the metadata, reporting missing and corrupt files. Exits with an error if any is found."`
+	MigrateLayout struct {
+		DeletePrevious bool `help:"Delete the firmware at its previous keys once the updated metadata is published. The clients holding the previous metadata cannot download it anymore."`
+	} `cmd:"" help:"Copy the firmware in storage to the keys of --cab-layout and publish the updated metadata. The firmware is kept at its previous keys for the clients holding the previous metadata, unless --delete-previous is set."`
+	Serve struct {
+		Listen  string `help:"Address to listen on" default:":8080"`
+		TLSCert string `name:"tls-cert" help:"Path to the TLS certificate to serve HTTPS with" type:"existingfile"`
+		TLSKey  string `name:"tls-key" help:"Path to the private key of the TLS certificate" type:"existingfile"`
+		BaseURL string `help:"Public URL of the repository in the generated fwupd remote configuration, e.g. https://firmware.example.com" required:""`
+		Title   string `help:"Title of the generated fwupd remote configuration" default:"Firmirror"`
+	} `cmd:"" help:"Serve the repository in storage to fwupd clients over HTTP, with its fwupd remote configuration at ${remote_conf_path}."`
 }
 
 func main() {
-	cli := kong.Parse(&args)
+	cli := kong.Parse(&args, kong.Vars{
+		"hpe_base_url":     hpe.DefaultBaseURL,
+		"remote_conf_path": firmirror.RemoteConfPath,
+	})
 	switch cli.Command() {
 	case "refresh":
+		refresh()
+	case "validate":
+		if !validate() {
+			os.Exit(1)
+		}
+	case "verify":
+		if !verify() {
+			os.Exit(1)
+		}
+	case "migrate-layout":
+		if !migrateLayout() {
+			os.Exit(1)
+		}
+	case "serve":
+		if !serve() {
+			os.Exit(1)
+		}
 	default:
 		panic(cli.Command())
 	}
+}
+
+// newStorage creates the storage backend selected by the flags, replicated to the replicas if any
+func newStorage() (firmirror.Storage, error) {
+	storage, err := newBackend()
+	if err != nil || args.Replicas == "" {
+		return storage, err
+	}
+
+	replicas, err := firmirror.LoadReplicas(context.Background(), args.Replicas)
+	if err != nil {
+		return nil, err
+	}
+	multi, err := firmirror.NewMultiStorage(context.Background(), storage, replicas...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replicated storage: %w", err)
+	}
+	for _, replica := range replicas {
+		slog.Info("Replicating repository", "replica", replica.Name, "policy", replica.Policy)
+	}
+	return multi, nil
+}
+
+// newBackend creates the storage backend selected by the flags
+func newBackend() (firmirror.Storage, error) {
+	enabled := 0
+	for _, enable := range []bool{args.S3.Enable, args.GCS.Enable, args.Azure.Enable, args.SFTP.Enable, args.WebDAV.Enable} {
+		if enable {
+			enabled++
+		}
+	}
+	if enabled > 1 {
+		return nil, errors.New("only one storage backend can be enabled")
+	}
+
+	if args.SFTP.Enable {
+		storage, err := firmirror.NewSFTPStorage(context.Background(), firmirror.SFTPConfig{
+			Address:    args.SFTP.Address,
+			User:       args.SFTP.User,
+			Password:   args.SFTP.Password,
+			PrivateKey: args.SFTP.PrivateKey,
+			KnownHosts: args.SFTP.KnownHosts,
+			Path:       args.SFTP.Path,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SFTP storage backend: %w", err)
+		}
+		slog.Info("Using SFTP storage backend", "address", args.SFTP.Address, "path", args.SFTP.Path)
+		return storage, nil
+	}
+
+	if args.WebDAV.Enable {
+		storage, err := firmirror.NewWebDAVStorage(context.Background(), firmirror.WebDAVConfig{
+			URL:      args.WebDAV.URL,
+			User:     args.WebDAV.User,
+			Password: args.WebDAV.Password,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create WebDAV storage backend: %w", err)
+		}
+		slog.Info("Using WebDAV storage backend", "url", args.WebDAV.URL)
+		return storage, nil
+	}
+
+	if args.Azure.Enable {
+		storage, err := firmirror.NewAzureBlobStorage(context.Background(), firmirror.AzureBlobConfig{
+			Account:    args.Azure.Account,
+			ServiceURL: args.Azure.ServiceURL,
+			Container:  args.Azure.Container,
+			Prefix:     args.Azure.Prefix,
+			AccountKey: args.Azure.AccountKey,
+			SASToken:   args.Azure.SASToken,
+			ClientID:   args.Azure.ClientID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure storage backend: %w", err)
+		}
+		slog.Info("Using Azure storage backend", "container", args.Azure.Container, "prefix", args.Azure.Prefix)
+		return storage, nil
+	}
+
+	if args.GCS.Enable {
+		storage, err := firmirror.NewGCSStorage(context.Background(), args.GCS.Bucket, args.GCS.Prefix, args.GCS.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCS storage backend: %w", err)
+		}
+		slog.Info("Using GCS storage backend", "bucket", args.GCS.Bucket, "prefix", args.GCS.Prefix)
+		return storage, nil
+	}
+
+	if args.S3.Enable {
+		storage, err := firmirror.NewS3Storage(context.Background(), firmirror.S3Config{
+			Bucket:               args.S3.Bucket,
+			Prefix:               args.S3.Prefix,
+			Region:               args.S3.Region,
+			Endpoint:             args.S3.Endpoint,
+			StorageClass:         args.S3.StorageClass,
+			KMSKeyID:             args.S3.KMSKeyID,
+			MetadataCacheControl: args.S3.MetadataCacheControl,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create S3 storage backend: %w", err)
+		}
+		slog.Info("Using S3 storage backend", "bucket", args.S3.Bucket, "prefix", args.S3.Prefix)
+		return storage, nil
+	}
+
+	if args.OutputDir == "" {
+		return nil, errors.New("output directory is required when using local storage")
+	}
+
+	storage, err := firmirror.NewLocalStorage(args.OutputDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create local storage backend: %w", err)
+	}
+	slog.Info("Using local filesystem storage", "path", args.OutputDir)
+	return storage, nil
+}
+
+// validate checks the repository in storage and reports whether it is valid
+func validate() bool {
+	storage, err := newStorage()
+	if err != nil {
+		slog.Error("Failed to create storage backend", "error", err)
+		return false
+	}
+
+	components, invalid, err := firmirror.ValidateRepository(context.Background(), storage)
+	if err != nil {
+		slog.Error("Failed to validate repository", "error", err)
+		return false
+	}
+
+	for _, rejected := range invalid {
+		slog.Error("Invalid component", "id", rejected.Component.ID, "error", rejected.Err)
+	}
+	slog.Info("Repository validated", "components", len(components.Component), "invalid", len(invalid))
+	return len(invalid) == 0
+}
+
+// verify checks the firmware in storage against the metadata and reports whether it is intact
+func verify() bool {
+	storage, err := newStorage()
+	if err != nil {
+		slog.Error("Failed to create storage backend", "error", err)
+		return false
+	}
+
+	report, err := firmirror.VerifyRepository(context.Background(), storage)
+	if err != nil {
+		slog.Error("Failed to verify repository", "error", err)
+		return false
+	}
+
+	for _, key := range report.Missing {
+		slog.Error("Missing firmware", "key", key)
+	}
+	for _, corrupt := range report.Corrupt {
+		slog.Error("Corrupt firmware", "key", corrupt.Key, "error", corrupt.Err)
+	}
+	for _, key := range report.Unverified {
+		slog.Warn("No checksum to verify firmware against", "key", key)
+	}
+	slog.Info("Repository verified", "verified", report.Verified, "unverified", len(report.Unverified),
+		"missing", len(report.Missing), "corrupt", len(report.Corrupt))
+	return report.OK()
+}
+
+// migrateLayout moves the firmware in storage to the layout of the flags and reports whether it succeeded
+func migrateLayout() bool {
+	if _, err := exec.LookPath("jcat-tool"); err != nil {
+		slog.Error("jcat-tool is required but not found in PATH, aborting")
+		return false
+	}
+
+	storage, err := newStorage()
+	if err != nil {
+		slog.Error("Failed to create storage backend", "error", err)
+		return false
+	}
+
+	fm := firmirror.NewFirmirrorSyncer(newConfig(), storage)
+	moved, err := fm.MigrateLayout(context.Background(), args.MigrateLayout.DeletePrevious)
+	if err != nil {
+		slog.Error("Failed to migrate repository", "layout", args.CABLayout, "error", err)
+		return false
+	}
+	slog.Info("Repository migrated", "layout", args.CABLayout, "moved", moved, "previous_deleted", args.MigrateLayout.DeletePrevious)
+	if moved > 0 && !args.MigrateLayout.DeletePrevious {
+		slog.Info("The firmware is kept at its previous keys, use --delete-previous to delete it", "count", moved)
+	}
+	return true
+}
+
+// serve serves the repository in storage until interrupted and reports whether it stopped cleanly
+func serve() bool {
+	if (args.Serve.TLSCert == "") != (args.Serve.TLSKey == "") {
+		slog.Error("Both --tls-cert and --tls-key are required to serve HTTPS")
+		return false
+	}
+
+	// Reads are served by the selected backend, the replicas are only written to
+	storage, err := newBackend()
+	if err != nil {
+		slog.Error("Failed to create storage backend", "error", err)
+		return false
+	}
+
+	server := &http.Server{
+		Addr: args.Serve.Listen,
+		Handler: firmirror.NewServer(storage, firmirror.ServerConfig{
+			BaseURL: args.Serve.BaseURL,
+			Title:   args.Serve.Title,
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("Serving repository", "address", args.Serve.Listen, "tls", args.Serve.TLSCert != "")
+		if args.Serve.TLSCert != "" {
+			errs <- server.ListenAndServeTLS(args.Serve.TLSCert, args.Serve.TLSKey)
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		slog.Error("Failed to serve repository", "error", err)
+		return false
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutdown signal received, waiting for current requests to complete...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to shut down server", "error", err)
+		return false
+	}
+	return true
+}
+
+// newConfig returns the syncer configuration from the flags
+func newConfig() firmirror.FirmirrorConfig {
+	return firmirror.FirmirrorConfig{
+		CacheDir:     ".firmirror_cache",
+		Certificate:  args.Signature.Certificate,
+		PrivateKey:   args.Signature.PrivateKey,
+		VerifyWrites: args.VerifyWrites,
+		Layout:       args.CABLayout,
+	}
+}
+
+func refresh() {
+	for key, urgency := range args.HPEFlags.Urgencies {
+		if !slices.Contains(lvfs.Urgencies, urgency) {
+			slog.Error("Invalid HPE urgency override", "key", key, "urgency", urgency, "valid", lvfs.Urgencies)
+			return
+		}
+	}
+
+	for _, overrides := range []map[string]string{args.DellFlags.VersionFormats, args.HPEFlags.VersionFormats} {
+		for key, format := range overrides {
+			if !slices.Contains(lvfs.VersionFormats, format) {
+				slog.Error("Invalid version format override", "key", key, "format", format, "valid", lvfs.VersionFormats)
+				return
+			}
+		}
+	}
+
+	if err := firmirror.ValidateLayout(args.CABLayout); err != nil {
+		slog.Error("Invalid CAB layout", "error", err)
+		return
+	}
 
 	// Check if bin tools are available
 	for _, bin := range []string{"fwupdtool", "jcat-tool"} {
@@ -64,10 +440,6 @@ func main() {
 		}
 	}
 
-	// Create storage backend
-	var storage firmirror.Storage
-	var err error
-
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 
 	// Monitor for shutdown signal
@@ -80,31 +452,22 @@ func main() {
 		slog.Warn("No certificate or private key provided, metadata will not be signed")
 	}
 
-	if args.S3.Enable {
-		storage, err = firmirror.NewS3Storage(context.Background(), args.S3.Bucket, args.S3.Prefix, args.S3.Region, args.S3.Endpoint)
-		if err != nil {
-			slog.Error("Failed to create S3 storage backend", "error", err)
-			return
-		}
-		slog.Info("Using S3 storage backend", "bucket", args.S3.Bucket, "prefix", args.S3.Prefix)
-	} else {
-		if args.OutputDir == "" {
-			slog.Error("Output directory is required when using local storage")
-			return
-		}
+	// Create storage backend
+	storage, err := newStorage()
+	if err != nil {
+		slog.Error("Failed to create storage backend", "error", err)
+		return
+	}
+
+	config := newConfig()
 
-		storage, err = firmirror.NewLocalStorage(args.OutputDir)
+	if args.Advisories != "" {
+		config.Advisories, err = firmirror.LoadAdvisories(args.Advisories)
 		if err != nil {
-			slog.Error("Failed to create local storage backend", "error", err)
+			slog.Error("Failed to load advisories", "error", err)
 			return
 		}
-		slog.Info("Using local filesystem storage", "path", args.OutputDir)
-	}
-
-	config := firmirror.FirmirrorConfig{
-		CacheDir:    ".firmirror_cache",
-		Certificate: args.Signature.Certificate,
-		PrivateKey:  args.Signature.PrivateKey,
+		slog.Info("Loaded security advisories", "count", len(config.Advisories))
 	}
 
 	if !args.HPEFlags.Enable && !args.DellFlags.Enable {
@@ -115,15 +478,30 @@ func main() {
 	fm := firmirror.NewFirmirrorSyncer(config, storage)
 
 	if args.HPEFlags.Enable {
+		// Map each repository to its vendor name, keeping the historical names of the fwpp ones
+		repos := map[string]string{}
+		for _, repo := range args.HPEFlags.Repos {
+			repos[repo] = "hpe-" + repo
+		}
 		for _, gen := range args.HPEFlags.Gens {
-			hpeRepo := "fwpp-" + gen
-			hpeVendor := hpe.NewHPEVendor(hpeRepo)
-			fm.RegisterVendor("hpe-"+gen, hpeVendor)
+			repos["fwpp-"+gen] = "hpe-" + gen
+		}
+
+		for hpeRepo, name := range repos {
+			hpeVendor := hpe.NewHPEVendor(args.HPEFlags.BaseURL, hpeRepo, args.HPEFlags.Release)
+			hpeVendor.Products = args.HPEFlags.Products
+			hpeVendor.DeviceClasses = args.HPEFlags.DeviceClasses
+			hpeVendor.Urgencies = args.HPEFlags.Urgencies
+			hpeVendor.VersionFormats = args.HPEFlags.VersionFormats
+			fm.RegisterVendor(name, hpeVendor)
 		}
 	}
 
 	if args.DellFlags.Enable {
 		dellVendor := dell.NewDellVendor(args.DellFlags.MachinesID)
+		dellVendor.BundleIDs = args.DellFlags.Bundles
+		dellVendor.LatestBundle = args.DellFlags.LatestBundle
+		dellVendor.VersionFormats = args.DellFlags.VersionFormats
 		fm.RegisterVendor("dell", dellVendor)
 	}
 
@@ -133,6 +511,16 @@ func main() {
 			slog.Error("Failed to save metadata", "error", err)
 		}
 
+		if multi, ok := storage.(*firmirror.MultiStorage); ok {
+			if err := multi.Retry(context.Background()); err != nil {
+				slog.Error("Failed to replicate repository", "error", err)
+			}
+			for _, status := range multi.Status() {
+				slog.Info("Replication status", "destination", status.Name, "policy", status.Policy,
+					"written", status.Written, "failed", status.Failed, "pending", len(status.Pending))
+			}
+		}
+
 		stop()
 	}()
 
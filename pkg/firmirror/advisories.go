@@ -0,0 +1,106 @@
+package firmirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/criteo/firmirror/pkg/lvfs"
+)
+
+// Advisory adds security issues to the releases of a component, on top of the ones
+// extracted from the vendor descriptions
+type Advisory struct {
+	// ComponentID and GUID select the components the advisory applies to. At least one is required.
+	ComponentID string `json:"component_id,omitempty"`
+	GUID        string `json:"guid,omitempty"`
+	// Version restricts the advisory to a single release. If empty, applies to all releases.
+	Version string `json:"version,omitempty"`
+	// Issues lists advisory identifiers such as "CVE-2024-1234" or "DSA-2024-001"
+	Issues []string `json:"issues"`
+	// Replace discards the issues extracted from the vendor descriptions
+	Replace bool `json:"replace,omitempty"`
+}
+
+// LoadAdvisories reads a JSON list of advisories from a local file
+func LoadAdvisories(path string) ([]Advisory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read advisories: %w", err)
+	}
+
+	var advisories []Advisory
+	if err := json.Unmarshal(data, &advisories); err != nil {
+		return nil, fmt.Errorf("failed to parse advisories: %w", err)
+	}
+
+	for i, advisory := range advisories {
+		if advisory.ComponentID == "" && advisory.GUID == "" {
+			return nil, fmt.Errorf("advisory %d: component_id or guid is required", i)
+		}
+		for _, id := range advisory.Issues {
+			if _, ok := lvfs.IssueType(id); !ok {
+				return nil, fmt.Errorf("advisory %d: unknown issue identifier %q", i, id)
+			}
+		}
+	}
+
+	return advisories, nil
+}
+
+// matches reports whether the advisory applies to the release of the component
+func (a Advisory) matches(component *lvfs.Component, release *lvfs.Release) bool {
+	if a.ComponentID != "" && a.ComponentID != component.ID {
+		return false
+	}
+	if a.Version != "" && a.Version != release.Version {
+		return false
+	}
+	if a.GUID == "" {
+		return true
+	}
+	for _, provide := range component.Provides {
+		if strings.EqualFold(provide.Text, a.GUID) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyAdvisories adds the issues of the matching advisories to the releases of the component,
+// and reports whether the issues of any release changed
+func applyAdvisories(advisories []Advisory, component *lvfs.Component) bool {
+	changed := false
+	for i := range component.Releases {
+		release := &component.Releases[i]
+		previous := slices.Clone(release.Issues)
+
+		// Replacements are applied first, so that they only discard extracted issues
+		for _, advisory := range advisories {
+			if advisory.Replace && advisory.matches(component, release) {
+				release.Issues = nil
+				break
+			}
+		}
+
+		for _, advisory := range advisories {
+			if !advisory.matches(component, release) {
+				continue
+			}
+			for _, id := range advisory.Issues {
+				issueType, _ := lvfs.IssueType(id)
+				release.Issues = lvfs.AddIssue(release.Issues, lvfs.Issue{
+					Type: issueType,
+					Text: strings.ToUpper(strings.TrimSpace(id)),
+				})
+			}
+		}
+
+		if !slices.Equal(previous, release.Issues) {
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,112 @@
+package firmirror
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/criteo/firmirror/pkg/lvfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeAdvisories(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "advisories.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644), "Should write advisories file")
+	return path
+}
+
+func TestLoadAdvisories(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		path := writeAdvisories(t, `[
+			{"guid": "dadc32f0-d6fc-575c-bad8-140b0e1d6850", "version": "1.0.0", "issues": ["CVE-2024-1234", "DSA-2024-001"]},
+			{"component_id": "com.hpe.ilo6", "issues": ["CVE-2024-5678"], "replace": true}
+		]`)
+
+		advisories, err := LoadAdvisories(path)
+		require.NoError(t, err, "LoadAdvisories should not return an error")
+		assert.Equal(t, []Advisory{
+			{GUID: "dadc32f0-d6fc-575c-bad8-140b0e1d6850", Version: "1.0.0", Issues: []string{"CVE-2024-1234", "DSA-2024-001"}},
+			{ComponentID: "com.hpe.ilo6", Issues: []string{"CVE-2024-5678"}, Replace: true},
+		}, advisories, "Advisories should be parsed")
+	})
+
+	t.Run("MissingSelector", func(t *testing.T) {
+		_, err := LoadAdvisories(writeAdvisories(t, `[{"issues": ["CVE-2024-1234"]}]`))
+		assert.ErrorContains(t, err, "component_id or guid is required", "Advisories without selector should be rejected")
+	})
+
+	t.Run("UnknownIdentifier", func(t *testing.T) {
+		for _, id := range []string{"GHSA-1234", "HPESBHF04456"} {
+			_, err := LoadAdvisories(writeAdvisories(t, `[{"component_id": "com.test", "issues": ["`+id+`"]}]`))
+			assert.ErrorContains(t, err, "unknown issue identifier", "Unknown identifiers should be rejected")
+		}
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		_, err := LoadAdvisories(writeAdvisories(t, `{`))
+		assert.Error(t, err, "Invalid JSON should be rejected")
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		_, err := LoadAdvisories(filepath.Join(t.TempDir(), "missing.json"))
+		assert.Error(t, err, "Missing file should be rejected")
+	})
+}
+
+func TestApplyAdvisories(t *testing.T) {
+	component := func() *lvfs.Component {
+		return &lvfs.Component{
+			ID:       "com.dell.network",
+			Provides: []lvfs.Firmware{{Type: "flashed", Text: "dadc32f0-d6fc-575c-bad8-140b0e1d6850"}},
+			Releases: []lvfs.Release{
+				{Version: "2.0.0", Issues: []lvfs.Issue{{Type: "cve", Text: "CVE-2023-0001"}}},
+				{Version: "1.0.0"},
+			},
+		}
+	}
+
+	t.Run("ByGUIDAndVersion", func(t *testing.T) {
+		comp := component()
+		changed := applyAdvisories([]Advisory{
+			{GUID: "DADC32F0-D6FC-575C-BAD8-140B0E1D6850", Version: "1.0.0", Issues: []string{"cve-2024-1234"}},
+		}, comp)
+
+		assert.True(t, changed, "Change should be reported")
+		assert.Equal(t, []lvfs.Issue{{Type: "cve", Text: "CVE-2023-0001"}}, comp.Releases[0].Issues, "Other releases should be unchanged")
+		assert.Equal(t, []lvfs.Issue{{Type: "cve", Text: "CVE-2024-1234"}}, comp.Releases[1].Issues, "Matching release should get the issue")
+	})
+
+	t.Run("AddsWithoutDuplicates", func(t *testing.T) {
+		comp := component()
+		applyAdvisories([]Advisory{
+			{ComponentID: "com.dell.network", Issues: []string{"CVE-2023-0001", "DSA-2024-001"}},
+		}, comp)
+
+		assert.Equal(t, []lvfs.Issue{
+			{Type: "cve", Text: "CVE-2023-0001"},
+			{Type: "dell", Text: "DSA-2024-001"},
+		}, comp.Releases[0].Issues, "Issues should be added once")
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		comp := component()
+		applyAdvisories([]Advisory{
+			{ComponentID: "com.dell.network", Version: "2.0.0", Issues: []string{"DSA-2024-001"}},
+			{ComponentID: "com.dell.network", Version: "2.0.0", Replace: true},
+		}, comp)
+
+		assert.Equal(t, []lvfs.Issue{{Type: "dell", Text: "DSA-2024-001"}}, comp.Releases[0].Issues, "Extracted issues should be replaced, advisories kept")
+	})
+
+	t.Run("NoMatch", func(t *testing.T) {
+		comp := component()
+		changed := applyAdvisories([]Advisory{
+			{ComponentID: "com.hpe.ilo6", Issues: []string{"CVE-2024-5678"}},
+			{GUID: "00000000-0000-0000-0000-000000000000", Issues: []string{"CVE-2024-1234"}},
+		}, comp)
+
+		assert.False(t, changed, "No change should be reported")
+		assert.Equal(t, component().Releases, comp.Releases, "Releases should be unchanged")
+	})
+}
@@ -1,27 +1,40 @@
 package firmirror
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"maps"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/criteo/firmirror/pkg/lvfs"
 	"github.com/klauspost/compress/zstd"
 )
 
+const (
+	metadataKey   = "metadata.xml.zst"
+	quarantineKey = "quarantine.xml" // Components dropped from the metadata, see SaveMetadata
+)
+
 type FirmirrorConfig struct {
-	CacheDir    string // Local cache directory for temporary work
-	Certificate string // Path to certificate file for signing metadata (.pem or .crt)
-	PrivateKey  string // Path to private key file for signing metadata (.pem or .key)
+	CacheDir     string     // Local cache directory for temporary work
+	Certificate  string     // Path to certificate file for signing metadata (.pem or .crt)
+	PrivateKey   string     // Path to private key file for signing metadata (.pem or .key)
+	Advisories   []Advisory // Security issues to add to the releases, see LoadAdvisories
+	VerifyWrites bool       // Check the digest of the files once written to storage, see WriteVerified
+	Layout       string     // Storage key of the CABs, see CABKey. DefaultLayout if empty
 }
 
 type FirmirrorSyncer struct {
@@ -30,6 +43,7 @@ type FirmirrorSyncer struct {
 	vendors          map[string]Vendor
 	existingMetadata *lvfs.Components // Loaded metadata from existing metadata.xml.gz
 	existingIndex    map[string]bool  // Index of firmware already in metadata (by filename)
+	existingReleases map[string]bool  // Index of releases already in metadata (by device GUID and version)
 	newComponents    []lvfs.Component // Components accumulated during this run
 }
 
@@ -40,10 +54,11 @@ func NewFirmirrorSyncer(config FirmirrorConfig, storage Storage) *FirmirrorSynce
 	}
 
 	return &FirmirrorSyncer{
-		Config:        config,
-		Storage:       storage,
-		vendors:       make(map[string]Vendor),
-		existingIndex: make(map[string]bool),
+		Config:           config,
+		Storage:          storage,
+		vendors:          make(map[string]Vendor),
+		existingIndex:    make(map[string]bool),
+		existingReleases: make(map[string]bool),
 	}
 }
 
@@ -92,8 +107,32 @@ func (f *FirmirrorSyncer) ProcessVendor(ctx context.Context, vendor Vendor, vend
 			continue
 		}
 
+		// Check if the catalog announces a release that is already in metadata
+		if release, ok := entry.(CatalogRelease); ok && f.hasRelease(release.GetGUIDs(), release.GetVersion()) {
+			entryLogger.Info("Firmware release already in metadata, skipping", "version", release.GetVersion())
+			skipped++
+			continue
+		}
+
 		entryLogger.Info("Processing firmware")
 
+		// Convert to AppStream before downloading, so that firmware which can't be
+		// converted doesn't cost any bandwidth
+		appstream, convertErr := entry.ToAppstream()
+		if convertErr == nil {
+			convertErr = appstream.Validate()
+		}
+		if errors.Is(convertErr, ErrSkipped) {
+			entryLogger.Info("Firmware excluded by filter, skipping", "reason", convertErr)
+			skipped++
+			continue
+		} else if errors.Is(convertErr, ErrNotRetrieved) {
+			entryLogger.Debug("Firmware must be retrieved before conversion")
+		} else if convertErr != nil {
+			entryLogger.Error("Failed to convert firmware", "error", convertErr)
+			continue
+		}
+
 		tmpDir := filepath.Join(f.Config.CacheDir, fwName+".wrk")
 		if err := os.MkdirAll(tmpDir, 0755); err != nil {
 			entryLogger.Error("Failed to create temp directory", "error", err)
@@ -106,24 +145,28 @@ func (f *FirmirrorSyncer) ProcessVendor(ctx context.Context, vendor Vendor, vend
 			continue
 		}
 
-		// Convert to AppStream
-		appstream, err := entry.ToAppstream()
-		if err != nil {
-			entryLogger.Error("Failed to convert firmware", "error", err)
-			continue
+		if convertErr != nil {
+			if appstream, err = entry.ToAppstream(); err == nil {
+				err = appstream.Validate()
+			}
+			if err != nil {
+				entryLogger.Error("Failed to convert firmware", "error", err)
+				os.RemoveAll(tmpDir)
+				continue
+			}
 		}
 
 		// Add source URL
 		sourceURL := entry.GetSourceURL()
 		if sourceURL != "" {
-			appstream.URL = lvfs.URL{
+			appstream.URLs = append(appstream.URLs, lvfs.URL{
 				Type: "homepage",
 				Text: sourceURL,
-			}
+			})
 		}
 
 		// Build package
-		if err = f.buildPackage(ctx, appstream, fwName, tmpDir); err != nil {
+		if err = f.buildPackage(ctx, appstream, vendorName, fwName, tmpDir); err != nil {
 			entryLogger.Error("Failed to build package", "error", err)
 			continue
 		}
@@ -140,7 +183,7 @@ func (f *FirmirrorSyncer) ProcessVendor(ctx context.Context, vendor Vendor, vend
 	return nil
 }
 
-func (f *FirmirrorSyncer) buildPackage(ctx context.Context, appstream *lvfs.Component, fwFile, tmpDir string) error {
+func (f *FirmirrorSyncer) buildPackage(ctx context.Context, appstream *lvfs.Component, vendorName, fwFile, tmpDir string) error {
 	fwPath := filepath.Join(tmpDir, fwFile)
 	logger := slog.With("firmware", fwFile)
 
@@ -199,20 +242,67 @@ func (f *FirmirrorSyncer) buildPackage(ctx context.Context, appstream *lvfs.Comp
 		return err
 	}
 
-	// Write CAB to storage backend
-	cabFile, err := os.Open(cabPathInCache)
+	// Record the checksums of the CAB itself, so that fwupd and VerifyRepository can check
+	// the download
+	cabSHA1, cabSHA256, err := calculateChecksums(cabPathInCache)
 	if err != nil {
-		return fmt.Errorf("failed to open CAB file: %w", err)
+		return err
+	}
+
+	// Only recorded in the metadata, the CAB is already built
+	appstream.SetCustomValue(vendorKey, vendorName)
+	fields := CABFields{Vendor: vendorName, Component: appstream.ID, SHA256: cabSHA256, Filename: fwFile}
+	if len(appstream.Releases) > 0 {
+		fields.Version = appstream.Releases[0].Version
+	}
+	cabKey := CABKey(f.layout(), fields)
+
+	for i := range appstream.Releases {
+		appstream.Releases[i].Location = cabKey
+		appstream.Releases[i].Checksums = append(appstream.Releases[i].Checksums,
+			lvfs.Checksum{Filename: path.Base(cabKey), Target: lvfs.ChecksumContainer, Type: "sha1", Value: cabSHA1},
+			lvfs.Checksum{Filename: path.Base(cabKey), Target: lvfs.ChecksumContainer, Type: "sha256", Value: cabSHA256},
+		)
 	}
-	defer cabFile.Close()
 
-	if err := f.Storage.Write(ctx, cabName, cabFile); err != nil {
+	// Write CAB to storage backend
+	if err := f.writeFile(ctx, cabKey, cabPathInCache); err != nil {
 		return fmt.Errorf("failed to write CAB to storage: %w", err)
 	}
 
 	return nil
 }
 
+// layout returns the configured layout of the CABs
+func (f *FirmirrorSyncer) layout() string {
+	if f.Config.Layout == "" {
+		return DefaultLayout
+	}
+	return f.Config.Layout
+}
+
+// writeFile stores the local file with the given key, verifying it if VerifyWrites is set
+func (f *FirmirrorSyncer) writeFile(ctx context.Context, key, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if !f.Config.VerifyWrites {
+		return f.Storage.Write(ctx, key, file)
+	}
+
+	digest, err := sha256Hex(file)
+	if err != nil {
+		return fmt.Errorf("failed to hash file: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return WriteVerified(ctx, f.Storage, key, file, digest)
+}
+
 func calculateChecksums(filepath string) (sha1Hash, sha256Hash string, err error) {
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -236,8 +326,6 @@ func calculateChecksums(filepath string) (sha1Hash, sha256Hash string, err error
 
 // LoadMetadata loads existing metadata.xml.zst and builds an index of existing firmware
 func (f *FirmirrorSyncer) LoadMetadata(ctx context.Context) error {
-	metadataKey := "metadata.xml.zst"
-
 	// Check if metadata file exists
 	exists, err := f.Storage.Exists(ctx, metadataKey)
 	if err != nil {
@@ -248,48 +336,77 @@ func (f *FirmirrorSyncer) LoadMetadata(ctx context.Context) error {
 		return nil
 	}
 
-	// Read metadata from storage
-	reader, err := f.Storage.Read(ctx, metadataKey)
+	components, err := readMetadata(ctx, f.Storage, metadataKey)
+	if err != nil {
+		return err
+	}
+
+	f.existingMetadata = components
+
+	// Build index of existing firmware files from checksums
+	for _, comp := range components.Component {
+		for _, release := range comp.Releases {
+			for _, checksum := range release.Checksums {
+				if checksum.Filename != "" {
+					f.existingIndex[checksum.Filename] = true
+				}
+			}
+			for _, provide := range comp.Provides {
+				f.existingReleases[releaseKey(provide.Text, release.Version)] = true
+			}
+		}
+	}
+
+	slog.Info("Loaded existing metadata",
+		"components", len(components.Component),
+		"firmware_files", len(f.existingIndex))
+
+	return nil
+}
+
+// readMetadata reads and parses the compressed metadata stored under key
+func readMetadata(ctx context.Context, storage Storage, key string) (*lvfs.Components, error) {
+	reader, err := storage.Read(ctx, key)
 	if err != nil {
-		return fmt.Errorf("failed to read metadata file: %w", err)
+		return nil, fmt.Errorf("failed to read metadata file: %w", err)
 	}
 	defer reader.Close()
 
 	zstReader, err := zstd.NewReader(reader)
 	if err != nil {
-		return fmt.Errorf("failed to create zstd reader: %w", err)
+		return nil, fmt.Errorf("failed to create zstd reader: %w", err)
 	}
 	defer zstReader.Close()
 
 	// Read and parse XML
 	data, err := io.ReadAll(zstReader)
 	if err != nil {
-		return fmt.Errorf("failed to read metadata file: %w", err)
+		return nil, fmt.Errorf("failed to read metadata file: %w", err)
 	}
 
 	var components lvfs.Components
 	if err := xml.Unmarshal(data, &components); err != nil {
-		return fmt.Errorf("failed to parse metadata XML: %w", err)
+		return nil, fmt.Errorf("failed to parse metadata XML: %w", err)
 	}
 
-	f.existingMetadata = &components
+	return &components, nil
+}
 
-	// Build index of existing firmware files from checksums
-	for _, comp := range components.Component {
-		for _, release := range comp.Releases {
-			for _, checksum := range release.Checksums {
-				if checksum.Filename != "" {
-					f.existingIndex[checksum.Filename] = true
-				}
-			}
+// hasRelease reports whether metadata already holds the given version for all the devices
+func (f *FirmirrorSyncer) hasRelease(guids []string, version string) bool {
+	if len(guids) == 0 || version == "" {
+		return false
+	}
+	for _, guid := range guids {
+		if !f.existingReleases[releaseKey(guid, version)] {
+			return false
 		}
 	}
+	return true
+}
 
-	slog.Info("Loaded existing metadata",
-		"components", len(components.Component),
-		"firmware_files", len(f.existingIndex))
-
-	return nil
+func releaseKey(guid, version string) string {
+	return strings.ToLower(guid) + "/" + version
 }
 
 // SaveMetadata saves the combined metadata (existing + accumulated) to metadata.xml.zst
@@ -297,47 +414,53 @@ func (f *FirmirrorSyncer) SaveMetadata(ctx context.Context) error {
 	ctx = context.WithoutCancel(ctx)
 	logger := slog.With("component", "metadata-save")
 
-	if len(f.newComponents) == 0 {
+	componentMap := f.mergeComponents(logger)
+
+	// Advisories also apply to the existing components, so a change of the advisories is
+	// published even without new firmware
+	advised := false
+	var merged []lvfs.Component
+	for _, component := range componentMap {
+		if applyAdvisories(f.Config.Advisories, component) {
+			advised = true
+		}
+		merged = append(merged, *component)
+	}
+
+	if len(f.newComponents) == 0 && !advised {
 		logger.Info("No new component, skipping metadata update")
 		return nil
 	}
 
-	componentMap := make(map[string]*lvfs.Component)
-
-	// Add existing components first
-	if f.existingMetadata != nil {
-		for i := range f.existingMetadata.Component {
-			comp := f.existingMetadata.Component[i]
-			componentMap[comp.ID] = &comp
-		}
+	// Drop the components and releases fwupd could not use, keeping them aside for inspection
+	valid, invalid := lvfs.ValidateComponents(merged)
+	for _, rejected := range invalid {
+		logger.Warn("Dropping invalid component", "id", rejected.Component.ID,
+			"releases", len(rejected.Component.Releases), "error", rejected.Err)
+	}
+	if len(valid) == 0 {
+		return errors.New("no valid component to publish")
+	}
+	if err := f.quarantine(ctx, invalid); err != nil {
+		return err
 	}
 
-	// Add or merge new components
-	for _, comp := range f.newComponents {
-		if existing, ok := componentMap[comp.ID]; ok {
-			// Merge releases if component already exists
-			logger.Debug("Merging component", "id", comp.ID)
-			existing.Releases = append(existing.Releases, comp.Releases...)
-		} else {
-			// Add new component
-			componentMap[comp.ID] = &comp
-		}
+	if err := f.publish(ctx, valid); err != nil {
+		return err
 	}
 
-	// Build final components structure
+	logger.Info("Metadata saved successfully",
+		"total_merged_components", len(componentMap),
+		"new_components", len(f.newComponents))
+
+	return nil
+}
+
+// publish writes the metadata of the components to storage, compressed and signed
+func (f *FirmirrorSyncer) publish(ctx context.Context, valid []lvfs.Component) error {
 	components := &lvfs.Components{
-		Origin: "firmirror",
-	}
-	for _, component := range componentMap {
-		// Ensure each release has a location tag
-		for i := range component.Releases {
-			release := &component.Releases[i]
-			if release.Location == "" && len(release.Checksums) > 0 {
-				// FIXME: this is brittle
-				release.Location = release.Checksums[0].Filename + ".cab"
-			}
-		}
-		components.Component = append(components.Component, *component)
+		Origin:    "firmirror",
+		Component: valid,
 	}
 
 	// Marshal metadata to XML
@@ -371,20 +494,151 @@ func (f *FirmirrorSyncer) SaveMetadata(ctx context.Context) error {
 
 	// Write compressed metadata to storage
 	for _, filePath := range []string{compressedPath, signaturePath} {
-		file, err := os.Open(filePath)
-		if err != nil {
-			return fmt.Errorf("failed to open file: %w", err)
-		}
-		defer file.Close()
-		if err := f.Storage.Write(ctx, filepath.Base(filePath), file); err != nil {
+		if err := f.writeFile(ctx, filepath.Base(filePath), filePath); err != nil {
 			return fmt.Errorf("failed to write file to storage: %w", err)
 		}
 	}
 
-	logger.Info("Metadata saved successfully",
-		"total_merged_components", len(componentMap),
-		"new_components", len(f.newComponents))
+	return nil
+}
+
+// mergeComponents combines the existing components with the ones accumulated during this
+// run, keyed by component ID. An existing component whose ID changed is only migrated when
+// a new release of it is mirrored, until then it stays published under its previous ID.
+func (f *FirmirrorSyncer) mergeComponents(logger *slog.Logger) map[string]*lvfs.Component {
+	componentMap := make(map[string]*lvfs.Component)
+
+	// Add existing components first. Components published before version formats get the
+	// one of their releases, so their versions are still valid.
+	if f.existingMetadata != nil {
+		for i := range f.existingMetadata.Component {
+			comp := f.existingMetadata.Component[i]
+			if comp.VersionFormat() == "" {
+				if format := comp.DetectVersionFormat(); format != "" {
+					comp.SetVersionFormat(format)
+				}
+			}
+			componentMap[comp.ID] = &comp
+		}
+	}
+
+	// Components produced during this run are never migrated
+	newIDs := make(map[string]bool)
+	for _, comp := range f.newComponents {
+		newIDs[comp.ID] = true
+	}
 
+	// Add or merge new components
+	for _, comp := range f.newComponents {
+		// Move the releases of existing components whose ID changed to the new ID
+		for _, id := range f.replacedComponents(componentMap, &comp, newIDs) {
+			logger.Info("Migrating component to its new ID", "from", id, "to", comp.ID)
+			replaced := componentMap[id]
+			delete(componentMap, id)
+
+			target := &comp
+			if existing, ok := componentMap[comp.ID]; ok {
+				target = existing
+			}
+			previous := normalizeVersions(replaced.Releases, versionFormat(&comp, replaced))
+			target.Releases = mergeReleases(previous, target.Releases)
+			if !slices.Contains(target.Replaces, id) {
+				target.Replaces = append(target.Replaces, id)
+			}
+		}
+
+		if existing, ok := componentMap[comp.ID]; ok {
+			// Merge releases if component already exists
+			logger.Debug("Merging component", "id", comp.ID)
+			previous := normalizeVersions(existing.Releases, versionFormat(&comp, existing))
+			existing.Releases = mergeReleases(previous, comp.Releases)
+			// Previous releases may predate the version format and the vendor, which follow
+			// the new releases
+			for _, key := range []string{lvfs.VersionFormatKey, vendorKey} {
+				if value := comp.CustomValue(key); value != "" {
+					existing.SetCustomValue(key, value)
+				}
+			}
+		} else {
+			// Add new component
+			componentMap[comp.ID] = &comp
+		}
+	}
+
+	return componentMap
+}
+
+// mergeReleases appends releases to the previous ones of a component. A release replaces
+// the previous one with the same version, so the latest mirrored copy wins.
+func mergeReleases(previous, releases []lvfs.Release) []lvfs.Release {
+	merged := make([]lvfs.Release, 0, len(previous)+len(releases))
+	indexes := make(map[string]int)
+	for _, release := range slices.Concat(previous, releases) {
+		if i, ok := indexes[release.Version]; ok {
+			merged[i] = release
+			continue
+		}
+		indexes[release.Version] = len(merged)
+		merged = append(merged, release)
+	}
+	return merged
+}
+
+// versionFormat returns the version format a component has once merged with the previous
+// one, which is the format of the new component if it has one
+func versionFormat(comp, previous *lvfs.Component) string {
+	if format := comp.VersionFormat(); format != "" {
+		return format
+	}
+	return previous.VersionFormat()
+}
+
+// normalizeVersions returns the releases with the versions not matching format rewritten
+// the way fwupd reports them, see lvfs.RedfishVersion. Releases published before versions
+// were normalized, such as "U46 v2.10 (05/22/2024)", are then kept with their new format.
+func normalizeVersions(releases []lvfs.Release, format string) []lvfs.Release {
+	normalized := slices.Clone(releases)
+	for i, release := range normalized {
+		if !lvfs.ValidVersion(release.Version, format) {
+			normalized[i].Version = lvfs.RedfishVersion(release.Version)
+		}
+	}
+	return normalized
+}
+
+// replacedComponents returns the IDs of the existing components that comp lists in its
+// replaces. Components providing the same devices are not merged, as distinct packages such
+// as per-system and generic images commonly flash the same device.
+func (f *FirmirrorSyncer) replacedComponents(componentMap map[string]*lvfs.Component, comp *lvfs.Component, newIDs map[string]bool) []string {
+	var ids []string
+	for _, id := range comp.Replaces {
+		if _, ok := componentMap[id]; ok && id != comp.ID && !newIDs[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// quarantine writes the rejected components to quarantine.xml so they can be inspected
+// and fixed, as they are not published anymore
+func (f *FirmirrorSyncer) quarantine(ctx context.Context, invalid []lvfs.InvalidComponent) error {
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	components := &lvfs.Components{Origin: "firmirror-quarantine"}
+	for _, rejected := range invalid {
+		components.Component = append(components.Component, rejected.Component)
+	}
+
+	xmlBytes, err := xml.MarshalIndent(components, "", "  ")
+	if err != nil {
+		return err
+	}
+	outBytes := append([]byte(xml.Header), xmlBytes...)
+	if err := f.Storage.Write(ctx, quarantineKey, bytes.NewReader(outBytes)); err != nil {
+		return fmt.Errorf("failed to write quarantine to storage: %w", err)
+	}
 	return nil
 }
 
@@ -1,9 +1,12 @@
 package firmirror
 
 import (
+	"bytes"
 	"context"
 	"encoding/xml"
 	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"testing"
@@ -63,6 +66,9 @@ func (m *MockVendor) RetrieveFirmware(entry FirmwareEntry, tmpDir string) error
 	}
 
 	m.retrievedFiles = append(m.retrievedFiles, filename)
+	if mockEntry, ok := entry.(*MockFirmwareEntry); ok {
+		mockEntry.retrieved = true
+	}
 	return nil
 }
 
@@ -77,10 +83,12 @@ func (m *MockCatalog) ListEntries() []FirmwareEntry {
 
 // MockFirmwareEntry implements the FirmwareEntry interface for testing
 type MockFirmwareEntry struct {
-	filename     string
-	sourceURL    string
-	appstream    *lvfs.Component
-	appstreamErr error
+	filename       string
+	sourceURL      string
+	appstream      *lvfs.Component
+	appstreamErr   error
+	needsRetrieval bool
+	retrieved      bool
 }
 
 func (m *MockFirmwareEntry) GetFilename() string {
@@ -95,9 +103,27 @@ func (m *MockFirmwareEntry) ToAppstream() (*lvfs.Component, error) {
 	if m.appstreamErr != nil {
 		return nil, m.appstreamErr
 	}
+	if m.needsRetrieval && !m.retrieved {
+		return nil, ErrNotRetrieved
+	}
 	return m.appstream, nil
 }
 
+// MockReleaseEntry implements the CatalogRelease interface for testing
+type MockReleaseEntry struct {
+	MockFirmwareEntry
+	version string
+	guids   []string
+}
+
+func (m *MockReleaseEntry) GetVersion() string {
+	return m.version
+}
+
+func (m *MockReleaseEntry) GetGUIDs() []string {
+	return m.guids
+}
+
 func TestNewFirmirrorSyncer(t *testing.T) {
 	t.Run("CreatesSyncerWithConfig", func(t *testing.T) {
 		syncer, _ := createTestSyncer(t)
@@ -154,8 +180,8 @@ func TestFirmirrorSyncer_ProcessVendor(t *testing.T) {
 			appstream: &lvfs.Component{
 				Type:            "firmware",
 				ID:              "com.test.firmware",
-				Name:            "Test Firmware",
-				Summary:         "Test firmware summary",
+				Name:            lvfs.Translations{{Text: "Test Firmware"}},
+				Summary:         lvfs.Translations{{Text: "Test firmware summary"}},
 				MetadataLicense: "proprietary",
 				ProjectLicense:  "proprietary",
 				Releases: []lvfs.Release{
@@ -230,7 +256,107 @@ func TestFirmirrorSyncer_ProcessVendor(t *testing.T) {
 		err := syncer.ProcessVendor(context.TODO(), mockVendor, "test-vendor")
 
 		assert.NoError(t, err, "ProcessVendor should not return error for individual firmware failures")
-		assert.Len(t, mockVendor.retrievedFiles, 1, "Firmware should be retrieved before appstream conversion")
+		assert.Empty(t, mockVendor.retrievedFiles, "Firmware should not be retrieved when conversion fails")
+	})
+
+	t.Run("InvalidComponent", func(t *testing.T) {
+		syncer, _ := createTestSyncer(t)
+
+		mockEntry := &MockFirmwareEntry{
+			filename: "test-firmware.bin",
+			appstream: &lvfs.Component{
+				Type:        "firmware",
+				ID:          "com.test.firmware",
+				Name:        lvfs.Translations{{Text: "Bad\x00name"}},
+				Description: []lvfs.Description{{}},
+			},
+		}
+
+		mockVendor := &MockVendor{
+			catalog: &MockCatalog{
+				entries: []FirmwareEntry{mockEntry},
+			},
+		}
+
+		err := syncer.ProcessVendor(context.TODO(), mockVendor, "test-vendor")
+
+		assert.NoError(t, err, "ProcessVendor should not return error for an invalid component")
+		assert.Empty(t, mockVendor.retrievedFiles, "Invalid firmware should not be retrieved")
+		assert.Empty(t, syncer.newComponents, "Invalid component should not be added to the metadata")
+	})
+
+	t.Run("ExcludedByFilter", func(t *testing.T) {
+		syncer, _ := createTestSyncer(t)
+
+		mockEntry := &MockFirmwareEntry{
+			filename:     "test-firmware.bin",
+			appstreamErr: fmt.Errorf("%w: unsupported product", ErrSkipped),
+		}
+
+		mockVendor := &MockVendor{
+			catalog: &MockCatalog{
+				entries: []FirmwareEntry{mockEntry},
+			},
+		}
+
+		err := syncer.ProcessVendor(context.TODO(), mockVendor, "test-vendor")
+
+		assert.NoError(t, err, "ProcessVendor should not return error for filtered firmware")
+		assert.Empty(t, mockVendor.retrievedFiles, "Filtered firmware should not be retrieved")
+	})
+
+	t.Run("ConversionNeedsRetrieval", func(t *testing.T) {
+		syncer, _ := createTestSyncer(t)
+
+		mockEntry := &MockFirmwareEntry{
+			filename:       "test-firmware.bin",
+			needsRetrieval: true,
+			appstream: &lvfs.Component{
+				Type: "firmware",
+				ID:   "com.test.firmware",
+			},
+		}
+
+		mockVendor := &MockVendor{
+			catalog: &MockCatalog{
+				entries: []FirmwareEntry{mockEntry},
+			},
+		}
+
+		err := syncer.ProcessVendor(context.TODO(), mockVendor, "test-vendor")
+
+		assert.NoError(t, err, "ProcessVendor should not return error")
+		assert.Len(t, mockVendor.retrievedFiles, 1, "Firmware should be retrieved to complete the conversion")
+	})
+
+	t.Run("SkipsUnchangedCatalogRelease", func(t *testing.T) {
+		syncer, _ := createTestSyncer(t)
+		syncer.existingReleases[releaseKey("a6b1a447-382a-5a4f-15b3-101d15b30042", "1.0.0")] = true
+
+		unchanged := &MockReleaseEntry{
+			MockFirmwareEntry: MockFirmwareEntry{filename: "unchanged.bin"},
+			version:           "1.0.0",
+			guids:             []string{"A6B1A447-382A-5A4F-15B3-101D15B30042"},
+		}
+		updated := &MockReleaseEntry{
+			MockFirmwareEntry: MockFirmwareEntry{
+				filename:  "updated.bin",
+				appstream: &lvfs.Component{Type: "firmware", ID: "com.test.firmware"},
+			},
+			version: "2.0.0",
+			guids:   []string{"a6b1a447-382a-5a4f-15b3-101d15b30042"},
+		}
+
+		mockVendor := &MockVendor{
+			catalog: &MockCatalog{
+				entries: []FirmwareEntry{unchanged, updated},
+			},
+		}
+
+		err := syncer.ProcessVendor(context.TODO(), mockVendor, "test-vendor")
+
+		assert.NoError(t, err, "ProcessVendor should not return error")
+		assert.Equal(t, []string{"updated.bin"}, mockVendor.retrievedFiles, "Only the new release should be retrieved")
 	})
 
 	t.Run("EmptyCatalog", func(t *testing.T) {
@@ -256,7 +382,7 @@ func TestFirmirrorSyncer_ProcessVendor(t *testing.T) {
 			appstream: &lvfs.Component{
 				Type:            "firmware",
 				ID:              "com.test.firmware1",
-				Name:            "Test Firmware 1",
+				Name:            lvfs.Translations{{Text: "Test Firmware 1"}},
 				MetadataLicense: "proprietary",
 			},
 		}
@@ -266,7 +392,7 @@ func TestFirmirrorSyncer_ProcessVendor(t *testing.T) {
 			appstream: &lvfs.Component{
 				Type:            "firmware",
 				ID:              "com.test.firmware2",
-				Name:            "Test Firmware 2",
+				Name:            lvfs.Translations{{Text: "Test Firmware 2"}},
 				MetadataLicense: "proprietary",
 			},
 		}
@@ -317,8 +443,8 @@ func TestFirmirrorSyncer_BuildPackage(t *testing.T) {
 		component := &lvfs.Component{
 			Type:            "firmware",
 			ID:              "com.test.firmware",
-			Name:            "Test Firmware",
-			Summary:         "Test summary",
+			Name:            lvfs.Translations{{Text: "Test Firmware"}},
+			Summary:         lvfs.Translations{{Text: "Test summary"}},
 			MetadataLicense: "proprietary",
 			ProjectLicense:  "proprietary",
 		}
@@ -330,7 +456,7 @@ func TestFirmirrorSyncer_BuildPackage(t *testing.T) {
 		require.NoError(t, err, "Should create test firmware file")
 
 		// Note: This will fail without fwupdtool, but we can test XML creation
-		syncer.buildPackage(context.TODO(), component, firmwareFilename, tmpDir)
+		syncer.buildPackage(context.TODO(), component, "test", firmwareFilename, tmpDir)
 
 		// Verify metainfo XML was created
 		metainfoPath := filepath.Join(tmpDir, "firmware.metainfo.xml")
@@ -365,7 +491,7 @@ func TestFirmirrorSyncer_LoadMetadata(t *testing.T) {
 				{
 					Type:            "firmware",
 					ID:              "com.test.firmware1",
-					Name:            "Test Firmware 1",
+					Name:            lvfs.Translations{{Text: "Test Firmware 1"}},
 					MetadataLicense: "proprietary",
 					Releases: []lvfs.Release{
 						{
@@ -383,7 +509,7 @@ func TestFirmirrorSyncer_LoadMetadata(t *testing.T) {
 				{
 					Type:            "firmware",
 					ID:              "com.test.firmware2",
-					Name:            "Test Firmware 2",
+					Name:            lvfs.Translations{{Text: "Test Firmware 2"}},
 					MetadataLicense: "proprietary",
 					Releases: []lvfs.Release{
 						{
@@ -483,11 +609,14 @@ func TestFirmirrorSyncer_SaveMetadata(t *testing.T) {
 			{
 				Type:            "firmware",
 				ID:              "com.test.firmware1",
-				Name:            "Test Firmware 1",
+				Name:            lvfs.Translations{{Text: "Test Firmware 1"}},
+				Summary:         lvfs.Translations{{Text: "Test firmware"}},
+				Provides:        []lvfs.Firmware{{Type: "flashed", Text: "dadc32f0-d6fc-575c-bad8-140b0e1d6850"}},
 				MetadataLicense: "proprietary",
 				Releases: []lvfs.Release{
 					{
-						Version: "1.0.0",
+						Version:  "1.0.0",
+						Location: "firmware1.bin.cab",
 						Checksums: []lvfs.Checksum{
 							{
 								Filename: "firmware1.bin",
@@ -526,7 +655,7 @@ func TestFirmirrorSyncer_SaveMetadata(t *testing.T) {
 		assert.Equal(t, "com.test.firmware1", components.Component[0].ID)
 		assert.Equal(t, "firmirror", components.Origin)
 
-		// Verify location tag was added
+		// Verify the recorded location was kept
 		assert.Equal(t, "firmware1.bin.cab", components.Component[0].Releases[0].Location,
 			"Should have location tag set")
 	})
@@ -545,12 +674,15 @@ func TestFirmirrorSyncer_SaveMetadata(t *testing.T) {
 		syncer.existingMetadata = &lvfs.Components{
 			Component: []lvfs.Component{
 				{
-					Type: "firmware",
-					ID:   "com.existing.firmware",
-					Name: "Existing Firmware",
+					Type:     "firmware",
+					ID:       "com.existing.firmware",
+					Name:     lvfs.Translations{{Text: "Existing Firmware"}},
+					Summary:  lvfs.Translations{{Text: "Test firmware"}},
+					Provides: []lvfs.Firmware{{Type: "flashed", Text: "dadc32f0-d6fc-575c-bad8-140b0e1d6850"}},
 					Releases: []lvfs.Release{
 						{
-							Version: "1.0.0",
+							Version:  "1.0.0",
+							Location: "existing.bin.cab",
 							Checksums: []lvfs.Checksum{
 								{Filename: "existing.bin"},
 							},
@@ -563,12 +695,15 @@ func TestFirmirrorSyncer_SaveMetadata(t *testing.T) {
 		// Add new components
 		syncer.newComponents = []lvfs.Component{
 			{
-				Type: "firmware",
-				ID:   "com.new.firmware",
-				Name: "New Firmware",
+				Type:     "firmware",
+				ID:       "com.new.firmware",
+				Name:     lvfs.Translations{{Text: "New Firmware"}},
+				Summary:  lvfs.Translations{{Text: "Test firmware"}},
+				Provides: []lvfs.Firmware{{Type: "flashed", Text: "6de5d951-d755-576b-bd09-c5cf66b27234"}},
 				Releases: []lvfs.Release{
 					{
-						Version: "2.0.0",
+						Version:  "2.0.0",
+						Location: "new.bin.cab",
 						Checksums: []lvfs.Checksum{
 							{Filename: "new.bin"},
 						},
@@ -628,12 +763,15 @@ func TestFirmirrorSyncer_SaveMetadata(t *testing.T) {
 		syncer.existingMetadata = &lvfs.Components{
 			Component: []lvfs.Component{
 				{
-					Type: "firmware",
-					ID:   "com.test.firmware",
-					Name: "Test Firmware",
+					Type:     "firmware",
+					ID:       "com.test.firmware",
+					Name:     lvfs.Translations{{Text: "Test Firmware"}},
+					Summary:  lvfs.Translations{{Text: "Test firmware"}},
+					Provides: []lvfs.Firmware{{Type: "flashed", Text: "dadc32f0-d6fc-575c-bad8-140b0e1d6850"}},
 					Releases: []lvfs.Release{
 						{
-							Version: "1.0.0",
+							Version:  "1.0.0",
+							Location: "firmware-v1.bin.cab",
 							Checksums: []lvfs.Checksum{
 								{Filename: "firmware-v1.bin"},
 							},
@@ -646,12 +784,15 @@ func TestFirmirrorSyncer_SaveMetadata(t *testing.T) {
 		// Add new release for the same component ID
 		syncer.newComponents = []lvfs.Component{
 			{
-				Type: "firmware",
-				ID:   "com.test.firmware",
-				Name: "Test Firmware",
+				Type:     "firmware",
+				ID:       "com.test.firmware",
+				Name:     lvfs.Translations{{Text: "Test Firmware"}},
+				Summary:  lvfs.Translations{{Text: "Test firmware"}},
+				Provides: []lvfs.Firmware{{Type: "flashed", Text: "dadc32f0-d6fc-575c-bad8-140b0e1d6850"}},
 				Releases: []lvfs.Release{
 					{
-						Version: "2.0.0",
+						Version:  "2.0.0",
+						Location: "firmware-v2.bin.cab",
 						Checksums: []lvfs.Checksum{
 							{Filename: "firmware-v2.bin"},
 						},
@@ -707,64 +848,365 @@ func TestFirmirrorSyncer_SaveMetadata(t *testing.T) {
 		assert.NoFileExists(t, metadataZstPath, "Should not create metadata file")
 	})
 
-	t.Run("AddsLocationTagsToReleases", func(t *testing.T) {
-		tmpDir := t.TempDir()
-		storage, err := NewLocalStorage(tmpDir)
-		require.NoError(t, err)
-		syncer := NewFirmirrorSyncer(FirmirrorConfig{
-			CacheDir:    filepath.Join(tmpDir, "cache"),
-			Certificate: "",
-			PrivateKey:  "",
-		}, storage)
+	t.Run("PublishesAdvisoryChanges", func(t *testing.T) {
+		syncer, tmpDir := createTestSyncer(t)
+		syncer.existingMetadata = &lvfs.Components{Component: []lvfs.Component{{
+			Type:     "firmware",
+			ID:       "com.test.firmware",
+			Name:     lvfs.Translations{{Text: "Test Firmware"}},
+			Summary:  lvfs.Translations{{Text: "Test firmware"}},
+			Provides: []lvfs.Firmware{{Type: "flashed", Text: "dadc32f0-d6fc-575c-bad8-140b0e1d6850"}},
+			Releases: []lvfs.Release{{Version: "1.0.0", Location: "firmware.bin.cab"}},
+		}}}
+
+		// Advisories already applied don't change anything
+		syncer.Config.Advisories = []Advisory{{ComponentID: "com.test.other", Issues: []string{"CVE-2024-1234"}}}
+		require.NoError(t, syncer.SaveMetadata(context.TODO()))
+		assert.NoFileExists(t, filepath.Join(tmpDir, "output", metadataKey), "Unchanged metadata should not be published")
+
+		syncer.Config.Advisories = []Advisory{{ComponentID: "com.test.firmware", Issues: []string{"CVE-2024-1234"}}}
+		require.NoError(t, syncer.SaveMetadata(context.TODO()))
+
+		components, err := readMetadata(context.TODO(), syncer.Storage, metadataKey)
+		require.NoError(t, err, "New advisories should be published without new firmware")
+		require.Len(t, components.Component, 1)
+		assert.Equal(t, []lvfs.Issue{{Type: "cve", Text: "CVE-2024-1234"}}, components.Component[0].Releases[0].Issues)
+	})
 
-		// Add components without location tags
+	t.Run("RejectsInvalidComponents", func(t *testing.T) {
+		syncer, tmpDir := createTestSyncer(t)
+
+		// No device GUID, so fwupd could not match the firmware
 		syncer.newComponents = []lvfs.Component{
 			{
-				Type: "firmware",
-				ID:   "com.test.firmware",
-				Releases: []lvfs.Release{
-					{
-						Version:  "1.0.0",
-						Location: "", // No location
-						Checksums: []lvfs.Checksum{
-							{Filename: "firmware.bin"},
-						},
-					},
-					{
-						Version:  "2.0.0",
-						Location: "already-set.cab", // Already has location
-						Checksums: []lvfs.Checksum{
-							{Filename: "firmware-v2.bin"},
-						},
-					},
-				},
+				Type:     "firmware",
+				ID:       "com.test.firmware",
+				Name:     lvfs.Translations{{Text: "Test Firmware"}},
+				Summary:  lvfs.Translations{{Text: "Test firmware"}},
+				Releases: []lvfs.Release{{Version: "1.0.0", Location: "firmware.bin.cab"}},
 			},
 		}
 
-		// Save metadata
-		err = syncer.SaveMetadata(context.TODO())
-		require.NoError(t, err)
+		err := syncer.SaveMetadata(context.TODO())
+		assert.ErrorContains(t, err, "no valid component", "Should not publish empty metadata")
+		assert.NoFileExists(t, filepath.Join(tmpDir, "output", "metadata.xml.zst"), "Should not create metadata file")
+	})
 
-		// Read and verify
-		metadataZstPath := filepath.Join(tmpDir, "metadata.xml.zst")
-		file, err := os.Open(metadataZstPath)
+	t.Run("RequiresRecordedLocations", func(t *testing.T) {
+		syncer, tmpDir := createTestSyncer(t)
+		component := func(id, guid, location string) lvfs.Component {
+			return lvfs.Component{
+				Type:     "firmware",
+				ID:       id,
+				Name:     lvfs.Translations{{Text: "Test Firmware"}},
+				Summary:  lvfs.Translations{{Text: "Test firmware"}},
+				Provides: []lvfs.Firmware{{Type: "flashed", Text: guid}},
+				Releases: []lvfs.Release{{
+					Version:   "1.0.0",
+					Location:  location,
+					Checksums: []lvfs.Checksum{{Filename: "firmware.bin"}},
+				}},
+			}
+		}
+		syncer.newComponents = []lvfs.Component{
+			component("com.test.recorded", "dadc32f0-d6fc-575c-bad8-140b0e1d6850", "dell/firmware.bin.cab"),
+			component("com.test.unrecorded", "6de5d951-d755-576b-bd09-c5cf66b27234", ""),
+		}
+
+		require.NoError(t, syncer.SaveMetadata(context.TODO()))
+
+		components, err := readMetadata(context.TODO(), syncer.Storage, metadataKey)
 		require.NoError(t, err)
-		defer file.Close()
+		require.Len(t, components.Component, 1, "Release without location should not be published")
+		assert.Equal(t, "dell/firmware.bin.cab", components.Component[0].Releases[0].Location,
+			"Should keep the location recorded at build time")
 
-		zstReader, err := zstd.NewReader(file)
+		quarantined, err := os.ReadFile(filepath.Join(tmpDir, "output", quarantineKey))
 		require.NoError(t, err)
-		defer zstReader.Close()
+		assert.Contains(t, string(quarantined), "com.test.unrecorded", "Location should not be guessed")
+	})
 
-		var components lvfs.Components
-		decoder := xml.NewDecoder(zstReader)
-		err = decoder.Decode(&components)
+	t.Run("DropsInvalidReleasesOfPreviousMetadata", func(t *testing.T) {
+		syncer, tmpDir := createTestSyncer(t)
+
+		// Metadata published before release validation: plain text fields, and an old
+		// release whose location could not be guessed
+		previous := xml.Header + `<components origin="firmirror">
+  <component type="firmware">
+    <id>com.dell.bios</id>
+    <name>BIOS</name>
+    <summary>Dell BIOS</summary>
+    <description><p>System BIOS</p></description>
+    <provides>
+      <firmware type="flashed">dadc32f0-d6fc-575c-bad8-140b0e1d6850</firmware>
+    </provides>
+    <metadata_license>proprietary</metadata_license>
+    <project_license>proprietary</project_license>
+    <releases>
+      <release version="1.2.0" date="2024-03-01" install_duration="0">
+        <location>bios-1.2.0.bin.cab</location>
+        <checksum filename="bios-1.2.0.bin" target="content"></checksum>
+        <description><p>Fixes</p></description>
+      </release>
+      <release version="1.1.0" date="2024-01-01" install_duration="0">
+        <description></description>
+      </release>
+    </releases>
+  </component>
+</components>`
+		encoder, err := zstd.NewWriter(nil)
 		require.NoError(t, err)
+		require.NoError(t, syncer.Storage.Write(context.TODO(), metadataKey,
+			bytes.NewReader(encoder.EncodeAll([]byte(previous), nil))))
+		require.NoError(t, syncer.LoadMetadata(context.TODO()))
+
+		// The new run mirrors a new release and an existing one again
+		syncer.newComponents = []lvfs.Component{{
+			Type:     "firmware",
+			ID:       "com.dell.bios",
+			Name:     lvfs.Translations{{Text: "BIOS"}},
+			Summary:  lvfs.Translations{{Text: "Dell BIOS"}},
+			Provides: []lvfs.Firmware{{Type: "flashed", Text: "dadc32f0-d6fc-575c-bad8-140b0e1d6850"}},
+			Releases: []lvfs.Release{
+				{Version: "1.2.0", Location: "dell/bios-1.2.0.bin.cab"},
+				{Version: "1.3.0", Location: "dell/bios-1.3.0.bin.cab"},
+			},
+		}}
+		require.NoError(t, syncer.SaveMetadata(context.TODO()))
 
-		// Verify locations
+		components, err := readMetadata(context.TODO(), syncer.Storage, metadataKey)
+		require.NoError(t, err)
+		require.Len(t, components.Component, 1, "Component should be kept with its valid releases")
 		releases := components.Component[0].Releases
-		assert.Equal(t, "firmware.bin.cab", releases[0].Location,
-			"Should add location based on checksum filename")
-		assert.Equal(t, "already-set.cab", releases[1].Location,
-			"Should preserve existing location")
+		require.Len(t, releases, 2, "Invalid release should be dropped and mirrored ones not duplicated")
+		assert.Equal(t, "1.2.0", releases[0].Version)
+		assert.Equal(t, "dell/bios-1.2.0.bin.cab", releases[0].Location, "Latest mirrored release should win")
+		assert.Equal(t, "1.3.0", releases[1].Version)
+
+		quarantined, err := os.ReadFile(filepath.Join(tmpDir, "output", quarantineKey))
+		require.NoError(t, err)
+		assert.Contains(t, string(quarantined), `version="1.1.0"`, "Dropped release should be quarantined")
+		assert.NotContains(t, string(quarantined), `version="1.3.0"`, "Valid releases should not be quarantined")
+	})
+
+	t.Run("KeepsPreviousComponentsWithoutVersionFormat", func(t *testing.T) {
+		syncer, tmpDir := createTestSyncer(t)
+
+		// Metadata published before version formats, with Dell plain versions
+		previous := xml.Header + `<components origin="firmirror">
+  <component type="firmware">
+    <id>com.dell.nic</id>
+    <name>Network Firmware</name>
+    <summary>Dell network firmware</summary>
+    <provides>
+      <firmware type="flashed">dadc32f0-d6fc-575c-bad8-140b0e1d6850</firmware>
+    </provides>
+    <releases>
+      <release version="A12" date="2024-03-01" install_duration="0">
+        <location>nic-A12.bin.cab</location>
+      </release>
+      <release version="A11" date="2024-01-01" install_duration="0">
+        <location>nic-A11.bin.cab</location>
+      </release>
+    </releases>
+  </component>
+</components>`
+		encoder, err := zstd.NewWriter(nil)
+		require.NoError(t, err)
+		require.NoError(t, syncer.Storage.Write(context.TODO(), metadataKey,
+			bytes.NewReader(encoder.EncodeAll([]byte(previous), nil))))
+		require.NoError(t, syncer.LoadMetadata(context.TODO()))
+
+		// The new run mirrors another component only
+		syncer.newComponents = []lvfs.Component{{
+			Type:     "firmware",
+			ID:       "com.dell.bios",
+			Name:     lvfs.Translations{{Text: "BIOS"}},
+			Summary:  lvfs.Translations{{Text: "Dell BIOS"}},
+			Provides: []lvfs.Firmware{{Type: "flashed", Text: "6de5d951-d755-576b-bd09-c5cf66b27234"}},
+			Releases: []lvfs.Release{{Version: "1.3.0", Location: "dell/bios-1.3.0.bin.cab"}},
+		}}
+		require.NoError(t, syncer.SaveMetadata(context.TODO()))
+
+		components, err := readMetadata(context.TODO(), syncer.Storage, metadataKey)
+		require.NoError(t, err)
+		require.Len(t, components.Component, 2, "Previous component should still be published")
+		for _, comp := range components.Component {
+			if comp.ID == "com.dell.nic" {
+				assert.Len(t, comp.Releases, 2, "Previous releases should be kept")
+				assert.Equal(t, lvfs.VersionFormatPlain, comp.VersionFormat(), "Version format should be detected from the releases")
+			}
+		}
+		assert.NoFileExists(t, filepath.Join(tmpDir, "output", quarantineKey), "Nothing should be quarantined")
+	})
+
+	t.Run("AppliesVersionFormatToPreviousReleases", func(t *testing.T) {
+		syncer, _ := createTestSyncer(t)
+		component := func(id, guid string, releases ...lvfs.Release) lvfs.Component {
+			return lvfs.Component{
+				Type:     "firmware",
+				ID:       id,
+				Name:     lvfs.Translations{{Text: "Test Firmware"}},
+				Summary:  lvfs.Translations{{Text: "Test firmware"}},
+				Provides: []lvfs.Firmware{{Type: "flashed", Text: guid}},
+				Releases: releases,
+			}
+		}
+
+		// Releases published before version formats, with Dell plain versions and raw HPE versions
+		syncer.existingMetadata = &lvfs.Components{Component: []lvfs.Component{
+			component("com.dell.nic", "dadc32f0-d6fc-575c-bad8-140b0e1d6850",
+				lvfs.Release{Version: "A12", Location: "dell/nic-A12.cab"}),
+			component("com.hpe.ilo", "6de5d951-d755-576b-bd09-c5cf66b27234",
+				lvfs.Release{Version: "U46 v2.10 (05/22/2024)", Location: "hpe/ilo-2.10.cab"},
+				lvfs.Release{Version: "U46 v2.12 (07/01/2024)", Location: "hpe/ilo-2.12-old.cab"}),
+		}}
+		dell := component("com.dell.nic", "dadc32f0-d6fc-575c-bad8-140b0e1d6850",
+			lvfs.Release{Version: "A13", Location: "dell/nic-A13.cab"})
+		dell.SetVersionFormat(lvfs.VersionFormatPlain)
+		hpe := component("com.hpe.ilo", "6de5d951-d755-576b-bd09-c5cf66b27234",
+			lvfs.Release{Version: "2.12", Location: "hpe/ilo-2.12.cab"})
+		hpe.SetVersionFormat(lvfs.VersionFormatPair)
+		syncer.newComponents = []lvfs.Component{dell, hpe}
+
+		require.NoError(t, syncer.SaveMetadata(context.TODO()))
+
+		components, err := readMetadata(context.TODO(), syncer.Storage, metadataKey)
+		require.NoError(t, err)
+		require.Len(t, components.Component, 2, "Components should be kept")
+		versions := map[string][]string{}
+		locations := map[string]string{}
+		for _, comp := range components.Component {
+			for _, release := range comp.Releases {
+				versions[comp.ID] = append(versions[comp.ID], release.Version)
+				locations[release.Version] = release.Location
+			}
+		}
+		assert.Equal(t, []string{"A12", "A13"}, versions["com.dell.nic"], "Previous plain versions should use the new format")
+		assert.Equal(t, []string{"2.10", "2.12"}, versions["com.hpe.ilo"], "Previous raw versions should be normalized to the new format")
+		assert.Equal(t, "hpe/ilo-2.12.cab", locations["2.12"], "Normalized versions should be replaced by the mirrored release")
+	})
+}
+
+func TestFirmirrorSyncer_Quarantine(t *testing.T) {
+	syncer, tmpDir := createTestSyncer(t)
+
+	t.Run("NothingToQuarantine", func(t *testing.T) {
+		require.NoError(t, syncer.quarantine(context.TODO(), nil))
+		assert.NoFileExists(t, filepath.Join(tmpDir, "output", quarantineKey), "Should not create quarantine file")
+	})
+
+	t.Run("WritesRejectedComponents", func(t *testing.T) {
+		invalid := []lvfs.InvalidComponent{
+			{Component: lvfs.Component{Type: "firmware", ID: "com.test.broken"}, Err: errors.New("releases: no release")},
+		}
+		require.NoError(t, syncer.quarantine(context.TODO(), invalid))
+
+		data, err := os.ReadFile(filepath.Join(tmpDir, "output", quarantineKey))
+		require.NoError(t, err, "Quarantine file should exist")
+
+		var components lvfs.Components
+		require.NoError(t, xml.Unmarshal(data, &components))
+		require.Len(t, components.Component, 1, "Rejected component should be kept")
+		assert.Equal(t, "com.test.broken", components.Component[0].ID)
+	})
+}
+
+func TestFirmirrorSyncer_MergeComponents(t *testing.T) {
+	provides := func(guids ...string) []lvfs.Firmware {
+		var out []lvfs.Firmware
+		for _, guid := range guids {
+			out = append(out, lvfs.Firmware{Type: "flashed", Text: guid})
+		}
+		return out
+	}
+
+	t.Run("MigratesReplacedID", func(t *testing.T) {
+		syncer, _ := createTestSyncer(t)
+		syncer.existingMetadata = &lvfs.Components{Component: []lvfs.Component{
+			{ID: "com.dell.old-name", Provides: provides("guid-1"), Releases: []lvfs.Release{{Version: "1.0"}}},
+		}}
+		syncer.newComponents = []lvfs.Component{
+			{ID: "com.dell.stable", Replaces: []string{"com.dell.old-name"}, Provides: provides("guid-1", "guid-2"), Releases: []lvfs.Release{{Version: "2.0"}}},
+		}
+
+		components := syncer.mergeComponents(slog.Default())
+
+		require.Len(t, components, 1, "Replaced component should be merged")
+		comp := components["com.dell.stable"]
+		require.NotNil(t, comp, "Component should use its new ID")
+		assert.Equal(t, []lvfs.Release{{Version: "1.0"}, {Version: "2.0"}}, comp.Releases, "Release history should be kept")
+		assert.Equal(t, []string{"com.dell.old-name"}, comp.Replaces, "Component should list the replaced ID")
+		assert.Len(t, comp.Provides, 2, "Component should use the new metadata")
+	})
+
+	t.Run("KeepsComponentsWithSameDevices", func(t *testing.T) {
+		syncer, _ := createTestSyncer(t)
+		syncer.existingMetadata = &lvfs.Components{Component: []lvfs.Component{
+			{ID: "com.hpe.option-rom", Provides: provides("guid-1", "guid-2"), Releases: []lvfs.Release{{Version: "1.0"}}},
+			{ID: "com.hpe.combined", Provides: provides("guid-1", "guid-2"), Releases: []lvfs.Release{{Version: "1.5"}}},
+		}}
+		syncer.newComponents = []lvfs.Component{
+			{ID: "com.hpe.combined", Provides: provides("guid-2", "guid-1"), Releases: []lvfs.Release{{Version: "2.0"}}},
+		}
+
+		components := syncer.mergeComponents(slog.Default())
+
+		require.Len(t, components, 2, "Distinct packages flashing the same devices should not be merged")
+		assert.Equal(t, []lvfs.Release{{Version: "1.0"}}, components["com.hpe.option-rom"].Releases, "Other package should be unchanged")
+		assert.Equal(t, []lvfs.Release{{Version: "1.5"}, {Version: "2.0"}}, components["com.hpe.combined"].Releases, "Releases should be merged by ID")
+		assert.Empty(t, components["com.hpe.combined"].Replaces, "Component should not replace anything")
+	})
+
+	t.Run("KeepsDistinctComponents", func(t *testing.T) {
+		syncer, _ := createTestSyncer(t)
+		syncer.existingMetadata = &lvfs.Components{Component: []lvfs.Component{
+			{ID: "com.dell.a", Provides: provides("guid-1"), Releases: []lvfs.Release{{Version: "1.0"}}},
+			{ID: "com.dell.b", Releases: []lvfs.Release{{Version: "1.0"}}},
+		}}
+		syncer.newComponents = []lvfs.Component{
+			{ID: "com.dell.c", Provides: provides("guid-1", "guid-2"), Releases: []lvfs.Release{{Version: "2.0"}}},
+			{ID: "com.dell.d", Releases: []lvfs.Release{{Version: "2.0"}}},
+		}
+
+		components := syncer.mergeComponents(slog.Default())
+
+		assert.Len(t, components, 4, "Components with different devices should not be merged")
+		assert.Empty(t, components["com.dell.c"].Replaces, "Component should not replace anything")
+	})
+
+	t.Run("NewComponentsNotMigrated", func(t *testing.T) {
+		syncer, _ := createTestSyncer(t)
+		syncer.existingMetadata = &lvfs.Components{Component: []lvfs.Component{
+			{ID: "com.hpe.a", Provides: provides("guid-1"), Releases: []lvfs.Release{{Version: "1.0"}}},
+		}}
+		syncer.newComponents = []lvfs.Component{
+			{ID: "com.hpe.a", Provides: provides("guid-1"), Releases: []lvfs.Release{{Version: "2.0"}}},
+			{ID: "com.hpe.b", Provides: provides("guid-1"), Releases: []lvfs.Release{{Version: "3.0"}}},
+		}
+
+		components := syncer.mergeComponents(slog.Default())
+
+		assert.Len(t, components, 2, "Components produced during the run should not be merged together")
+		assert.Equal(t, []lvfs.Release{{Version: "1.0"}, {Version: "2.0"}}, components["com.hpe.a"].Releases, "Releases should be merged by ID")
+	})
+
+	t.Run("DeduplicatesReleases", func(t *testing.T) {
+		syncer, _ := createTestSyncer(t)
+		syncer.existingMetadata = &lvfs.Components{Component: []lvfs.Component{
+			{ID: "com.dell.old-name", Releases: []lvfs.Release{{Version: "1.0", Location: "old-1.0.cab"}}},
+			{ID: "com.dell.stable", Releases: []lvfs.Release{{Version: "1.0", Location: "stable-1.0.cab"}, {Version: "2.0", Location: "stable-2.0.cab"}}},
+		}}
+		syncer.newComponents = []lvfs.Component{
+			{ID: "com.dell.stable", Replaces: []string{"com.dell.old-name"}, Releases: []lvfs.Release{{Version: "2.0", Location: "dell/stable-2.0.cab"}}},
+		}
+
+		components := syncer.mergeComponents(slog.Default())
+
+		require.Len(t, components, 1, "Replaced component should be merged")
+		assert.Equal(t, []lvfs.Release{
+			{Version: "1.0", Location: "stable-1.0.cab"},
+			{Version: "2.0", Location: "dell/stable-2.0.cab"},
+		}, components["com.dell.stable"].Releases, "Releases should be unique per version, the latest winning")
 	})
 }
@@ -0,0 +1,89 @@
+package firmirror
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultLayout stores the CABs under the file name published by the vendor, in a flat
+// namespace shared by all the vendors
+const DefaultLayout = "{filename}.cab"
+
+// layoutPlaceholder matches the placeholders of a layout, such as {sha256}
+var layoutPlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// layoutPlaceholders are the placeholders a layout can use
+var layoutPlaceholders = []string{"vendor", "component", "version", "sha256", "filename"}
+
+// vendorKey is the custom value recording the name of the vendor a component is mirrored
+// from, so that migrating the CABs of the metadata uses the same {vendor} as building them
+const vendorKey = "firmirror::Vendor"
+
+// CABFields are the values the placeholders of a layout are replaced with
+type CABFields struct {
+	Vendor    string // Name the vendor is registered with, e.g. hpe-gen11
+	Component string // Component ID
+	Version   string // Release version
+	SHA256    string // Digest of the CAB
+	Filename  string // Name of the firmware file published by the vendor
+}
+
+// ValidateLayout checks that the layout only uses known placeholders and identifies a CAB,
+// with {sha256}, {filename}, or {component} and {version}
+func ValidateLayout(layout string) error {
+	var used []string
+	for _, match := range layoutPlaceholder.FindAllStringSubmatch(layout, -1) {
+		if !slices.Contains(layoutPlaceholders, match[1]) {
+			return fmt.Errorf("layout %q: unknown placeholder {%s}", layout, match[1])
+		}
+		used = append(used, match[1])
+	}
+
+	if strings.HasPrefix(layout, "/") || path.Clean(layout) != layout || slices.Contains(strings.Split(layout, "/"), "..") {
+		return fmt.Errorf("layout %q: must be a clean relative path", layout)
+	}
+	if !slices.Contains(used, "sha256") && !slices.Contains(used, "filename") &&
+		!(slices.Contains(used, "component") && slices.Contains(used, "version")) {
+		return fmt.Errorf("layout %q: must contain {sha256}, {filename}, or {component} and {version}", layout)
+	}
+	return nil
+}
+
+// CABKey returns the storage key of a CAB according to the layout
+func CABKey(layout string, fields CABFields) string {
+	values := map[string]string{
+		"vendor":    fields.Vendor,
+		"component": fields.Component,
+		"version":   fields.Version,
+		"sha256":    fields.SHA256,
+		"filename":  fields.Filename,
+	}
+	return layoutPlaceholder.ReplaceAllStringFunc(layout, func(placeholder string) string {
+		// Values never add or escape directories
+		value := strings.NewReplacer("/", "_", `\`, "_").Replace(values[strings.Trim(placeholder, "{}")])
+		if value == "" || value == "." || value == ".." {
+			return "_"
+		}
+		return value
+	})
+}
+
+// inLayout reports whether the key could have been produced by the layout, whatever its values
+func inLayout(layout, key string) bool {
+	pattern := "^"
+	last := 0
+	for _, loc := range layoutPlaceholder.FindAllStringSubmatchIndex(layout, -1) {
+		pattern += regexp.QuoteMeta(layout[last:loc[0]])
+		if layout[loc[2]:loc[3]] == "sha256" {
+			pattern += "[0-9a-f]{64}"
+		} else {
+			pattern += "[^/]+"
+		}
+		last = loc[1]
+	}
+	pattern += regexp.QuoteMeta(layout[last:]) + "$"
+	return regexp.MustCompile(pattern).MatchString(key)
+}
@@ -0,0 +1,67 @@
+package firmirror
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLayout(t *testing.T) {
+	tests := []struct {
+		layout string
+		err    string
+	}{
+		{layout: DefaultLayout},
+		{layout: "{vendor}/{sha256}.cab"},
+		{layout: "{vendor}/{component}/{version}.cab"},
+		{layout: "{vendor}/{checksum}.cab", err: "unknown placeholder {checksum}"},
+		{layout: "{vendor}/{version}.cab", err: "must contain {sha256}, {filename}, or {component} and {version}"},
+		{layout: "/srv/{sha256}.cab", err: "must be a clean relative path"},
+		{layout: "../{sha256}.cab", err: "must be a clean relative path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			err := ValidateLayout(tt.layout)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCABKey(t *testing.T) {
+	const digest = "8a12ac1ee9d33bb41e4579af5878e1217fd607b44ffe213c16cb33389069d30f"
+	fields := CABFields{Vendor: "hpe-gen11", Component: "com.hpe.ilo6", Version: "1.60", SHA256: digest, Filename: "ilo6_160.fwpkg"}
+
+	tests := []struct {
+		layout string
+		key    string
+	}{
+		{layout: DefaultLayout, key: "ilo6_160.fwpkg.cab"},
+		{layout: "{vendor}/{sha256}.cab", key: "hpe-gen11/" + digest + ".cab"},
+		{layout: "{vendor}/{component}/{version}.cab", key: "hpe-gen11/com.hpe.ilo6/1.60.cab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			key := CABKey(tt.layout, fields)
+			assert.Equal(t, tt.key, key)
+			assert.True(t, inLayout(tt.layout, key), "Key should fit its layout")
+		})
+	}
+
+	t.Run("Sanitized", func(t *testing.T) {
+		key := CABKey("{vendor}/{component}/{version}.cab", CABFields{Vendor: "dell", Component: "../etc", Version: "A12/B"})
+		assert.Equal(t, "dell/.._etc/A12_B.cab", key, "Values should not add directories")
+		assert.Equal(t, "_/_.cab", CABKey("{vendor}/{version}.cab", CABFields{Version: ".."}))
+	})
+
+	t.Run("InLayout", func(t *testing.T) {
+		assert.False(t, inLayout("{vendor}/{sha256}.cab", "firmware.bin.cab"), "Flat key should not fit a nested layout")
+		assert.False(t, inLayout("{vendor}/{sha256}.cab", "dell/firmware.bin.cab"), "Digest should be a sha256")
+		assert.False(t, inLayout(DefaultLayout, "dell/firmware.bin.cab"), "Nested key should not fit the flat layout")
+	})
+}
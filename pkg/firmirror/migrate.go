@@ -0,0 +1,188 @@
+package firmirror
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/criteo/firmirror/pkg/lvfs"
+)
+
+// move is the copy of a CAB to its key in the new layout
+type move struct {
+	from, to string
+	sha256   string
+}
+
+// MigrateLayout copies the CABs of the metadata in storage to their key in the configured
+// layout, then publishes the metadata pointing to them. Unless deletePrevious is set, which
+// requires a ManagedStorage, the CABs are left at their previous key for the clients still
+// holding the previous metadata. It returns the number of CABs copied.
+//
+// CABs whose key already fits the layout are kept. The other ones take the vendor recorded in
+// their component when it was mirrored, see componentVendor.
+func (f *FirmirrorSyncer) MigrateLayout(ctx context.Context, deletePrevious bool) (int, error) {
+	ctx = context.WithoutCancel(ctx)
+	if err := ValidateLayout(f.layout()); err != nil {
+		return 0, err
+	}
+	if _, ok := f.Storage.(ManagedStorage); deletePrevious && !ok {
+		return 0, fmt.Errorf("%T cannot delete the previous CABs", f.Storage)
+	}
+
+	exists, err := f.Storage.Exists(ctx, metadataKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check %s existence: %w", metadataKey, err)
+	}
+	if !exists {
+		return 0, fmt.Errorf("%s not found in storage", metadataKey)
+	}
+
+	components, err := readMetadata(ctx, f.Storage, metadataKey)
+	if err != nil {
+		return 0, err
+	}
+
+	// Plan all the moves first, so that a layout mapping different CABs to the same key is
+	// rejected before anything is copied
+	var releases []*lvfs.Release
+	var fields []CABFields
+	current := map[string]string{} // sha256 of the CABs by their current key
+	for i := range components.Component {
+		component := &components.Component[i]
+		for j := range component.Releases {
+			release := &component.Releases[j]
+			if release.Location == "" || strings.Contains(release.Location, "://") {
+				continue
+			}
+
+			digest, err := f.releaseDigest(ctx, release, current)
+			if err != nil {
+				return 0, err
+			}
+			current[release.Location] = digest
+
+			releases = append(releases, release)
+			fields = append(fields, CABFields{
+				Vendor:    componentVendor(component),
+				Component: component.ID,
+				Version:   release.Version,
+				SHA256:    digest,
+				Filename:  releaseFilename(release),
+			})
+		}
+	}
+
+	moves := map[string]move{}
+	for i, release := range releases {
+		if inLayout(f.layout(), release.Location) {
+			continue
+		}
+		key := CABKey(f.layout(), fields[i])
+		if digest, ok := current[key]; ok && digest != fields[i].SHA256 {
+			return 0, fmt.Errorf("layout maps %s to %s, which holds another CAB", release.Location, key)
+		}
+		if planned, ok := moves[key]; ok && planned.sha256 != fields[i].SHA256 {
+			return 0, fmt.Errorf("layout maps both %s and %s to %s", planned.from, release.Location, key)
+		}
+
+		moves[key] = move{from: release.Location, to: key, sha256: fields[i].SHA256}
+		release.Location = key
+		for k := range release.Checksums {
+			if release.Checksums[k].Target == lvfs.ChecksumContainer {
+				release.Checksums[k].Filename = path.Base(key)
+			}
+		}
+	}
+
+	for _, m := range moves {
+		slog.Info("Copying CAB", "from", m.from, "to", m.to)
+		if err := f.copyCAB(ctx, m); err != nil {
+			return 0, fmt.Errorf("failed to copy %s to %s: %w", m.from, m.to, err)
+		}
+	}
+
+	if len(moves) > 0 {
+		if err := f.publish(ctx, components.Component); err != nil {
+			return 0, err
+		}
+	}
+
+	if deletePrevious {
+		for _, m := range moves {
+			slog.Info("Deleting previous CAB", "key", m.from)
+			if err := deleteKey(ctx, f.Storage, m.from); err != nil {
+				return len(moves), fmt.Errorf("failed to delete %s: %w", m.from, err)
+			}
+		}
+	}
+	return len(moves), nil
+}
+
+// releaseDigest returns the sha256 of the CAB of the release, from its container checksum or
+// by hashing the stored CAB unless already in digests, which is then recorded as its container
+// checksum
+func (f *FirmirrorSyncer) releaseDigest(ctx context.Context, release *lvfs.Release, digests map[string]string) (string, error) {
+	for _, checksum := range release.Checksums {
+		if checksum.Target == lvfs.ChecksumContainer && checksum.Type == "sha256" {
+			return strings.ToLower(checksum.Value), nil
+		}
+	}
+
+	digest, ok := digests[release.Location]
+	if !ok {
+		reader, err := f.Storage.Read(ctx, release.Location)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", release.Location, err)
+		}
+		defer reader.Close()
+
+		if digest, err = sha256Hex(reader); err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", release.Location, err)
+		}
+	}
+
+	release.Checksums = append(release.Checksums, lvfs.Checksum{
+		Filename: path.Base(release.Location),
+		Target:   lvfs.ChecksumContainer,
+		Type:     "sha256",
+		Value:    digest,
+	})
+	return digest, nil
+}
+
+// copyCAB copies the CAB to its new key, checking that it is intact
+func (f *FirmirrorSyncer) copyCAB(ctx context.Context, m move) error {
+	reader, err := f.Storage.Read(ctx, m.from)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return WriteVerified(ctx, f.Storage, m.to, reader, m.sha256)
+}
+
+// releaseFilename returns the name of the firmware file published by the vendor
+func releaseFilename(release *lvfs.Release) string {
+	for _, checksum := range release.Checksums {
+		if checksum.Target != lvfs.ChecksumContainer && checksum.Filename != "" {
+			return checksum.Filename
+		}
+	}
+	return strings.TrimSuffix(path.Base(release.Location), ".cab")
+}
+
+// componentVendor returns the name of the vendor recorded in the component when it was
+// mirrored or, for the components mirrored before it was recorded, the vendor of its ID
+// such as dell for com.dell.xxx
+func componentVendor(component *lvfs.Component) string {
+	if vendor := component.CustomValue(vendorKey); vendor != "" {
+		return vendor
+	}
+	if parts := strings.Split(component.ID, "."); len(parts) > 2 {
+		return parts[1]
+	}
+	return "unknown"
+}
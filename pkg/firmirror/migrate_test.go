@@ -0,0 +1,126 @@
+package firmirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/criteo/firmirror/pkg/lvfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFirmirrorSyncer_MigrateLayout(t *testing.T) {
+	ctx := context.TODO()
+	sum := sha256.Sum256([]byte("cab")) // Content of the files stored by writeRepository
+	digest := hex.EncodeToString(sum[:])
+
+	component := func(id, location string, checksums ...lvfs.Checksum) lvfs.Component {
+		return lvfs.Component{
+			Type:     "firmware",
+			ID:       id,
+			Releases: []lvfs.Release{{Version: "1.0.0", Location: location, Checksums: checksums}},
+		}
+	}
+	content := func(filename string) lvfs.Checksum {
+		return lvfs.Checksum{Filename: filename, Target: lvfs.ChecksumContent, Type: "sha256", Value: "0000"}
+	}
+
+	t.Run("MovesCABs", func(t *testing.T) {
+		syncer, tmpDir := createTestSyncer(t)
+		syncer.Config.Layout = "{vendor}/{sha256}.cab"
+		nic := component("com.hpe.nic", "nic.fwpkg.cab", content("nic.fwpkg"))
+		nic.SetCustomValue(vendorKey, "hpe-gen11")
+		writeRepository(t, syncer.Storage, []lvfs.Component{
+			component("com.dell.bios", "bios.exe.cab", content("bios.exe"),
+				lvfs.Checksum{Filename: "bios.exe.cab", Target: lvfs.ChecksumContainer, Type: "sha256", Value: digest}),
+			component("com.hpe.ilo6", "ilo6.fwpkg.cab", content("ilo6.fwpkg")),
+			component("com.test.remote", "https://fwupd.org/downloads/remote.cab"),
+			nic,
+		}, "bios.exe.cab", "ilo6.fwpkg.cab", "nic.fwpkg.cab")
+
+		moved, err := syncer.MigrateLayout(ctx, false)
+		require.NoError(t, err)
+		assert.Equal(t, 3, moved)
+
+		components, err := readMetadata(ctx, syncer.Storage, metadataKey)
+		require.NoError(t, err)
+		dell, hpe, remote := components.Component[0].Releases[0], components.Component[1].Releases[0], components.Component[2].Releases[0]
+		assert.Equal(t, "dell/"+digest+".cab", dell.Location, "Location should follow the layout")
+		assert.Equal(t, "hpe/"+digest+".cab", hpe.Location, "Vendor should be taken from the component ID")
+		assert.Equal(t, "https://fwupd.org/downloads/remote.cab", remote.Location, "Absolute locations should be kept")
+		assert.Equal(t, "hpe-gen11/"+digest+".cab", components.Component[3].Releases[0].Location, "Vendor recorded in the component should be used")
+		assert.Contains(t, hpe.Checksums, lvfs.Checksum{Filename: digest + ".cab", Target: lvfs.ChecksumContainer, Type: "sha256", Value: digest},
+			"Digest of the CAB should be recorded")
+
+		for _, key := range []string{"dell/" + digest + ".cab", "hpe/" + digest + ".cab", "bios.exe.cab", "ilo6.fwpkg.cab"} {
+			assert.FileExists(t, filepath.Join(tmpDir, "output", key), "CABs should be copied, keeping the previous ones")
+		}
+
+		report, err := VerifyRepository(ctx, syncer.Storage)
+		require.NoError(t, err)
+		assert.Equal(t, 3, report.Verified, "Migrated repository should be intact")
+
+		moved, err = syncer.MigrateLayout(ctx, false)
+		require.NoError(t, err)
+		assert.Zero(t, moved, "CABs already in the layout should be kept")
+	})
+
+	t.Run("DeletesPreviousCABs", func(t *testing.T) {
+		syncer, tmpDir := createTestSyncer(t)
+		syncer.Config.Layout = "{vendor}/{sha256}.cab"
+		writeRepository(t, syncer.Storage, []lvfs.Component{
+			component("com.dell.bios", "bios.exe.cab", content("bios.exe")),
+		}, "bios.exe.cab")
+
+		moved, err := syncer.MigrateLayout(ctx, true)
+		require.NoError(t, err)
+		assert.Equal(t, 1, moved)
+		assert.FileExists(t, filepath.Join(tmpDir, "output", "dell", digest+".cab"), "CAB should be copied")
+		assert.NoFileExists(t, filepath.Join(tmpDir, "output", "bios.exe.cab"), "Previous CAB should be deleted")
+	})
+
+	t.Run("DeletePreviousNeedsManagedStorage", func(t *testing.T) {
+		syncer, tmpDir := createTestSyncer(t)
+		syncer.Config.Layout = "{vendor}/{sha256}.cab"
+		writeRepository(t, syncer.Storage, []lvfs.Component{
+			component("com.dell.bios", "bios.exe.cab", content("bios.exe")),
+		}, "bios.exe.cab")
+		// Embedding the interface hides List and Delete
+		syncer.Storage = struct{ Storage }{syncer.Storage}
+
+		_, err := syncer.MigrateLayout(ctx, true)
+		assert.ErrorContains(t, err, "cannot delete the previous CABs")
+		assert.NoFileExists(t, filepath.Join(tmpDir, "output", "dell", digest+".cab"), "Nothing should be copied")
+	})
+
+	t.Run("RejectsCollisions", func(t *testing.T) {
+		syncer, tmpDir := createTestSyncer(t)
+		syncer.Config.Layout = DefaultLayout
+		writeRepository(t, syncer.Storage, []lvfs.Component{
+			component("com.hpe.gen10", "gen10/firmware.bin.cab", content("firmware.bin")),
+			component("com.hpe.gen11", "gen11/firmware.bin.cab", content("firmware.bin")),
+		}, "gen10/firmware.bin.cab")
+		require.NoError(t, syncer.Storage.Write(ctx, "gen11/firmware.bin.cab", strings.NewReader("other")))
+
+		_, err := syncer.MigrateLayout(ctx, false)
+		assert.ErrorContains(t, err, "layout maps both gen10/firmware.bin.cab and gen11/firmware.bin.cab to firmware.bin.cab")
+		assert.NoFileExists(t, filepath.Join(tmpDir, "output", "firmware.bin.cab"), "Nothing should be copied")
+	})
+
+	t.Run("InvalidLayout", func(t *testing.T) {
+		syncer, _ := createTestSyncer(t)
+		syncer.Config.Layout = "{vendor}.cab"
+		_, err := syncer.MigrateLayout(ctx, false)
+		assert.ErrorContains(t, err, "must contain")
+	})
+
+	t.Run("MissingMetadata", func(t *testing.T) {
+		syncer, _ := createTestSyncer(t)
+		_, err := syncer.MigrateLayout(ctx, false)
+		assert.ErrorContains(t, err, "metadata.xml.zst not found")
+	})
+}
@@ -0,0 +1,310 @@
+package firmirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RemoteConfPath is where Server publishes the fwupd remote configuration of the repository,
+// to be saved to /etc/fwupd/remotes.d/firmirror.conf
+const RemoteConfPath = "/.well-known/fwupd/firmirror.conf"
+
+// internalKeys are the files firmirror keeps in storage for itself, which are never served
+var internalKeys = []string{quarantineKey, pendingKey}
+
+const (
+	// objectCacheTTL bounds how long an overwritten CAB of a storage without RangeStorage
+	// can be described by its previous size and ETag
+	objectCacheTTL = time.Minute
+	// objectCacheSize bounds the number of CABs described at once
+	objectCacheSize = 1024
+)
+
+// ServerConfig configures Server
+type ServerConfig struct {
+	BaseURL string // Public URL of the repository, e.g. behind a reverse proxy. The remote configuration is only served with it.
+	Title   string // Title of the fwupd remote, defaults to Firmirror
+}
+
+// Server serves a repository in storage to fwupd clients over HTTP, with the conditional and
+// Range requests of large CABs
+type Server struct {
+	storage Storage
+	config  ServerConfig
+
+	mu      sync.Mutex
+	objects map[string]cachedObject // CABs of storages without RangeStorage, by key
+}
+
+// cachedObject describes a CAB of a storage without RangeStorage until it expires
+type cachedObject struct {
+	info    ObjectInfo
+	expires time.Time
+}
+
+// NewServer creates a server of the repository in storage
+func NewServer(storage Storage, config ServerConfig) *Server {
+	if config.Title == "" {
+		config.Title = "Firmirror"
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	return &Server{storage: storage, config: config, objects: make(map[string]cachedObject)}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Path == RemoteConfPath {
+		s.serveRemoteConf(w, r)
+		return
+	}
+
+	// Cleaning the rooted path drops the .. segments
+	key := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if key == "" || hiddenKey(key) {
+		http.NotFound(w, r)
+		return
+	}
+
+	err := s.serveKey(w, r, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Keys such as directories exist without holding a file
+		http.NotFound(w, r)
+	} else if err != nil {
+		slog.Error("Failed to serve file", "key", key, "error", err)
+		http.Error(w, "failed to read from storage", http.StatusBadGateway)
+	}
+}
+
+// hiddenKey reports whether the key is a hidden file, such as a temporary file of an upload,
+// or one of the internalKeys
+func hiddenKey(key string) bool {
+	if slices.Contains(internalKeys, key) {
+		return true
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// serveKey writes the data stored with the key, or the part of it requested
+func (s *Server) serveKey(w http.ResponseWriter, r *http.Request, key string) error {
+	exists, err := s.storage.Exists(r.Context(), key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", contentType(key))
+	if isMetadataKey(key) {
+		// Clients must revalidate the metadata, which changes on every refresh
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	storage, ok := s.storage.(RangeStorage)
+	if !ok {
+		storage = streamStorage{s}
+	}
+
+	info, err := storage.Stat(r.Context(), key)
+	if err != nil {
+		return err
+	}
+	if info.ETag != "" {
+		w.Header().Set("ETag", info.ETag)
+	}
+
+	content := &rangeReader{ctx: r.Context(), storage: storage, key: key, size: info.Size}
+	defer content.Close()
+	http.ServeContent(w, r, "", info.ModTime, content)
+	return nil
+}
+
+// streamStorage serves the objects of a storage without RangeStorage, which can only read
+// whole objects: they are described by hashing them, and ranges are read by skipping the
+// start of the objects
+type streamStorage struct {
+	server *Server
+}
+
+// Stat describes the object stored with the given key. As CABs are seldom overwritten, their
+// description is kept for objectCacheTTL, so that HEAD and conditional requests don't read
+// them again. The objects have no modification time, clients revalidate them with the ETag.
+func (s streamStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	s.server.mu.Lock()
+	cached, ok := s.server.objects[key]
+	s.server.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.info, nil
+	}
+
+	reader, err := s.server.storage.Read(ctx, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer reader.Close()
+
+	counter := &countingReader{Reader: reader}
+	digest, err := sha256Hex(counter)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info := ObjectInfo{Size: counter.n, ETag: `"` + digest + `"`}
+
+	// The metadata changes on every refresh and is small enough to be hashed every time
+	if !isMetadataKey(key) {
+		s.server.cacheObject(key, info)
+	}
+	return info, nil
+}
+
+// cacheObject keeps the description of a CAB for objectCacheTTL, dropping the expired ones
+// when the cache is full, or else all of them
+func (s *Server) cacheObject(key string, info ObjectInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if len(s.objects) >= objectCacheSize {
+		for k, cached := range s.objects {
+			if !now.Before(cached.expires) {
+				delete(s.objects, k)
+			}
+		}
+		if len(s.objects) >= objectCacheSize {
+			clear(s.objects)
+		}
+	}
+	s.objects[key] = cachedObject{info: info, expires: now.Add(objectCacheTTL)}
+}
+
+// ReadRange retrieves length bytes from offset of the object, reading it from the start
+func (s streamStorage) ReadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	reader, err := s.server.storage.Read(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, reader, offset); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(reader, length), reader}, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// serveRemoteConf writes the fwupd remote configuration pointing to the metadata. The URL of
+// the metadata is never derived from the Host of the requests, which clients can forge to
+// point the configuration cached by a proxy to another server.
+func (s *Server) serveRemoteConf(w http.ResponseWriter, r *http.Request) {
+	baseURL := s.config.BaseURL
+	if baseURL == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	conf := fmt.Sprintf(`[fwupd Remote]
+Enabled=true
+Title=%s
+MetadataURI=%s/%s
+ApprovalRequired=false
+`, s.config.Title, baseURL, metadataKey)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, "", time.Time{}, strings.NewReader(conf))
+}
+
+// rangeReader reads an object of a RangeStorage from the offset it is seeked to, only
+// downloading the parts http.ServeContent reads
+type rangeReader struct {
+	ctx     context.Context
+	storage RangeStorage
+	key     string
+	size    int64
+
+	offset int64
+	body   io.ReadCloser // Object from offset, opened on the first read
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		body, err := r.storage.ReadRange(r.ctx, r.key, r.offset, r.size-r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if errors.Is(err, io.EOF) && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if offset != r.offset {
+		r.Close()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+// Close releases the object being read, if any
+func (r *rangeReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
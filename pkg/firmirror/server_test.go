@@ -0,0 +1,160 @@
+package firmirror
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.TODO()
+	storage := newLocalStorage(t)
+	require.NoError(t, storage.Write(ctx, "dell/firmware.bin.cab", strings.NewReader("0123456789")))
+	require.NoError(t, storage.Write(ctx, metadataKey, strings.NewReader("metadata")))
+	require.NoError(t, storage.Write(ctx, ".metadata.xml.zst.1234.tmp", strings.NewReader("partial")))
+	require.NoError(t, storage.Write(ctx, quarantineKey, strings.NewReader("quarantine")))
+	require.NoError(t, storage.Write(ctx, pendingKey, strings.NewReader("{}")))
+
+	request := func(handler http.Handler, method, target string, header http.Header) *http.Response {
+		req := httptest.NewRequest(method, target, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Result()
+	}
+	body := func(t *testing.T, resp *http.Response) string {
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		storage Storage
+	}{
+		{name: "RangeStorage", storage: storage},
+		{name: "Storage", storage: struct{ Storage }{storage}}, // Hides Stat and ReadRange
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(tt.storage, ServerConfig{})
+
+			resp := request(server, http.MethodGet, "/dell/firmware.bin.cab", nil)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "0123456789", body(t, resp))
+			assert.Equal(t, "application/vnd.ms-cab-compressed", resp.Header.Get("Content-Type"))
+			assert.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+			etag := resp.Header.Get("ETag")
+			require.NotEmpty(t, etag, "Responses should have an ETag")
+
+			resp = request(server, http.MethodGet, "/dell/firmware.bin.cab", http.Header{"Range": {"bytes=2-4"}})
+			require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+			assert.Equal(t, "234", body(t, resp), "Only the range should be sent")
+			assert.Equal(t, "bytes 2-4/10", resp.Header.Get("Content-Range"))
+
+			resp = request(server, http.MethodGet, "/dell/firmware.bin.cab", http.Header{"Range": {"bytes=-3"}})
+			require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+			assert.Equal(t, "789", body(t, resp), "Suffix range should be sent")
+
+			resp = request(server, http.MethodGet, "/dell/firmware.bin.cab", http.Header{"If-None-Match": {etag}})
+			assert.Equal(t, http.StatusNotModified, resp.StatusCode, "Unchanged files should not be sent again")
+
+			resp = request(server, http.MethodHead, "/metadata.xml.zst", nil)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Empty(t, body(t, resp))
+			assert.Equal(t, "8", resp.Header.Get("Content-Length"))
+			assert.Equal(t, "application/zstd", resp.Header.Get("Content-Type"))
+			assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"), "Metadata should be revalidated")
+		})
+	}
+
+	t.Run("CachesCABs", func(t *testing.T) {
+		counting := &countingStorage{Storage: storage}
+		server := NewServer(struct{ Storage }{counting}, ServerConfig{})
+
+		resp := request(server, http.MethodHead, "/dell/firmware.bin.cab", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "10", resp.Header.Get("Content-Length"))
+		etag := resp.Header.Get("ETag")
+		require.Equal(t, 1, counting.reads, "The CAB should be hashed on its first request")
+
+		resp = request(server, http.MethodHead, "/dell/firmware.bin.cab", nil)
+		assert.Equal(t, etag, resp.Header.Get("ETag"))
+		resp = request(server, http.MethodGet, "/dell/firmware.bin.cab", http.Header{"If-None-Match": {etag}})
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+		assert.Equal(t, 1, counting.reads, "HEAD and 304 responses should not read the CAB again")
+
+		resp = request(server, http.MethodGet, "/dell/firmware.bin.cab", nil)
+		assert.Equal(t, "0123456789", body(t, resp))
+		assert.Equal(t, 2, counting.reads)
+		assert.Empty(t, resp.Header.Get("Last-Modified"), "No modification time should be made up")
+
+		// Overwritten CABs are described again once their description expires
+		require.NoError(t, storage.Write(ctx, "dell/firmware.bin.cab", strings.NewReader("abcdef")))
+		server.objects["dell/firmware.bin.cab"] = cachedObject{info: server.objects["dell/firmware.bin.cab"].info, expires: time.Now()}
+		resp = request(server, http.MethodGet, "/dell/firmware.bin.cab", nil)
+		assert.Equal(t, "abcdef", body(t, resp))
+		assert.NotEqual(t, etag, resp.Header.Get("ETag"), "Overwritten CAB should have a new ETag")
+		require.NoError(t, storage.Write(ctx, "dell/firmware.bin.cab", strings.NewReader("0123456789")))
+	})
+
+	t.Run("BoundsCache", func(t *testing.T) {
+		server := NewServer(struct{ Storage }{storage}, ServerConfig{})
+		for i := range objectCacheSize + 1 {
+			server.cacheObject(fmt.Sprintf("dell/%d.cab", i), ObjectInfo{})
+		}
+		assert.LessOrEqual(t, len(server.objects), objectCacheSize, "Cache should be bounded")
+		assert.Contains(t, server.objects, fmt.Sprintf("dell/%d.cab", objectCacheSize), "Latest CAB should be cached")
+	})
+
+	t.Run("LastModified", func(t *testing.T) {
+		resp := request(NewServer(storage, ServerConfig{}), http.MethodGet, "/dell/firmware.bin.cab", nil)
+		lastModified := resp.Header.Get("Last-Modified")
+		require.NotEmpty(t, lastModified)
+
+		resp = request(NewServer(storage, ServerConfig{}), http.MethodGet, "/dell/firmware.bin.cab", http.Header{"If-Modified-Since": {lastModified}})
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		server := NewServer(storage, ServerConfig{})
+		for _, target := range []string{"/", "/missing.cab", "/.metadata.xml.zst.1234.tmp", "/../dell/firmware.bin.cab/..", "/" + quarantineKey, "/" + pendingKey} {
+			assert.Equal(t, http.StatusNotFound, request(server, http.MethodGet, target, nil).StatusCode, target)
+		}
+		assert.Equal(t, http.StatusMethodNotAllowed, request(server, http.MethodPut, "/metadata.xml.zst", nil).StatusCode)
+	})
+
+	t.Run("RemoteConf", func(t *testing.T) {
+		resp := request(NewServer(storage, ServerConfig{BaseURL: "https://firmware.example.com/lvfs/"}), http.MethodGet, "http://attacker.example.com"+RemoteConfPath, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		conf := body(t, resp)
+		assert.Contains(t, conf, "[fwupd Remote]\nEnabled=true\nTitle=Firmirror\n")
+		assert.Contains(t, conf, "MetadataURI=https://firmware.example.com/lvfs/metadata.xml.zst\n", "Base URL should be used, whatever the Host of the request")
+
+		resp = request(NewServer(storage, ServerConfig{BaseURL: "https://firmware.example.com", Title: "Internal"}), http.MethodGet, RemoteConfPath, nil)
+		assert.Contains(t, body(t, resp), "Title=Internal\n")
+
+		resp = request(NewServer(storage, ServerConfig{}), http.MethodGet, "http://mirror.example.com:8080"+RemoteConfPath, nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, "Configuration should not be derived from the request")
+	})
+}
+
+// countingStorage counts the reads of a storage
+type countingStorage struct {
+	Storage
+	reads int
+}
+
+func (s *countingStorage) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.reads++
+	return s.Storage.Read(ctx, key)
+}
@@ -2,7 +2,14 @@ package firmirror
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"path"
+	"strings"
+	"time"
 )
 
 // Interface for different storage backends
@@ -17,3 +24,148 @@ type Storage interface {
 	Exists(ctx context.Context, key string) (bool, error)
 }
 
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Size    int64
+	ModTime time.Time
+	ETag    string // Quoted entity tag of the content, empty if the backend has none
+}
+
+// RangeStorage is implemented by the storages able to describe an object and to read part of
+// it, so that Server answers conditional and Range requests without downloading whole objects
+type RangeStorage interface {
+	// Stat describes the object stored with the given key
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+
+	// ReadRange retrieves length bytes, which must be positive, from offset of the data stored
+	// with the given key
+	ReadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+}
+
+// ManagedStorage is implemented by the storages able to enumerate and remove their objects,
+// which all the backends of this package are, so that unused files can be cleaned up
+type ManagedStorage interface {
+	// List returns all keys with the given prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// Delete removes the data stored with the given key, succeeding if there is none
+	Delete(ctx context.Context, key string) error
+}
+
+// listKeys lists the keys of a storage with the given prefix, if it is a ManagedStorage
+func listKeys(ctx context.Context, storage Storage, prefix string) ([]string, error) {
+	managed, ok := storage.(ManagedStorage)
+	if !ok {
+		return nil, fmt.Errorf("%T cannot list its keys", storage)
+	}
+	return managed.List(ctx, prefix)
+}
+
+// deleteKey deletes a key from a storage, if it is a ManagedStorage
+func deleteKey(ctx context.Context, storage Storage, key string) error {
+	managed, ok := storage.(ManagedStorage)
+	if !ok {
+		return fmt.Errorf("%T cannot delete %s", storage, key)
+	}
+	return managed.Delete(ctx, key)
+}
+
+// modTimeETag returns an entity tag for the backends without one, changing with the file
+func modTimeETag(modTime time.Time, size int64) string {
+	return fmt.Sprintf(`"%x-%x"`, modTime.UnixNano(), size)
+}
+
+// quoteETag returns the entity tag of a backend quoted as HTTP expects it
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// limitedFile reads length bytes from offset of a file, closing it once done
+func limitedFile(file io.ReadSeekCloser, offset, length int64) (io.ReadCloser, error) {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, length), file}, nil
+}
+
+// contentTypes maps the extensions of the files of a repository to their media type
+var contentTypes = map[string]string{
+	".cab":  "application/vnd.ms-cab-compressed",
+	".jcat": "application/gzip", // gzip-compressed JSON
+	".xml":  "application/xml",
+	".zst":  "application/zstd",
+}
+
+// contentType returns the media type of the file stored with the given key
+func contentType(key string) string {
+	if contentType, ok := contentTypes[path.Ext(key)]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// isMetadataKey reports whether the key is one of the metadata files, which are replaced on
+// every refresh unlike the firmware
+func isMetadataKey(key string) bool {
+	return strings.HasPrefix(path.Base(key), "metadata.")
+}
+
+// ErrChecksumMismatch is returned when the data written to storage doesn't have the expected digest
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumWriter is implemented by the storages able to verify the data they write themselves,
+// without reading it back
+type ChecksumWriter interface {
+	// WriteChecksum stores data with the given key, failing with ErrChecksumMismatch if its
+	// sha256 differs from the given hex digest
+	WriteChecksum(ctx context.Context, key string, data io.Reader, sha256 string) error
+}
+
+// WriteVerified stores data with the given key and checks that the stored object has the given
+// hex sha256. Storages which don't implement ChecksumWriter are checked by reading the object
+// back, leaving it in place if it doesn't match.
+func WriteVerified(ctx context.Context, storage Storage, key string, data io.Reader, sha256 string) error {
+	if writer, ok := storage.(ChecksumWriter); ok {
+		return writer.WriteChecksum(ctx, key, data, sha256)
+	}
+
+	if err := storage.Write(ctx, key, data); err != nil {
+		return err
+	}
+
+	reader, err := storage.Read(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to read back %s: %w", key, err)
+	}
+	defer reader.Close()
+
+	digest, err := sha256Hex(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read back %s: %w", key, err)
+	}
+	return checkDigest(key, sha256, digest)
+}
+
+// sha256Hex returns the hex-encoded sha256 of the data
+func sha256Hex(data io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// checkDigest returns ErrChecksumMismatch if the actual sha256 of the key isn't the expected one
+func checkDigest(key, expected, actual string) error {
+	if !strings.EqualFold(expected, actual) {
+		return fmt.Errorf("%w for %s: expected sha256 %s, got %s", ErrChecksumMismatch, key, expected, actual)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package firmirror
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+const (
+	// azureBlockSize is the size of the blocks large CABs are uploaded with
+	azureBlockSize = 8 * 1024 * 1024
+	// azureConcurrency is the number of blocks uploaded in parallel
+	azureConcurrency = 4
+)
+
+// AzureBlobConfig configures the Azure Blob Storage backend. Authentication uses the account
+// key if set, else the SAS token if set, else the user-assigned managed identity if its client
+// ID is set, else the default Azure credential chain, which includes system-assigned managed
+// identities and workload identities.
+type AzureBlobConfig struct {
+	Account    string `json:"account,omitempty"`     // Storage account name
+	ServiceURL string `json:"service_url,omitempty"` // Defaults to https://<account>.blob.core.windows.net, set for Azurite
+	Container  string `json:"container"`
+	Prefix     string `json:"prefix,omitempty"` // Optional prefix for all keys
+	AccountKey string `json:"account_key,omitempty"`
+	SASToken   string `json:"sas_token,omitempty"`
+	ClientID   string `json:"client_id,omitempty"` // Client ID of a user-assigned managed identity
+}
+
+// containerURL returns the URL of the container, without credentials
+func (c AzureBlobConfig) containerURL() (string, error) {
+	serviceURL := c.ServiceURL
+	if serviceURL == "" {
+		if c.Account == "" {
+			return "", fmt.Errorf("account name or service URL is required")
+		}
+		serviceURL = "https://" + c.Account + ".blob.core.windows.net"
+	}
+
+	u, err := url.Parse(serviceURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid service URL: %w", err)
+	}
+	return u.JoinPath(c.Container).String(), nil
+}
+
+// AzureBlobStorage implements Storage interface for Azure Blob Storage
+type AzureBlobStorage struct {
+	client *container.Client
+	prefix string // optional prefix for all keys
+}
+
+// NewAzureBlobStorage creates an Azure Blob Storage backend and verifies the container is accessible
+func NewAzureBlobStorage(ctx context.Context, cfg AzureBlobConfig) (*AzureBlobStorage, error) {
+	if cfg.Container == "" {
+		return nil, fmt.Errorf("container name is required")
+	}
+
+	containerURL, err := cfg.containerURL()
+	if err != nil {
+		return nil, err
+	}
+
+	var client *container.Client
+	switch {
+	case cfg.AccountKey != "":
+		if cfg.Account == "" {
+			return nil, fmt.Errorf("account name is required with an account key")
+		}
+		cred, err := container.NewSharedKeyCredential(cfg.Account, cfg.AccountKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid account key: %w", err)
+		}
+		client, err = container.NewClientWithSharedKeyCredential(containerURL, cred, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure client: %w", err)
+		}
+	case cfg.SASToken != "":
+		client, err = container.NewClientWithNoCredential(containerURL+"?"+strings.TrimPrefix(cfg.SASToken, "?"), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure client: %w", err)
+		}
+	default:
+		var cred azcore.TokenCredential
+		if cfg.ClientID != "" {
+			cred, err = azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+				ID: azidentity.ClientID(cfg.ClientID),
+			})
+		} else {
+			cred, err = azidentity.NewDefaultAzureCredential(nil)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Azure credentials: %w", err)
+		}
+		client, err = container.NewClient(containerURL, cred, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure client: %w", err)
+		}
+	}
+
+	// Verify container exists, by listing it as container SAS tokens cannot read its properties
+	maxResults := int32(1)
+	pager := client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{MaxResults: &maxResults})
+	if _, err := pager.NextPage(ctx); err != nil {
+		return nil, fmt.Errorf("failed to access container %s: %w", cfg.Container, err)
+	}
+
+	return &AzureBlobStorage{
+		client: client,
+		prefix: cfg.Prefix,
+	}, nil
+}
+
+// buildKey constructs the full blob name with optional prefix
+func (s *AzureBlobStorage) buildKey(key string) string {
+	if s.prefix != "" {
+		return s.prefix + "/" + key
+	}
+	return key
+}
+
+// Write stores data with the given key to Azure, uploading it as blocks so that large
+// CABs are never held in memory
+func (s *AzureBlobStorage) Write(ctx context.Context, key string, data io.Reader) error {
+	_, err := s.client.NewBlockBlobClient(s.buildKey(key)).UploadStream(ctx, data, &blockblob.UploadStreamOptions{
+		BlockSize:   azureBlockSize,
+		Concurrency: azureConcurrency,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload to Azure: %w", err)
+	}
+
+	return nil
+}
+
+// Read retrieves data for the given key from Azure
+func (s *AzureBlobStorage) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.client.NewBlobClient(s.buildKey(key)).DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from Azure: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// Exists checks if a key exists in Azure
+func (s *AzureBlobStorage) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.client.NewBlobClient(s.buildKey(key)).GetProperties(ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check blob existence: %w", err)
+	}
+	return true, nil
+}
+
+// Stat describes the blob stored with the given key in Azure
+func (s *AzureBlobStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	props, err := s.client.NewBlobClient(s.buildKey(key)).GetProperties(ctx, nil)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to describe blob: %w", err)
+	}
+
+	var info ObjectInfo
+	if props.ContentLength != nil {
+		info.Size = *props.ContentLength
+	}
+	if props.LastModified != nil {
+		info.ModTime = *props.LastModified
+	}
+	if props.ETag != nil {
+		info.ETag = quoteETag(string(*props.ETag))
+	}
+	return info, nil
+}
+
+// ReadRange retrieves part of the data for the given key from Azure
+func (s *AzureBlobStorage) ReadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	resp, err := s.client.NewBlobClient(s.buildKey(key)).DownloadStream(ctx, &blob.DownloadStreamOptions{
+		Range: blob.HTTPRange{Offset: offset, Count: length},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from Azure: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// List returns all keys with the given prefix (useful for debugging and management)
+func (s *AzureBlobStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	fullPrefix := s.buildKey(prefix)
+
+	var keys []string
+	pager := s.client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: &fullPrefix})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list blobs: %w", err)
+		}
+
+		for _, item := range page.Segment.BlobItems {
+			if item.Name != nil {
+				// Remove the storage prefix from the returned keys
+				keys = append(keys, strings.TrimPrefix(*item.Name, s.buildKey("")))
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// Delete removes the blob stored with the given key from Azure
+func (s *AzureBlobStorage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.NewBlobClient(s.buildKey(key)).Delete(ctx, nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return nil
+}
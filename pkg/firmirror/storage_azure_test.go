@@ -0,0 +1,192 @@
+package firmirror
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Well-known Azurite development account
+const (
+	azuriteAccount = "devstoreaccount1"
+	azuriteKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+func TestAzureBlobConfig_ContainerURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      AzureBlobConfig
+		expected string
+	}{
+		{
+			name:     "Account",
+			cfg:      AzureBlobConfig{Account: "firmirror", Container: "mirror"},
+			expected: "https://firmirror.blob.core.windows.net/mirror",
+		},
+		{
+			name:     "Azurite",
+			cfg:      AzureBlobConfig{Account: azuriteAccount, ServiceURL: "http://127.0.0.1:10000/devstoreaccount1/", Container: "mirror"},
+			expected: "http://127.0.0.1:10000/devstoreaccount1/mirror",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containerURL, err := tt.cfg.containerURL()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, containerURL, "Container URL should match")
+		})
+	}
+
+	t.Run("MissingAccount", func(t *testing.T) {
+		_, err := AzureBlobConfig{Container: "mirror"}.containerURL()
+		assert.ErrorContains(t, err, "account name or service URL is required")
+	})
+}
+
+func TestNewAzureBlobStorage_InvalidConfig(t *testing.T) {
+	_, err := NewAzureBlobStorage(context.TODO(), AzureBlobConfig{Account: "firmirror"})
+	assert.ErrorContains(t, err, "container name is required")
+
+	_, err = NewAzureBlobStorage(context.TODO(), AzureBlobConfig{ServiceURL: "http://127.0.0.1:10000/devstoreaccount1", Container: "mirror", AccountKey: azuriteKey})
+	assert.ErrorContains(t, err, "account name is required with an account key")
+}
+
+// newAzuriteContainer creates a fresh container in the Azurite instance given by AZURITE_BLOB_URL,
+// e.g. "http://127.0.0.1:10000/devstoreaccount1", and returns its configuration
+func newAzuriteContainer(t *testing.T) AzureBlobConfig {
+	serviceURL := os.Getenv("AZURITE_BLOB_URL")
+	if serviceURL == "" {
+		t.Skip("AZURITE_BLOB_URL not set, skipping Azurite integration tests")
+	}
+
+	cfg := AzureBlobConfig{
+		Account:    azuriteAccount,
+		ServiceURL: serviceURL,
+		Container:  "firmirror-" + uuid.NewString(),
+		AccountKey: azuriteKey,
+	}
+	containerURL, err := cfg.containerURL()
+	require.NoError(t, err)
+	cred, err := container.NewSharedKeyCredential(azuriteAccount, azuriteKey)
+	require.NoError(t, err)
+	client, err := container.NewClientWithSharedKeyCredential(containerURL, cred, nil)
+	require.NoError(t, err)
+
+	_, err = client.Create(context.TODO(), nil)
+	require.NoError(t, err, "Container should be created")
+	t.Cleanup(func() {
+		client.Delete(context.Background(), nil)
+	})
+
+	return cfg
+}
+
+func TestAzureBlobStorage_Azurite(t *testing.T) {
+	ctx := context.TODO()
+	cfg := newAzuriteContainer(t)
+
+	t.Run("MissingContainer", func(t *testing.T) {
+		missing := cfg
+		missing.Container = "missing"
+		_, err := NewAzureBlobStorage(ctx, missing)
+		assert.ErrorContains(t, err, "failed to access container missing")
+	})
+
+	t.Run("AccountKey", func(t *testing.T) {
+		storage, err := NewAzureBlobStorage(ctx, cfg)
+		require.NoError(t, err, "Storage should be created")
+
+		require.NoError(t, storage.Write(ctx, "metadata.xml.zst", strings.NewReader("metadata")))
+
+		reader, err := storage.Read(ctx, "metadata.xml.zst")
+		require.NoError(t, err, "Blob should be readable")
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "metadata", string(data), "Content should match")
+
+		exists, err := storage.Exists(ctx, "metadata.xml.zst")
+		require.NoError(t, err)
+		assert.True(t, exists, "Written blob should exist")
+
+		exists, err = storage.Exists(ctx, "missing.cab")
+		require.NoError(t, err)
+		assert.False(t, exists, "Missing blob should not exist")
+	})
+
+	t.Run("LargeBlob", func(t *testing.T) {
+		storage, err := NewAzureBlobStorage(ctx, cfg)
+		require.NoError(t, err, "Storage should be created")
+
+		// Spans several blocks
+		content := bytes.Repeat([]byte("firmware"), 3*azureBlockSize/8+1)
+		require.NoError(t, storage.Write(ctx, "large.cab", bytes.NewReader(content)))
+
+		reader, err := storage.Read(ctx, "large.cab")
+		require.NoError(t, err, "Blob should be readable")
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, content, data, "Content should match")
+	})
+
+	t.Run("ListAndDelete", func(t *testing.T) {
+		managed := cfg
+		managed.Prefix = "managed"
+		storage, err := NewAzureBlobStorage(ctx, managed)
+		require.NoError(t, err, "Storage should be created")
+		testManagedStorage(t, storage)
+	})
+
+	t.Run("SASToken", func(t *testing.T) {
+		cred, err := container.NewSharedKeyCredential(azuriteAccount, azuriteKey)
+		require.NoError(t, err)
+		values, err := sas.BlobSignatureValues{
+			Protocol:      sas.ProtocolHTTPSandHTTP,
+			ExpiryTime:    time.Now().Add(time.Hour),
+			Permissions:   (&sas.ContainerPermissions{Read: true, Create: true, Write: true, List: true}).String(),
+			ContainerName: cfg.Container,
+		}.SignWithSharedKey(cred)
+		require.NoError(t, err)
+
+		sasCfg := cfg
+		sasCfg.AccountKey = ""
+		sasCfg.SASToken = "?" + values.Encode()
+		storage, err := NewAzureBlobStorage(ctx, sasCfg)
+		require.NoError(t, err, "Storage should be created")
+
+		require.NoError(t, storage.Write(ctx, "sas.cab", strings.NewReader("cab")))
+		exists, err := storage.Exists(ctx, "sas.cab")
+		require.NoError(t, err)
+		assert.True(t, exists, "Written blob should exist")
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		prefixed := cfg
+		prefixed.Prefix = "mirror"
+		storage, err := NewAzureBlobStorage(ctx, prefixed)
+		require.NoError(t, err, "Storage should be created")
+		require.NoError(t, storage.Write(ctx, "firmware.bin.cab", strings.NewReader("cab")))
+
+		unprefixed, err := NewAzureBlobStorage(ctx, cfg)
+		require.NoError(t, err)
+		exists, err := unprefixed.Exists(ctx, "mirror/firmware.bin.cab")
+		require.NoError(t, err)
+		assert.True(t, exists, "Blob should be stored under the prefix")
+
+		keys, err := storage.List(ctx, "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"firmware.bin.cab"}, keys, "Keys should be relative to the prefix")
+	})
+}
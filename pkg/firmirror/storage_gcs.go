@@ -0,0 +1,156 @@
+package firmirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// GCSStorage implements Storage interface for Google Cloud Storage
+type GCSStorage struct {
+	client *storage.Client
+	bucket *storage.BucketHandle
+	prefix string // optional prefix for all keys
+}
+
+// NewGCSStorage creates a GCS storage backend using the Application Default Credentials.
+// A custom endpoint, such as a fake-gcs-server emulator, is used without authentication.
+func NewGCSStorage(ctx context.Context, bucket, prefix, endpoint string) (*GCSStorage, error) {
+	var opts []option.ClientOption
+	if endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint), option.WithoutAuthentication())
+	}
+
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	s, err := newGCSStorage(ctx, client, bucket, prefix)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func newGCSStorage(ctx context.Context, client *storage.Client, bucket, prefix string) (*GCSStorage, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("bucket name is required")
+	}
+
+	// Verify bucket exists
+	handle := client.Bucket(bucket)
+	if _, err := handle.Attrs(ctx); err != nil {
+		return nil, fmt.Errorf("failed to access bucket %s: %w", bucket, err)
+	}
+
+	return &GCSStorage{
+		client: client,
+		bucket: handle,
+		prefix: strings.Trim(prefix, "/"),
+	}, nil
+}
+
+// buildKey constructs the full object name with optional prefix
+func (s *GCSStorage) buildKey(key string) string {
+	if s.prefix != "" {
+		return s.prefix + "/" + key
+	}
+	return key
+}
+
+// Write stores data with the given key to GCS
+func (s *GCSStorage) Write(ctx context.Context, key string, data io.Reader) error {
+	writer := s.bucket.Object(s.buildKey(key)).NewWriter(ctx)
+	if _, err := io.Copy(writer, data); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to upload to GCS: %w", err)
+	}
+
+	// The object is only created once the writer is closed
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to upload to GCS: %w", err)
+	}
+
+	return nil
+}
+
+// Read retrieves data for the given key from GCS
+func (s *GCSStorage) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	reader, err := s.bucket.Object(s.buildKey(key)).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from GCS: %w", err)
+	}
+	return reader, nil
+}
+
+// Exists checks if a key exists in GCS
+func (s *GCSStorage) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.bucket.Object(s.buildKey(key)).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check object existence: %w", err)
+	}
+	return true, nil
+}
+
+// Stat describes the object stored with the given key in GCS
+func (s *GCSStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	attrs, err := s.bucket.Object(s.buildKey(key)).Attrs(ctx)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to describe object: %w", err)
+	}
+	return ObjectInfo{Size: attrs.Size, ModTime: attrs.Updated, ETag: quoteETag(attrs.Etag)}, nil
+}
+
+// ReadRange retrieves part of the data for the given key from GCS
+func (s *GCSStorage) ReadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	reader, err := s.bucket.Object(s.buildKey(key)).NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from GCS: %w", err)
+	}
+	return reader, nil
+}
+
+// List returns all keys with the given prefix (useful for debugging and management)
+func (s *GCSStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	it := s.bucket.Objects(ctx, &storage.Query{Prefix: s.buildKey(prefix)})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		// Remove the storage prefix from the returned keys
+		keys = append(keys, strings.TrimPrefix(attrs.Name, s.buildKey("")))
+	}
+
+	return keys, nil
+}
+
+// Delete removes the object stored with the given key from GCS
+func (s *GCSStorage) Delete(ctx context.Context, key string) error {
+	err := s.bucket.Object(s.buildKey(key)).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// Close releases the resources of the GCS client
+func (s *GCSStorage) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,96 @@
+package firmirror
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeGCSStorage returns a GCS storage backed by an in-process fake-gcs-server
+func newFakeGCSStorage(t *testing.T, prefix string) (*GCSStorage, *fakestorage.Server) {
+	server, err := fakestorage.NewServerWithOptions(fakestorage.Options{
+		InitialObjects: []fakestorage.Object{
+			{ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "firmirror", Name: "unrelated"}, Content: []byte("data")},
+		},
+		NoListener: true,
+	})
+	require.NoError(t, err, "Fake GCS server should start")
+	t.Cleanup(server.Stop)
+
+	storage, err := newGCSStorage(context.TODO(), server.Client(), "firmirror", prefix)
+	require.NoError(t, err, "GCS storage should be created")
+	return storage, server
+}
+
+func TestGCSStorage(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("MissingBucket", func(t *testing.T) {
+		_, server := newFakeGCSStorage(t, "")
+		_, err := newGCSStorage(ctx, server.Client(), "missing", "")
+		assert.ErrorContains(t, err, "failed to access bucket missing", "Missing bucket should be reported")
+
+		_, err = newGCSStorage(ctx, server.Client(), "", "")
+		assert.ErrorContains(t, err, "bucket name is required", "Empty bucket should be reported")
+	})
+
+	t.Run("WriteAndRead", func(t *testing.T) {
+		storage, _ := newFakeGCSStorage(t, "")
+
+		require.NoError(t, storage.Write(ctx, "metadata.xml.zst", strings.NewReader("metadata")))
+
+		reader, err := storage.Read(ctx, "metadata.xml.zst")
+		require.NoError(t, err, "Object should be readable")
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "metadata", string(data), "Content should match")
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		storage, _ := newFakeGCSStorage(t, "")
+		require.NoError(t, storage.Write(ctx, "firmware.bin.cab", strings.NewReader("cab")))
+
+		exists, err := storage.Exists(ctx, "firmware.bin.cab")
+		require.NoError(t, err)
+		assert.True(t, exists, "Written object should exist")
+
+		exists, err = storage.Exists(ctx, "missing.cab")
+		require.NoError(t, err)
+		assert.False(t, exists, "Missing object should not exist")
+	})
+
+	t.Run("ListAndDelete", func(t *testing.T) {
+		storage, _ := newFakeGCSStorage(t, "mirror")
+		testManagedStorage(t, storage)
+	})
+
+	t.Run("ReadMissing", func(t *testing.T) {
+		storage, _ := newFakeGCSStorage(t, "")
+		_, err := storage.Read(ctx, "missing.cab")
+		assert.ErrorContains(t, err, "failed to download from GCS", "Missing object should be reported")
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		storage, server := newFakeGCSStorage(t, "/mirror/")
+		require.NoError(t, storage.Write(ctx, "firmware.bin.cab", strings.NewReader("cab")))
+		require.NoError(t, storage.Write(ctx, "metadata.xml.zst", strings.NewReader("metadata")))
+
+		object, err := server.GetObject("firmirror", "mirror/firmware.bin.cab")
+		require.NoError(t, err, "Object should be stored under the prefix")
+		assert.Equal(t, "cab", string(object.Content), "Content should match")
+
+		keys, err := storage.List(ctx, "")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"firmware.bin.cab", "metadata.xml.zst"}, keys, "Keys should be relative to the prefix")
+
+		keys, err = storage.List(ctx, "firm")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"firmware.bin.cab"}, keys, "Keys should be filtered")
+	})
+}
@@ -2,10 +2,13 @@ package firmirror
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // LocalStorage implements Storage interface for local filesystem
@@ -25,6 +28,9 @@ func NewLocalStorage(basePath string) (*LocalStorage, error) {
 // Write stores data with the given key to the filesystem
 func (s *LocalStorage) Write(ctx context.Context, key string, data io.Reader) error {
 	fullPath := filepath.Join(s.basePath, key)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
 
 	file, err := os.Create(fullPath)
 	if err != nil {
@@ -39,6 +45,38 @@ func (s *LocalStorage) Write(ctx context.Context, key string, data io.Reader) er
 	return nil
 }
 
+// WriteChecksum stores data with the given key to the filesystem if its sha256 is the given
+// one. The data is written to a temporary file first, so a corrupted file never replaces the key.
+func (s *LocalStorage) WriteChecksum(ctx context.Context, key string, data io.Reader, sha256 string) error {
+	fullPath := filepath.Join(s.basePath, key)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(file.Name()) // No-op once renamed
+	defer file.Close()
+
+	digest, err := sha256Hex(io.TeeReader(data, file))
+	if err != nil {
+		return fmt.Errorf("failed to write data: %w", err)
+	}
+	if err := checkDigest(key, sha256, digest); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write data: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), fullPath); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	return nil
+}
+
 // Read retrieves data for the given key from the filesystem
 func (s *LocalStorage) Read(ctx context.Context, key string) (io.ReadCloser, error) {
 	fullPath := filepath.Join(s.basePath, key)
@@ -61,3 +99,55 @@ func (s *LocalStorage) Exists(ctx context.Context, key string) (bool, error) {
 	}
 	return true, nil
 }
+
+// List returns all keys with the given prefix in the filesystem
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(s.basePath, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		key, err := filepath.Rel(s.basePath, p)
+		if err != nil {
+			return err
+		}
+		if key = filepath.ToSlash(key); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return keys, nil
+}
+
+// Delete removes the file stored with the given key from the filesystem
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	err := os.Remove(filepath.Join(s.basePath, key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// Stat describes the file stored with the given key
+func (s *LocalStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := os.Stat(filepath.Join(s.basePath, key))
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		return ObjectInfo{}, fmt.Errorf("failed to stat file: %s is a directory: %w", key, fs.ErrNotExist)
+	}
+	return ObjectInfo{Size: info.Size(), ModTime: info.ModTime(), ETag: modTimeETag(info.ModTime(), info.Size())}, nil
+}
+
+// ReadRange retrieves part of the data for the given key from the filesystem
+func (s *LocalStorage) ReadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(s.basePath, key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return limitedFile(file, offset, length)
+}
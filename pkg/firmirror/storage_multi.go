@@ -0,0 +1,467 @@
+package firmirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ReplicationPolicy tells how a failed write to a replica is handled
+type ReplicationPolicy string
+
+const (
+	// PolicyFail fails the write, like a failure of the primary
+	PolicyFail ReplicationPolicy = "fail"
+	// PolicyWarn logs the failure and leaves the replica behind
+	PolicyWarn ReplicationPolicy = "warn"
+	// PolicyRetry logs the failure and copies the key from the primary on the next Retry
+	PolicyRetry ReplicationPolicy = "retry"
+)
+
+// ReplicationPolicies lists the valid replication policies
+var ReplicationPolicies = []ReplicationPolicy{PolicyFail, PolicyWarn, PolicyRetry}
+
+// pendingKey is the primary key listing the keys still to copy to each replica, so that
+// retries carry over to the next refresh. Like the other dot files, Server does not serve it.
+const pendingKey = ".replication.json"
+
+// Destination is a storage backend the repository is replicated to
+type Destination struct {
+	Name    string
+	Storage Storage
+	Policy  ReplicationPolicy
+}
+
+// DestinationStatus reports the writes to a destination
+type DestinationStatus struct {
+	Name      string
+	Policy    ReplicationPolicy
+	Written   int      // Keys written, including the successful retries
+	Failed    int      // Failed writes, including the failed retries
+	Pending   []string // Keys waiting for a retry
+	LastError error
+}
+
+// MultiStorage implements Storage interface by writing to a primary backend and replicating
+// the writes to other backends. Reads and existence checks only go to the primary, which
+// always has the latest repository.
+type MultiStorage struct {
+	primary  Storage
+	replicas []Destination
+
+	mu     sync.Mutex
+	status map[string]*DestinationStatus
+}
+
+// NewMultiStorage creates a storage replicating the writes to the primary to the replicas,
+// and loads the retries left over by the previous refresh
+func NewMultiStorage(ctx context.Context, primary Storage, replicas ...Destination) (*MultiStorage, error) {
+	s := &MultiStorage{
+		primary:  primary,
+		replicas: replicas,
+		status: map[string]*DestinationStatus{
+			"primary": {Name: "primary", Policy: PolicyFail},
+		},
+	}
+
+	for _, replica := range replicas {
+		if replica.Name == "" {
+			return nil, fmt.Errorf("replica name is required")
+		}
+		if _, ok := s.status[replica.Name]; ok {
+			return nil, fmt.Errorf("duplicate destination name %q", replica.Name)
+		}
+		if !slices.Contains(ReplicationPolicies, replica.Policy) {
+			return nil, fmt.Errorf("replica %s: unknown policy %q", replica.Name, replica.Policy)
+		}
+		s.status[replica.Name] = &DestinationStatus{Name: replica.Name, Policy: replica.Policy}
+	}
+
+	if err := s.loadPending(ctx); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// loadPending restores the retries saved by the previous refresh
+func (s *MultiStorage) loadPending(ctx context.Context) error {
+	exists, err := s.primary.Exists(ctx, pendingKey)
+	if err != nil || !exists {
+		return err
+	}
+
+	reader, err := s.primary.Read(ctx, pendingKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	var pending map[string][]string
+	if err := json.NewDecoder(reader).Decode(&pending); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", pendingKey, err)
+	}
+
+	for name, keys := range pending {
+		// Replicas removed from the configuration are forgotten
+		if status, ok := s.status[name]; ok && status.Policy == PolicyRetry {
+			status.Pending = keys
+		}
+	}
+	return nil
+}
+
+// Write stores data to the primary and the replicas in parallel. It fails if the primary
+// or a replica with the fail policy fails.
+func (s *MultiStorage) Write(ctx context.Context, key string, data io.Reader) error {
+	return s.write(key, data, func(storage Storage, data io.Reader) error {
+		return storage.Write(ctx, key, data)
+	})
+}
+
+// WriteChecksum stores data to the primary and the replicas like Write, verifying that each
+// destination stores the given sha256, see WriteVerified
+func (s *MultiStorage) WriteChecksum(ctx context.Context, key string, data io.Reader, sha256 string) error {
+	return s.write(key, data, func(storage Storage, data io.Reader) error {
+		return WriteVerified(ctx, storage, key, data, sha256)
+	})
+}
+
+// write stores data to every destination in parallel with the given function
+func (s *MultiStorage) write(key string, data io.Reader, write func(Storage, io.Reader) error) error {
+	source, size, cleanup, err := readerAt(data)
+	if err != nil {
+		return fmt.Errorf("failed to buffer data: %w", err)
+	}
+	defer cleanup()
+
+	destinations := append([]Destination{{Name: "primary", Storage: s.primary, Policy: PolicyFail}}, s.replicas...)
+	errs := make([]error, len(destinations))
+	var wg sync.WaitGroup
+	for i, destination := range destinations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = write(destination.Storage, io.NewSectionReader(source, 0, size))
+		}()
+	}
+	wg.Wait()
+
+	var failed []error
+	for i, destination := range destinations {
+		s.record(destination, key, errs[i])
+		if errs[i] != nil && destination.Policy == PolicyFail {
+			failed = append(failed, fmt.Errorf("%s: %w", destination.Name, errs[i]))
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// record updates the status of the destination after a write of the key
+func (s *MultiStorage) record(destination Destination, key string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status[destination.Name]
+	if err == nil {
+		status.Written++
+		// A later write supersedes the retry
+		status.Pending = slices.DeleteFunc(status.Pending, func(pending string) bool { return pending == key })
+		return
+	}
+
+	status.Failed++
+	status.LastError = err
+	switch destination.Policy {
+	case PolicyWarn:
+		slog.Warn("Failed to replicate", "destination", destination.Name, "key", key, "error", err)
+	case PolicyRetry:
+		slog.Warn("Failed to replicate, will retry", "destination", destination.Name, "key", key, "error", err)
+		if !slices.Contains(status.Pending, key) {
+			status.Pending = append(status.Pending, key)
+		}
+	}
+}
+
+// readerAt returns data as a reader at offsets, spooling it to a temporary file if it is not one
+// already, so that it can be read by each destination in parallel
+func readerAt(data io.Reader) (io.ReaderAt, int64, func(), error) {
+	if r, ok := data.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		start, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		return io.NewSectionReader(r, start, end-start), end - start, func() {}, nil
+	}
+
+	file, err := os.CreateTemp("", "firmirror-*")
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+
+	size, err := io.Copy(file, data)
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, err
+	}
+	return file, size, cleanup, nil
+}
+
+// Read retrieves data for the given key from the primary
+func (s *MultiStorage) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.primary.Read(ctx, key)
+}
+
+// Exists checks if a key exists in the primary
+func (s *MultiStorage) Exists(ctx context.Context, key string) (bool, error) {
+	return s.primary.Exists(ctx, key)
+}
+
+// List returns all keys with the given prefix in the primary
+func (s *MultiStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	return listKeys(ctx, s.primary, prefix)
+}
+
+// Delete removes the key from the primary and the replicas, cancelling its pending retries.
+// Like Write, it fails if the primary or a replica with the fail policy fails.
+func (s *MultiStorage) Delete(ctx context.Context, key string) error {
+	destinations := append([]Destination{{Name: "primary", Storage: s.primary, Policy: PolicyFail}}, s.replicas...)
+
+	var failed []error
+	for _, destination := range destinations {
+		s.mu.Lock()
+		status := s.status[destination.Name]
+		status.Pending = slices.DeleteFunc(status.Pending, func(pending string) bool { return pending == key })
+		s.mu.Unlock()
+
+		err := deleteKey(ctx, destination.Storage, key)
+		switch {
+		case err == nil:
+		case destination.Policy == PolicyFail:
+			failed = append(failed, fmt.Errorf("%s: %w", destination.Name, err))
+		default:
+			slog.Warn("Failed to delete from replica", "destination", destination.Name, "key", key, "error", err)
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// Retry copies the keys whose replication failed from the primary to the replicas with the
+// retry policy, then saves the ones still failing so that the next refresh retries them
+func (s *MultiStorage) Retry(ctx context.Context) error {
+	var errs []error
+	for _, replica := range s.replicas {
+		s.mu.Lock()
+		pending := slices.Clone(s.status[replica.Name].Pending)
+		s.mu.Unlock()
+
+		for _, key := range pending {
+			err := s.copy(ctx, replica, key)
+			s.record(replica, key, err)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", replica.Name, key, err))
+			}
+		}
+	}
+
+	if err := s.savePending(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to save pending replications: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// copy writes the key of the primary to the replica, verifying that the replica stores the
+// data read from the primary, see WriteVerified
+func (s *MultiStorage) copy(ctx context.Context, replica Destination, key string) error {
+	reader, err := s.primary.Read(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	hasher := sha256.New()
+	source, size, cleanup, err := readerAt(io.TeeReader(reader, hasher))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	digest := hex.EncodeToString(hasher.Sum(nil))
+	return WriteVerified(ctx, replica.Storage, key, io.NewSectionReader(source, 0, size), digest)
+}
+
+// savePending writes the keys still waiting for a retry to the primary
+func (s *MultiStorage) savePending(ctx context.Context) error {
+	s.mu.Lock()
+	pending := map[string][]string{}
+	for _, status := range s.status {
+		if len(status.Pending) > 0 {
+			pending[status.Name] = status.Pending
+		}
+	}
+	s.mu.Unlock()
+
+	if len(pending) == 0 {
+		exists, err := s.primary.Exists(ctx, pendingKey)
+		if err != nil || !exists {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.primary.Write(ctx, pendingKey, bytes.NewReader(data))
+}
+
+// Status returns the status of the primary then of each replica
+func (s *MultiStorage) Status() []DestinationStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := []DestinationStatus{*s.status["primary"]}
+	for _, replica := range s.replicas {
+		status := *s.status[replica.Name]
+		status.Pending = slices.Clone(status.Pending)
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// ReplicaConfig configures a replica in a replicas file. Exactly one backend must be set.
+type ReplicaConfig struct {
+	Name   string            `json:"name"`
+	Policy ReplicationPolicy `json:"policy"`
+
+	Local *struct {
+		Path string `json:"path"`
+	} `json:"local,omitempty"`
+	S3  *S3Config `json:"s3,omitempty"`
+	GCS *struct {
+		Bucket   string `json:"bucket"`
+		Prefix   string `json:"prefix,omitempty"`
+		Endpoint string `json:"endpoint,omitempty"`
+	} `json:"gcs,omitempty"`
+	Azure  *AzureBlobConfig `json:"azure,omitempty"`
+	SFTP   *SFTPConfig      `json:"sftp,omitempty"`
+	WebDAV *WebDAVConfig    `json:"webdav,omitempty"`
+}
+
+// LoadReplicas reads a JSON list of replicas from a local file and connects to them.
+// Environment variables such as ${MINIO_SECRET_KEY} are expanded in the string values, to
+// keep secrets out of the file, see expandEnv.
+func LoadReplicas(ctx context.Context, path string) ([]Destination, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replicas: %w", err)
+	}
+
+	// The values are expanded once parsed, so that the environment can't change the JSON
+	var values any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("failed to parse replicas: %w", err)
+	}
+	if data, err = json.Marshal(expandValues(values)); err != nil {
+		return nil, err
+	}
+	var configs []ReplicaConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse replicas: %w", err)
+	}
+
+	var replicas []Destination
+	for i, cfg := range configs {
+		storage, err := cfg.newStorage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("replica %d: %w", i, err)
+		}
+		replicas = append(replicas, Destination{Name: cfg.Name, Storage: storage, Policy: cfg.Policy})
+	}
+	return replicas, nil
+}
+
+// envPattern matches the ${VAR} references of replicas files, and their $${VAR} escapes
+var envPattern = regexp.MustCompile(`\$?\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+// expandEnv replaces the ${VAR} references of s with the value of the environment variables.
+// Only this syntax is expanded, so that other "$" such as the ones of passwords are kept, and
+// $${VAR} stands for a literal ${VAR}.
+func expandEnv(s string) string {
+	return envPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		return os.Getenv(ref[2 : len(ref)-1])
+	})
+}
+
+// expandValues expands the environment variables of the strings of a parsed JSON value
+func expandValues(value any) any {
+	switch value := value.(type) {
+	case string:
+		return expandEnv(value)
+	case []any:
+		for i := range value {
+			value[i] = expandValues(value[i])
+		}
+	case map[string]any:
+		for key := range value {
+			value[key] = expandValues(value[key])
+		}
+	}
+	return value
+}
+
+// newStorage creates the backend of the replica
+func (cfg ReplicaConfig) newStorage(ctx context.Context) (Storage, error) {
+	// Checked before connecting, so that no client is left behind
+	set := 0
+	for _, backend := range []bool{cfg.Local != nil, cfg.S3 != nil, cfg.GCS != nil, cfg.Azure != nil, cfg.SFTP != nil, cfg.WebDAV != nil} {
+		if backend {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("exactly one storage backend is required, got %d", set)
+	}
+
+	switch {
+	case cfg.Local != nil:
+		return NewLocalStorage(cfg.Local.Path)
+	case cfg.S3 != nil:
+		return NewS3Storage(ctx, *cfg.S3)
+	case cfg.GCS != nil:
+		return NewGCSStorage(ctx, cfg.GCS.Bucket, cfg.GCS.Prefix, cfg.GCS.Endpoint)
+	case cfg.Azure != nil:
+		return NewAzureBlobStorage(ctx, *cfg.Azure)
+	case cfg.SFTP != nil:
+		return NewSFTPStorage(ctx, *cfg.SFTP)
+	default:
+		return NewWebDAVStorage(ctx, *cfg.WebDAV)
+	}
+}
@@ -0,0 +1,249 @@
+package firmirror
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unreliableStorage wraps a storage whose writes fail while down is set
+type unreliableStorage struct {
+	Storage
+	down bool
+}
+
+func (s *unreliableStorage) Write(ctx context.Context, key string, data io.Reader) error {
+	if s.down {
+		return errors.New("connection refused")
+	}
+	return s.Storage.Write(ctx, key, data)
+}
+
+func newLocalStorage(t *testing.T) *LocalStorage {
+	storage, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+	return storage
+}
+
+func readKey(t *testing.T, storage Storage, key string) string {
+	reader, err := storage.Read(context.TODO(), key)
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestMultiStorage(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("InvalidDestinations", func(t *testing.T) {
+		primary := newLocalStorage(t)
+		_, err := NewMultiStorage(ctx, primary, Destination{Name: "minio", Storage: newLocalStorage(t), Policy: "ignore"})
+		assert.ErrorContains(t, err, `unknown policy "ignore"`)
+
+		_, err = NewMultiStorage(ctx, primary, Destination{Name: "primary", Storage: newLocalStorage(t), Policy: PolicyWarn})
+		assert.ErrorContains(t, err, `duplicate destination name "primary"`)
+
+		_, err = NewMultiStorage(ctx, primary, Destination{Storage: newLocalStorage(t), Policy: PolicyWarn})
+		assert.ErrorContains(t, err, "replica name is required")
+	})
+
+	t.Run("FansOutWrites", func(t *testing.T) {
+		primary, eu, us := newLocalStorage(t), newLocalStorage(t), newLocalStorage(t)
+		storage, err := NewMultiStorage(ctx, primary,
+			Destination{Name: "eu", Storage: eu, Policy: PolicyFail},
+			Destination{Name: "us", Storage: us, Policy: PolicyFail},
+		)
+		require.NoError(t, err)
+
+		// Streams are read once whatever the number of destinations
+		require.NoError(t, storage.Write(ctx, "firmware.bin.cab", io.MultiReader(strings.NewReader("firm"), strings.NewReader("ware"))))
+		for _, destination := range []Storage{primary, eu, us} {
+			assert.Equal(t, "firmware", readKey(t, destination, "firmware.bin.cab"), "Every destination should be written")
+		}
+
+		assert.Equal(t, "firmware", readKey(t, storage, "firmware.bin.cab"), "Reads should go to the primary")
+		exists, err := storage.Exists(ctx, "firmware.bin.cab")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		for _, status := range storage.Status() {
+			assert.Equal(t, 1, status.Written, "%s should report the write", status.Name)
+			assert.Zero(t, status.Failed, "%s should report no failure", status.Name)
+		}
+	})
+
+	t.Run("PolicyFail", func(t *testing.T) {
+		primary := newLocalStorage(t)
+		storage, err := NewMultiStorage(ctx, primary,
+			Destination{Name: "eu", Storage: &unreliableStorage{Storage: newLocalStorage(t), down: true}, Policy: PolicyFail},
+		)
+		require.NoError(t, err)
+
+		err = storage.Write(ctx, "firmware.bin.cab", strings.NewReader("firmware"))
+		assert.ErrorContains(t, err, "eu: connection refused", "Failing replica should fail the write")
+	})
+
+	t.Run("PolicyWarn", func(t *testing.T) {
+		primary := newLocalStorage(t)
+		storage, err := NewMultiStorage(ctx, primary,
+			Destination{Name: "eu", Storage: &unreliableStorage{Storage: newLocalStorage(t), down: true}, Policy: PolicyWarn},
+		)
+		require.NoError(t, err)
+
+		require.NoError(t, storage.Write(ctx, "firmware.bin.cab", strings.NewReader("firmware")), "Failing replica should only be logged")
+		require.NoError(t, storage.Retry(ctx))
+
+		status := storage.Status()[1]
+		assert.Equal(t, "eu", status.Name)
+		assert.Equal(t, 1, status.Failed, "Failure should be reported")
+		assert.EqualError(t, status.LastError, "connection refused")
+		assert.Empty(t, status.Pending, "Failure should not be retried")
+	})
+
+	t.Run("PolicyRetry", func(t *testing.T) {
+		primary := newLocalStorage(t)
+		eu := &unreliableStorage{Storage: newLocalStorage(t), down: true}
+		storage, err := NewMultiStorage(ctx, primary, Destination{Name: "eu", Storage: eu, Policy: PolicyRetry})
+		require.NoError(t, err)
+
+		require.NoError(t, storage.Write(ctx, "firmware.bin.cab", strings.NewReader("firmware")))
+		require.NoError(t, storage.Write(ctx, "metadata.xml.zst", strings.NewReader("metadata")))
+		assert.Equal(t, []string{"firmware.bin.cab", "metadata.xml.zst"}, storage.Status()[1].Pending, "Failures should be queued")
+
+		// Still down at the end of the refresh, the retries are saved for the next one
+		assert.ErrorContains(t, storage.Retry(ctx), "eu: firmware.bin.cab: connection refused")
+		assert.Contains(t, readKey(t, primary, pendingKey), "firmware.bin.cab", "Pending keys should be saved")
+
+		eu.down = false
+		next, err := NewMultiStorage(ctx, primary, Destination{Name: "eu", Storage: eu, Policy: PolicyRetry})
+		require.NoError(t, err)
+		assert.Len(t, next.Status()[1].Pending, 2, "Pending keys should be loaded")
+
+		require.NoError(t, next.Retry(ctx))
+		assert.Equal(t, "firmware", readKey(t, eu, "firmware.bin.cab"), "Key should be copied from the primary")
+		assert.Equal(t, "metadata", readKey(t, eu, "metadata.xml.zst"), "Key should be copied from the primary")
+
+		status := next.Status()[1]
+		assert.Equal(t, 2, status.Written, "Retries should be reported")
+		assert.Empty(t, status.Pending, "Retries should be done")
+		assert.Equal(t, "{}", readKey(t, primary, pendingKey), "Pending keys should be cleared")
+	})
+
+	t.Run("ListAndDelete", func(t *testing.T) {
+		testManagedStorage(t, func() *MultiStorage {
+			storage, err := NewMultiStorage(ctx, newLocalStorage(t), Destination{Name: "eu", Storage: newLocalStorage(t), Policy: PolicyFail})
+			require.NoError(t, err)
+			return storage
+		}())
+
+		primary, eu := newLocalStorage(t), newLocalStorage(t)
+		us := &unreliableStorage{Storage: newLocalStorage(t), down: true}
+		storage, err := NewMultiStorage(ctx, primary,
+			Destination{Name: "eu", Storage: eu, Policy: PolicyFail},
+			Destination{Name: "us", Storage: us, Policy: PolicyRetry},
+		)
+		require.NoError(t, err)
+		require.NoError(t, storage.Write(ctx, "firmware.bin.cab", strings.NewReader("firmware")))
+
+		require.NoError(t, storage.Delete(ctx, "firmware.bin.cab"), "Failing replica without the fail policy should only be logged")
+		for _, destination := range []Storage{primary, eu} {
+			exists, err := destination.Exists(ctx, "firmware.bin.cab")
+			require.NoError(t, err)
+			assert.False(t, exists, "Key should be deleted from every destination")
+		}
+		assert.Empty(t, storage.Status()[2].Pending, "Deleted key should not be retried")
+	})
+
+	t.Run("RetryVerifiesCopies", func(t *testing.T) {
+		primary := newLocalStorage(t)
+		eu := &unreliableStorage{Storage: corruptingStorage{struct{ Storage }{newLocalStorage(t)}}, down: true}
+		storage, err := NewMultiStorage(ctx, primary, Destination{Name: "eu", Storage: eu, Policy: PolicyRetry})
+		require.NoError(t, err)
+		require.NoError(t, storage.Write(ctx, "firmware.bin.cab", strings.NewReader("firmware")))
+
+		eu.down = false
+		err = storage.Retry(ctx)
+		assert.ErrorIs(t, err, ErrChecksumMismatch, "Copies should be checked against the primary")
+		assert.Equal(t, []string{"firmware.bin.cab"}, storage.Status()[1].Pending, "Corrupted copy should be retried")
+	})
+
+	t.Run("PrimaryFailure", func(t *testing.T) {
+		eu := newLocalStorage(t)
+		storage, err := NewMultiStorage(ctx, &unreliableStorage{Storage: newLocalStorage(t), down: true},
+			Destination{Name: "eu", Storage: eu, Policy: PolicyWarn},
+		)
+		require.NoError(t, err)
+
+		err = storage.Write(ctx, "firmware.bin.cab", strings.NewReader("firmware"))
+		assert.ErrorContains(t, err, "primary: connection refused", "Failing primary should fail the write")
+	})
+}
+
+func writeReplicas(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "replicas.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644), "Should write replicas file")
+	return path
+}
+
+func TestLoadReplicas(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Valid", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("REPLICA_DIR", dir)
+
+		replicas, err := LoadReplicas(ctx, writeReplicas(t, `[
+			{"name": "nfs", "policy": "retry", "local": {"path": "${REPLICA_DIR}/nfs"}},
+			{"name": "archive", "policy": "warn", "local": {"path": "${REPLICA_DIR}/archive"}}
+		]`))
+		require.NoError(t, err)
+		require.Len(t, replicas, 2)
+		assert.Equal(t, "nfs", replicas[0].Name)
+		assert.Equal(t, PolicyRetry, replicas[0].Policy)
+		assert.Equal(t, filepath.Join(dir, "nfs"), replicas[0].Storage.(*LocalStorage).basePath, "Environment variables should be expanded")
+		assert.Equal(t, PolicyWarn, replicas[1].Policy)
+	})
+
+	t.Run("ExpandsOnlyReferences", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("REPLICA_DIR", dir)
+		t.Setenv("INJECTED", `", "gcs": {"bucket": "firmware`)
+
+		replicas, err := LoadReplicas(ctx, writeReplicas(t, `[
+			{"name": "a", "policy": "warn", "local": {"path": "${REPLICA_DIR}/pa$$word$HOME"}},
+			{"name": "b", "policy": "warn", "local": {"path": "${REPLICA_DIR}/$${REPLICA_DIR}"}},
+			{"name": "c", "policy": "warn", "local": {"path": "${REPLICA_DIR}/${INJECTED}"}}
+		]`))
+		require.NoError(t, err, "Values should not be able to change the JSON")
+		require.Len(t, replicas, 3)
+		assert.Equal(t, filepath.Join(dir, "pa$$word$HOME"), replicas[0].Storage.(*LocalStorage).basePath, "Other $ should be kept")
+		assert.Equal(t, filepath.Join(dir, "${REPLICA_DIR}"), replicas[1].Storage.(*LocalStorage).basePath, "Escaped references should be kept")
+		assert.Equal(t, filepath.Join(dir, `", "gcs": {"bucket": "firmware`), replicas[2].Storage.(*LocalStorage).basePath)
+	})
+
+	t.Run("NoBackend", func(t *testing.T) {
+		_, err := LoadReplicas(ctx, writeReplicas(t, `[{"name": "nfs", "policy": "warn"}]`))
+		assert.ErrorContains(t, err, "replica 0: exactly one storage backend is required, got 0")
+	})
+
+	t.Run("SeveralBackends", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "nfs")
+		_, err := LoadReplicas(ctx, writeReplicas(t, `[{"name": "nfs", "policy": "warn", "local": {"path": "`+dir+`"}, "gcs": {"bucket": "firmware"}}]`))
+		assert.ErrorContains(t, err, "replica 0: exactly one storage backend is required, got 2")
+		assert.NoDirExists(t, dir, "No backend should be created before the configuration is checked")
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		_, err := LoadReplicas(ctx, filepath.Join(t.TempDir(), "missing.json"))
+		assert.ErrorContains(t, err, "failed to read replicas")
+	})
+}
@@ -1,37 +1,84 @@
 package firmirror
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"path"
+	"slices"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+const (
+	// sha256MetadataKey is the user metadata holding the sha256 of the objects, sent as
+	// the x-amz-meta-sha256 header
+	sha256MetadataKey = "sha256"
+	// s3PartSize is the size of the parts uploads are split in. Streams of unknown size are
+	// buffered one part at a time, so it bounds memory use along with s3Concurrency.
+	s3PartSize = 16 * 1024 * 1024
+	// s3Concurrency is the number of parts uploaded in parallel
+	s3Concurrency = 4
+)
+
+// S3Config configures the S3 storage backend
+type S3Config struct {
+	Bucket   string `json:"bucket"`
+	Prefix   string `json:"prefix,omitempty"` // Optional prefix for all keys
+	Region   string `json:"region,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"` // Custom endpoint for S3-compatible services like MinIO
+
+	// Static credentials, the default AWS credentials are used if empty
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+
+	StorageClass         string `json:"storage_class,omitempty"`          // Storage class of the CABs, e.g. STANDARD_IA, defaults to the bucket's
+	KMSKeyID             string `json:"kms_key_id,omitempty"`             // KMS key to encrypt the objects with, disabling SSE-KMS if empty
+	MetadataCacheControl string `json:"metadata_cache_control,omitempty"` // Cache-Control of the metadata files, which change on every refresh
+}
+
 // S3Storage implements Storage interface for AWS S3 or S3-compatible storage
 type S3Storage struct {
-	client     *s3.Client
-	uploader   *manager.Uploader
-	downloader *manager.Downloader
-	bucket     string
-	prefix     string // optional prefix for all keys
+	client   *s3.Client
+	uploader *manager.Uploader
+	bucket   string
+	prefix   string // optional prefix for all keys
+
+	storageClass         types.StorageClass
+	kmsKeyID             string
+	metadataCacheControl string
 }
 
-func NewS3Storage(ctx context.Context, bucket, prefix, region, endpoint string) (*S3Storage, error) {
-	if bucket == "" {
+func NewS3Storage(ctx context.Context, cfg S3Config) (*S3Storage, error) {
+	if cfg.Bucket == "" {
 		return nil, fmt.Errorf("bucket name is required")
 	}
 
+	storageClass := types.StorageClass(cfg.StorageClass)
+	if storageClass != "" && !slices.Contains(storageClass.Values(), storageClass) {
+		return nil, fmt.Errorf("unknown storage class %q", cfg.StorageClass)
+	}
+
 	var opts []func(*config.LoadOptions) error
 
-	if region != "" {
-		opts = append(opts, config.WithRegion(region))
+	if cfg.Region != "" {
+		opts = append(opts, config.WithRegion(cfg.Region))
+	}
+	if cfg.AccessKeyID != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		))
 	}
 
 	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
@@ -40,24 +87,30 @@ func NewS3Storage(ctx context.Context, bucket, prefix, region, endpoint string)
 	}
 
 	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
-		if endpoint != "" {
-			o.BaseEndpoint = aws.String(endpoint)
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
 			o.UsePathStyle = true
 			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+			o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
 		}
 	})
 
 	// Verify bucket exists
-	if _, err = client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)}); err != nil {
-		return nil, fmt.Errorf("failed to access bucket %s: %w", bucket, err)
+	if _, err = client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(cfg.Bucket)}); err != nil {
+		return nil, fmt.Errorf("failed to access bucket %s: %w", cfg.Bucket, err)
 	}
 
 	return &S3Storage{
-		client:     client,
-		uploader:   manager.NewUploader(client),
-		downloader: manager.NewDownloader(client),
-		bucket:     bucket,
-		prefix:     prefix,
+		client: client,
+		uploader: manager.NewUploader(client, func(u *manager.Uploader) {
+			u.PartSize = s3PartSize
+			u.Concurrency = s3Concurrency
+		}),
+		bucket:               cfg.Bucket,
+		prefix:               cfg.Prefix,
+		storageClass:         storageClass,
+		kmsKeyID:             cfg.KMSKeyID,
+		metadataCacheControl: cfg.MetadataCacheControl,
 	}, nil
 }
 
@@ -69,45 +122,164 @@ func (s *S3Storage) buildKey(key string) string {
 	return key
 }
 
-// Write stores data with the given key to S3
+// Write streams data with the given key to S3, as a multipart upload for large objects.
+// Seekable data such as files is uploaded straight from the source, other data is buffered
+// one part at a time, so memory use does not depend on the object size.
 func (s *S3Storage) Write(ctx context.Context, key string, data io.Reader) error {
-	fullKey := s.buildKey(key)
+	return s.write(ctx, key, data, "")
+}
+
+// WriteChecksum streams data with the given key to S3 like Write, if its sha256 is the given
+// one. S3 verifies the checksum of each part, and of the whole object when it fits in a part.
+// The data is also hashed while uploaded, and deleted if it doesn't match.
+func (s *S3Storage) WriteChecksum(ctx context.Context, key string, data io.Reader, sha256 string) error {
+	return s.write(ctx, key, data, sha256)
+}
+
+// write uploads data with the given key, verifying its sha256 unless expected is empty
+func (s *S3Storage) write(ctx context.Context, key string, data io.Reader, expected string) error {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.buildKey(key)),
+		Body:        data,
+		ContentType: aws.String(contentType(key)),
+	}
+
+	if isMetadataKey(key) {
+		if s.metadataCacheControl != "" {
+			input.CacheControl = aws.String(s.metadataCacheControl)
+		}
+	} else if path.Ext(key) == ".cab" && s.storageClass != "" {
+		input.StorageClass = s.storageClass
+	}
+
+	if s.kmsKeyID != "" {
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = aws.String(s.kmsKeyID)
+	}
+
+	// The expected digest is checked while uploading, so the data is only read once. Other
+	// digests are only known upfront when the data can be read twice, which is the case of
+	// the files and buffers firmirror writes.
+	size, sized := contentLength(data)
+	var hasher hash.Hash
+	if expected != "" {
+		// The object is deleted below if the data does not match the expected digest
+		input.Metadata = map[string]string{sha256MetadataKey: strings.ToLower(expected)}
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+		if seeker, ok := data.(io.ReadSeeker); ok {
+			if n, err := remaining(seeker); err == nil {
+				size, sized = n, true
+			}
+			if sum, err := hex.DecodeString(expected); err == nil && sized && size < s3PartSize {
+				// Uploaded with a single request, so S3 can check the digest of the whole object
+				input.ChecksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(sum))
+			}
+		}
+		hasher = sha256.New()
+		input.Body = io.TeeReader(data, hasher)
+	} else if seeker, ok := data.(io.ReadSeeker); ok {
+		digest, err := sha256Seeker(seeker)
+		if err != nil {
+			return fmt.Errorf("failed to hash data: %w", err)
+		}
+		input.Metadata = map[string]string{sha256MetadataKey: digest}
+	}
+
+	var opts []func(*manager.Uploader)
+	if sized {
+		// Grow the parts so that the object fits in the maximum number of parts, as the
+		// uploader only does it itself for seekable data
+		if size/s3PartSize >= int64(manager.MaxUploadParts) {
+			opts = append(opts, func(u *manager.Uploader) {
+				u.PartSize = size/int64(manager.MaxUploadParts) + 1
+			})
+		}
+	}
+
+	if _, err := s.uploader.Upload(ctx, input, opts...); err != nil {
+		err = fmt.Errorf("failed to upload to S3: %w", err)
+		if input.ChecksumSHA256 != nil {
+			// The single request was rejected once all the data was hashed, likely by S3 as
+			// the data does not match
+			if mismatch := checkDigest(key, expected, hex.EncodeToString(hasher.Sum(nil))); mismatch != nil {
+				return errors.Join(mismatch, err)
+			}
+		}
+		return err
+	}
+
+	if hasher != nil {
+		if err := checkDigest(key, expected, hex.EncodeToString(hasher.Sum(nil))); err != nil {
+			if _, delErr := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: aws.String(s.bucket),
+				Key:    aws.String(s.buildKey(key)),
+			}); delErr != nil {
+				return errors.Join(err, fmt.Errorf("failed to delete corrupted object: %w", delErr))
+			}
+			return err
+		}
+	}
+
+	return nil
+}
 
-	// Read data into buffer to determine size (needed for some S3-compatible services)
-	// This also allows us to retry in case of transient errors
-	buf, err := io.ReadAll(data)
+// remaining returns the number of bytes left in data, then rewinds it
+func remaining(data io.Seeker) (int64, error) {
+	start, err := data.Seek(0, io.SeekCurrent)
 	if err != nil {
-		return fmt.Errorf("failed to read data: %w", err)
+		return 0, err
 	}
+	end, err := data.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := data.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return end - start, nil
+}
 
-	_, err = s.uploader.Upload(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(fullKey),
-		Body:   bytes.NewReader(buf),
-	})
+// sha256Seeker returns the hex-encoded sha256 of the remaining data, then rewinds it
+func sha256Seeker(data io.ReadSeeker) (string, error) {
+	start, err := data.Seek(0, io.SeekCurrent)
 	if err != nil {
-		return fmt.Errorf("failed to upload to S3: %w", err)
+		return "", err
 	}
 
-	return nil
+	digest, err := sha256Hex(data)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := data.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+	return digest, nil
 }
 
-// Read retrieves data for the given key from S3
-func (s *S3Storage) Read(ctx context.Context, key string) (io.ReadCloser, error) {
-	fullKey := s.buildKey(key)
+// contentLength returns the number of bytes left in data, for the readers that tell it without
+// being seekable. The uploader measures seekable data itself.
+func contentLength(data io.Reader) (int64, bool) {
+	if _, ok := data.(io.Seeker); ok {
+		return 0, false
+	}
+	if r, ok := data.(interface{ Len() int }); ok { // e.g. bytes.Buffer
+		return int64(r.Len()), true
+	}
+	return 0, false
+}
 
-	// Download to buffer
-	buf := manager.NewWriteAtBuffer([]byte{})
-	_, err := s.downloader.Download(ctx, buf, &s3.GetObjectInput{
+// Read streams data for the given key from S3, the caller must close it
+func (s *S3Storage) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
-		Key:    aws.String(fullKey),
+		Key:    aws.String(s.buildKey(key)),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to download from S3: %w", err)
 	}
-
-	// Return buffer as ReadCloser
-	return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	return resp.Body, nil
 }
 
 // Exists checks if a key exists in S3
@@ -130,6 +302,47 @@ func (s *S3Storage) Exists(ctx context.Context, key string) (bool, error) {
 	return true, nil
 }
 
+// Stat describes the object stored with the given key in S3
+func (s *S3Storage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	resp, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.buildKey(key)),
+	})
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to describe object: %w", err)
+	}
+	return ObjectInfo{
+		Size:    aws.ToInt64(resp.ContentLength),
+		ModTime: aws.ToTime(resp.LastModified),
+		ETag:    quoteETag(aws.ToString(resp.ETag)),
+	}, nil
+}
+
+// ReadRange streams part of the data for the given key from S3, the caller must close it
+func (s *S3Storage) ReadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	resp, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.buildKey(key)),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from S3: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// Delete removes the object stored with the given key from S3
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.buildKey(key)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
 // List returns all keys with the given prefix (useful for debugging and management)
 func (s *S3Storage) List(ctx context.Context, prefix string) ([]string, error) {
 	fullPrefix := s.buildKey(prefix)
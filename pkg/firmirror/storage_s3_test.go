@@ -0,0 +1,410 @@
+package firmirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a minimal S3 server for the bucket "firmirror". Objects larger than keepSize are
+// discarded after being counted, and served back as zeros, so that large objects do not weigh
+// on the memory of the test.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	sizes    map[string]int64
+	headers  map[string]http.Header // headers the objects or uploads were created with
+	parts    map[string][][]byte
+	keepSize int64
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{
+		objects:  make(map[string][]byte),
+		sizes:    make(map[string]int64),
+		headers:  make(map[string]http.Header),
+		parts:    make(map[string][][]byte),
+		keepSize: 1024 * 1024,
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != "firmirror" {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID := fmt.Sprintf("upload-%d", len(f.parts))
+		f.parts[uploadID] = nil
+		f.headers[uploadID] = r.Header.Clone()
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", bucket, key, uploadID)
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		part, _ := strconv.Atoi(query.Get("partNumber"))
+		f.mu.Unlock()
+		data, size := f.consume(r.Body)
+		f.mu.Lock()
+		uploadID := query.Get("uploadId")
+		for len(f.parts[uploadID]) < part {
+			f.parts[uploadID] = append(f.parts[uploadID], nil)
+		}
+		f.parts[uploadID][part-1] = data
+		f.sizes[uploadID+"/"+strconv.Itoa(part)] = size
+		w.Header().Set("ETag", fmt.Sprintf("%q", "part"+strconv.Itoa(part)))
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		uploadID := query.Get("uploadId")
+		var data []byte
+		var size int64
+		for i, part := range f.parts[uploadID] {
+			data = append(data, part...)
+			size += f.sizes[uploadID+"/"+strconv.Itoa(i+1)]
+		}
+		f.store(key, data, size)
+		f.headers[key] = f.headers[uploadID]
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>\"object\"</ETag></CompleteMultipartUploadResult>", bucket, key)
+
+	case r.Method == http.MethodPut:
+		f.mu.Unlock()
+		data, size := f.consume(r.Body)
+		f.mu.Lock()
+		if checksum := r.Header.Get("X-Amz-Checksum-Sha256"); checksum != "" {
+			sum := sha256.Sum256(data)
+			if checksum != base64.StdEncoding.EncodeToString(sum[:]) {
+				http.Error(w, "<Error><Code>BadDigest</Code></Error>", http.StatusBadRequest)
+				return
+			}
+		}
+		f.store(key, data, size)
+		f.headers[key] = r.Header.Clone()
+		w.Header().Set("ETag", `"object"`)
+
+	case r.Method == http.MethodGet && key == "" && query.Get("list-type") == "2":
+		fmt.Fprint(w, "<ListBucketResult>")
+		for key := range f.objects {
+			if strings.HasPrefix(key, query.Get("prefix")) {
+				fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", key)
+			}
+		}
+		fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		size, ok := f.sizes[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"object"`)
+		data := f.objects[key]
+		var start, end int64
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err == nil && int64(len(data)) == size {
+			end = min(end+1, size)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, size))
+			w.Header().Set("Content-Length", strconv.FormatInt(end-start, 10))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[start:end])
+			return
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		if r.Method == http.MethodHead {
+			return
+		}
+		f.mu.Unlock()
+		if int64(len(data)) == size {
+			w.Write(data)
+		} else {
+			io.Copy(w, io.LimitReader(zeros{}, size))
+		}
+		f.mu.Lock()
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		delete(f.sizes, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "unsupported", http.StatusNotImplemented)
+	}
+}
+
+// consume reads a request body, returning it if it is small enough to be kept
+func (f *fakeS3) consume(body io.Reader) ([]byte, int64) {
+	data, _ := io.ReadAll(io.LimitReader(body, f.keepSize+1))
+	if int64(len(data)) <= f.keepSize {
+		return data, int64(len(data))
+	}
+	n, _ := io.Copy(io.Discard, body)
+	return nil, int64(len(data)) + n
+}
+
+func (f *fakeS3) store(key string, data []byte, size int64) {
+	if int64(len(data)) != size {
+		data = nil
+	}
+	f.objects[key] = data
+	f.sizes[key] = size
+}
+
+// zeros is an endless stream of zeros, hiding its size from the uploader like a network stream
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// peakHeap runs fn and returns how much the heap grew at most while it ran
+func peakHeap(t *testing.T, fn func()) uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	baseline := stats.HeapAlloc
+
+	var peak uint64
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > baseline && stats.HeapAlloc-baseline > peak {
+				peak = stats.HeapAlloc - baseline
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	fn()
+	close(done)
+	<-sampled
+	t.Logf("Peak heap growth: %d MiB", peak/1024/1024)
+	return peak
+}
+
+func TestS3Storage(t *testing.T) {
+	ctx := context.TODO()
+	t.Setenv("AWS_ACCESS_KEY_ID", "firmirror")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "firmirror")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	newStorage := func(t *testing.T, prefix string) (*S3Storage, *fakeS3) {
+		fake, server := newFakeS3(t)
+		storage, err := NewS3Storage(ctx, S3Config{Bucket: "firmirror", Prefix: prefix, Region: "us-east-1", Endpoint: server.URL})
+		require.NoError(t, err, "S3 storage should be created")
+		return storage, fake
+	}
+
+	t.Run("MissingBucket", func(t *testing.T) {
+		_, server := newFakeS3(t)
+		_, err := NewS3Storage(ctx, S3Config{Bucket: "missing", Region: "us-east-1", Endpoint: server.URL})
+		assert.ErrorContains(t, err, "failed to access bucket missing", "Missing bucket should be reported")
+
+		_, err = NewS3Storage(ctx, S3Config{Bucket: "firmirror", Region: "us-east-1", Endpoint: server.URL, StorageClass: "COLD"})
+		assert.ErrorContains(t, err, `unknown storage class "COLD"`, "Invalid storage class should be reported")
+	})
+
+	t.Run("WriteAndRead", func(t *testing.T) {
+		storage, fake := newStorage(t, "mirror")
+		require.NoError(t, storage.Write(ctx, "metadata.xml.zst", strings.NewReader("metadata")))
+		assert.Equal(t, []byte("metadata"), fake.objects["mirror/metadata.xml.zst"], "Object should be stored under the prefix")
+
+		reader, err := storage.Read(ctx, "metadata.xml.zst")
+		require.NoError(t, err, "Object should be readable")
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "metadata", string(data), "Content should match")
+
+		_, err = storage.Read(ctx, "missing.cab")
+		assert.ErrorContains(t, err, "failed to download from S3", "Missing object should be reported")
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		storage, _ := newStorage(t, "")
+		require.NoError(t, storage.Write(ctx, "firmware.bin.cab", strings.NewReader("cab")))
+
+		exists, err := storage.Exists(ctx, "firmware.bin.cab")
+		require.NoError(t, err)
+		assert.True(t, exists, "Written object should exist")
+
+		exists, err = storage.Exists(ctx, "missing.cab")
+		require.NoError(t, err)
+		assert.False(t, exists, "Missing object should not exist")
+	})
+
+	t.Run("ObjectMetadata", func(t *testing.T) {
+		fake, server := newFakeS3(t)
+		storage, err := NewS3Storage(ctx, S3Config{
+			Bucket:               "firmirror",
+			Region:               "us-east-1",
+			Endpoint:             server.URL,
+			StorageClass:         "STANDARD_IA",
+			KMSKeyID:             "alias/firmirror",
+			MetadataCacheControl: "no-cache",
+		})
+		require.NoError(t, err)
+
+		tests := []struct {
+			key          string
+			contentType  string
+			cacheControl string
+			storageClass string
+		}{
+			{key: "firmware.bin.cab", contentType: "application/vnd.ms-cab-compressed", storageClass: "STANDARD_IA"},
+			{key: "metadata.xml.zst", contentType: "application/zstd", cacheControl: "no-cache"},
+			{key: "metadata.xml.zst.jcat", contentType: "application/gzip", cacheControl: "no-cache"},
+			{key: "quarantine.xml", contentType: "application/xml"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.key, func(t *testing.T) {
+				require.NoError(t, storage.Write(ctx, tt.key, strings.NewReader("firmirror")))
+
+				header := fake.headers[tt.key]
+				assert.Equal(t, tt.contentType, header.Get("Content-Type"), "Content type should match")
+				assert.Equal(t, tt.cacheControl, header.Get("Cache-Control"), "Cache control should match")
+				assert.Equal(t, tt.storageClass, header.Get("X-Amz-Storage-Class"), "Storage class should match")
+				assert.Equal(t, "aws:kms", header.Get("X-Amz-Server-Side-Encryption"), "Object should be encrypted with KMS")
+				assert.Equal(t, "alias/firmirror", header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"), "KMS key should match")
+				assert.Equal(t, "8a12ac1ee9d33bb41e4579af5878e1217fd607b44ffe213c16cb33389069d30f", header.Get("X-Amz-Meta-Sha256"), "Digest should be stored in the metadata")
+			})
+		}
+
+		t.Run("Multipart", func(t *testing.T) {
+			storage.uploader.PartSize = manager.MinUploadPartSize
+			defer func() { storage.uploader.PartSize = s3PartSize }()
+
+			content := strings.Repeat("firmware", 2*int(manager.MinUploadPartSize)/8)
+			require.NoError(t, storage.Write(ctx, "large.cab", strings.NewReader(content)))
+			header := fake.headers["large.cab"]
+			assert.Equal(t, "application/vnd.ms-cab-compressed", header.Get("Content-Type"), "Content type should be set on the upload")
+			assert.NotEmpty(t, header.Get("X-Amz-Meta-Sha256"), "Digest should be set on the upload")
+		})
+
+		t.Run("Stream", func(t *testing.T) {
+			require.NoError(t, storage.Write(ctx, "stream.cab", io.LimitReader(zeros{}, 16)))
+			assert.Empty(t, fake.headers["stream.cab"].Get("X-Amz-Meta-Sha256"), "Digest of streams is not known upfront")
+		})
+	})
+
+	t.Run("ReadRange", func(t *testing.T) {
+		storage, _ := newStorage(t, "")
+		testReadRange(t, storage)
+	})
+
+	t.Run("ListAndDelete", func(t *testing.T) {
+		storage, _ := newStorage(t, "mirror")
+		testManagedStorage(t, storage)
+	})
+
+	t.Run("WriteChecksum", func(t *testing.T) {
+		storage, fake := newStorage(t, "")
+		const digest = "8a12ac1ee9d33bb41e4579af5878e1217fd607b44ffe213c16cb33389069d30f"
+
+		require.NoError(t, storage.WriteChecksum(ctx, "firmware.bin.cab", strings.NewReader("firmirror"), digest))
+		assert.Equal(t, "ihKsHunTO7QeRXmvWHjhIX/WB7RP/iE8FsszOJBp0w8=", fake.headers["firmware.bin.cab"].Get("X-Amz-Checksum-Sha256"), "S3 should verify the digest")
+
+		file := &countingReadSeeker{ReadSeeker: strings.NewReader("firmirror")}
+		require.NoError(t, storage.WriteChecksum(ctx, "counted.cab", file, digest))
+		assert.Equal(t, int64(len("firmirror")), file.n, "Data should only be read once")
+
+		err := storage.WriteChecksum(ctx, "corrupt.cab", strings.NewReader("corrupted"), digest)
+		assert.ErrorIs(t, err, ErrChecksumMismatch, "Mismatch should be reported")
+		assert.NotContains(t, fake.sizes, "corrupt.cab", "Corrupted data should not be uploaded")
+
+		err = storage.WriteChecksum(ctx, "stream.cab", io.MultiReader(strings.NewReader("corrupted")), digest)
+		assert.ErrorIs(t, err, ErrChecksumMismatch, "Mismatch of streams should be reported")
+		assert.NotContains(t, fake.sizes, "stream.cab", "Corrupted stream should be deleted")
+
+		require.NoError(t, storage.WriteChecksum(ctx, "stream.cab", io.MultiReader(strings.NewReader("firmirror")), digest))
+		assert.Equal(t, []byte("firmirror"), fake.objects["stream.cab"], "Matching stream should be kept")
+		assert.Equal(t, digest, fake.headers["stream.cab"].Get("X-Amz-Meta-Sha256"), "Expected digest of streams should be stored in the metadata")
+	})
+
+	t.Run("MultipartUpload", func(t *testing.T) {
+		storage, fake := newStorage(t, "")
+		storage.uploader.PartSize = manager.MinUploadPartSize
+		fake.keepSize = 64 * 1024 * 1024
+
+		content := strings.Repeat("firmware", 3*int(manager.MinUploadPartSize)/8+1)
+		require.NoError(t, storage.Write(ctx, "large.cab", strings.NewReader(content)))
+		assert.Len(t, fake.parts["upload-0"], 4, "Object should be uploaded in parts")
+
+		reader, err := storage.Read(ctx, "large.cab")
+		require.NoError(t, err)
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data), "Parts should be assembled in order")
+	})
+
+	t.Run("BoundedMemory", func(t *testing.T) {
+		storage, fake := newStorage(t, "")
+		storage.uploader.PartSize = manager.MinUploadPartSize
+		const size = 256 * 1024 * 1024
+		// Buffering the object would take at least its size
+		const limit = size / 2
+
+		peak := peakHeap(t, func() {
+			require.NoError(t, storage.Write(ctx, "spp.iso", io.LimitReader(zeros{}, size)))
+		})
+		assert.Equal(t, int64(size), fake.sizes["spp.iso"], "Whole object should be uploaded")
+		assert.Less(t, peak, uint64(limit), "Upload memory should not depend on the object size")
+
+		peak = peakHeap(t, func() {
+			reader, err := storage.Read(ctx, "spp.iso")
+			require.NoError(t, err)
+			defer reader.Close()
+			n, err := io.Copy(io.Discard, reader)
+			require.NoError(t, err)
+			assert.Equal(t, int64(size), n, "Whole object should be downloaded")
+		})
+		assert.Less(t, peak, uint64(limit), "Download memory should not depend on the object size")
+	})
+}
+
+// countingReadSeeker counts the bytes read through it
+type countingReadSeeker struct {
+	io.ReadSeeker
+	n int64
+}
+
+func (r *countingReadSeeker) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,244 @@
+package firmirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// posixRenameExtension lets SFTP renames replace an existing file atomically, which plain
+// SFTP renames can't do
+const posixRenameExtension = "posix-rename@openssh.com"
+
+// SFTPConfig configures the SFTP storage backend
+type SFTPConfig struct {
+	Address    string `json:"address"` // host:port of the SSH server, the port defaults to 22
+	User       string `json:"user,omitempty"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"` // Path to a private key, tried before the password
+	KnownHosts string `json:"known_hosts"`           // Path to the known_hosts file used to verify the server
+	Path       string `json:"path,omitempty"`        // Base directory of the repository on the server
+}
+
+// SFTPStorage implements Storage interface for SFTP servers. Files are uploaded to a temporary
+// name then renamed, so that clients fetching from the server never see partial files.
+type SFTPStorage struct {
+	conn     *ssh.Client
+	client   *sftp.Client
+	basePath string
+}
+
+// NewSFTPStorage connects to the SFTP server and creates the base directory if needed
+func NewSFTPStorage(ctx context.Context, cfg SFTPConfig) (*SFTPStorage, error) {
+	if cfg.KnownHosts == "" {
+		return nil, fmt.Errorf("known hosts file is required to verify the server")
+	}
+	hostKeyCallback, err := knownhosts.New(cfg.KnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts: %w", err)
+	}
+
+	var auth []ssh.AuthMethod
+	if cfg.PrivateKey != "" {
+		key, err := os.ReadFile(cfg.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+
+	address := cfg.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "22")
+	}
+
+	netConn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, address, &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+	})
+	if err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("failed to establish SSH connection: %w", err)
+	}
+	conn := ssh.NewClient(sshConn, chans, reqs)
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start SFTP session: %w", err)
+	}
+
+	s, err := newSFTPStorage(client, cfg.Path)
+	if err != nil {
+		client.Close()
+		conn.Close()
+		return nil, err
+	}
+	s.conn = conn
+	return s, nil
+}
+
+func newSFTPStorage(client *sftp.Client, basePath string) (*SFTPStorage, error) {
+	if basePath == "" {
+		basePath = "."
+	}
+
+	// Without atomic renames, replacing a file would leave it missing until the upload is
+	// renamed over it, and lost if the rename fails
+	if _, ok := client.HasExtension(posixRenameExtension); !ok {
+		return nil, fmt.Errorf("server does not support %s, needed to replace files atomically", posixRenameExtension)
+	}
+
+	// Create base directory if it doesn't exist
+	if err := client.MkdirAll(basePath); err != nil {
+		return nil, fmt.Errorf("failed to create base path: %w", err)
+	}
+
+	return &SFTPStorage{
+		client:   client,
+		basePath: basePath,
+	}, nil
+}
+
+// Write uploads data to a temporary file next to the key, then renames it over the key
+func (s *SFTPStorage) Write(ctx context.Context, key string, data io.Reader) error {
+	fullPath := path.Join(s.basePath, key)
+	dir := path.Dir(fullPath)
+	if err := s.client.MkdirAll(dir); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmpPath := path.Join(dir, "."+path.Base(fullPath)+"."+uuid.NewString()+".tmp")
+	if err := s.upload(tmpPath, data); err != nil {
+		s.client.Remove(tmpPath)
+		return fmt.Errorf("failed to upload to SFTP: %w", err)
+	}
+
+	if err := s.client.PosixRename(tmpPath, fullPath); err != nil {
+		s.client.Remove(tmpPath)
+		return fmt.Errorf("failed to rename %s: %w", tmpPath, err)
+	}
+
+	return nil
+}
+
+// upload writes data to a new file with the given name
+func (s *SFTPStorage) upload(name string, data io.Reader) error {
+	file, err := s.client.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, data); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Read retrieves data for the given key from the SFTP server
+func (s *SFTPStorage) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := s.client.Open(path.Join(s.basePath, key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from SFTP: %w", err)
+	}
+	return file, nil
+}
+
+// Exists checks if a key exists on the SFTP server
+func (s *SFTPStorage) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.client.Stat(path.Join(s.basePath, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return true, nil
+}
+
+// Stat describes the file stored with the given key on the SFTP server
+func (s *SFTPStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := s.client.Stat(path.Join(s.basePath, key))
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return ObjectInfo{Size: info.Size(), ModTime: info.ModTime(), ETag: modTimeETag(info.ModTime(), info.Size())}, nil
+}
+
+// ReadRange retrieves part of the data for the given key from the SFTP server
+func (s *SFTPStorage) ReadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	file, err := s.client.Open(path.Join(s.basePath, key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from SFTP: %w", err)
+	}
+	return limitedFile(file, offset, length)
+}
+
+// List returns all keys with the given prefix on the SFTP server
+func (s *SFTPStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	basePath := path.Clean(s.basePath)
+
+	var keys []string
+	walker := s.client.Walk(basePath)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+		if walker.Stat().IsDir() {
+			continue
+		}
+
+		// Remove the base path from the returned keys
+		key := walker.Path()
+		if basePath != "." {
+			key = strings.TrimPrefix(key, basePath+"/")
+		}
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Delete removes the file stored with the given key from the SFTP server
+func (s *SFTPStorage) Delete(ctx context.Context, key string) error {
+	err := s.client.Remove(path.Join(s.basePath, key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// Close ends the SFTP session and its SSH connection
+func (s *SFTPStorage) Close() error {
+	err := s.client.Close()
+	if s.conn != nil {
+		err = errors.Join(err, s.conn.Close())
+	}
+	return err
+}
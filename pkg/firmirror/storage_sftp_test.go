@@ -0,0 +1,231 @@
+package firmirror
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// newSFTPServer starts an in-process SSH server with an in-memory SFTP subsystem, accepting the
+// user "firmirror" with the password "secret". It returns its address and a known_hosts file for it.
+func newSFTPServer(t *testing.T) (string, string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "firmirror" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("invalid credentials")
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	handlers := sftp.InMemHandler()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(conn, config, handlers)
+		}
+	}()
+
+	address := listener.Addr().String()
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{address}, signer.PublicKey())+"\n"), 0644))
+	return address, knownHosts
+}
+
+// serveSFTP serves the SFTP subsystem on the sessions of an SSH connection
+func serveSFTP(conn net.Conn, config *ssh.ServerConfig, handlers sftp.Handlers) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func() {
+			for req := range requests {
+				// The payload of subsystem requests is the length-prefixed subsystem name
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					server := sftp.NewRequestServer(channel, handlers)
+					server.Serve()
+					server.Close()
+				}
+			}
+		}()
+	}
+}
+
+// failingReader returns some data then fails, like an interrupted download
+type failingReader struct {
+	data io.Reader
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func TestSFTPStorage(t *testing.T) {
+	ctx := context.TODO()
+	address, knownHosts := newSFTPServer(t)
+	cfg := SFTPConfig{
+		Address:    address,
+		User:       "firmirror",
+		Password:   "secret",
+		KnownHosts: knownHosts,
+		Path:       "/srv/firmware",
+	}
+
+	newStorage := func(t *testing.T) *SFTPStorage {
+		storage, err := NewSFTPStorage(ctx, cfg)
+		require.NoError(t, err, "SFTP storage should be created")
+		t.Cleanup(func() { storage.Close() })
+		return storage
+	}
+
+	t.Run("WriteAndRead", func(t *testing.T) {
+		storage := newStorage(t)
+		require.NoError(t, storage.Write(ctx, "metadata.xml.zst", strings.NewReader("metadata")))
+
+		reader, err := storage.Read(ctx, "metadata.xml.zst")
+		require.NoError(t, err, "File should be readable")
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "metadata", string(data), "Content should match")
+
+		_, err = storage.Read(ctx, "missing.cab")
+		assert.ErrorContains(t, err, "failed to download from SFTP", "Missing file should be reported")
+	})
+
+	t.Run("ReadRange", func(t *testing.T) {
+		storage := newStorage(t)
+		testReadRange(t, storage)
+	})
+
+	t.Run("ListAndDelete", func(t *testing.T) {
+		storage, err := NewSFTPStorage(ctx, SFTPConfig{
+			Address:    address,
+			User:       "firmirror",
+			Password:   "secret",
+			KnownHosts: knownHosts,
+			Path:       "/srv/managed",
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { storage.Close() })
+		testManagedStorage(t, storage)
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		storage := newStorage(t)
+		require.NoError(t, storage.Write(ctx, "firmware.bin.cab", strings.NewReader("cab")))
+
+		exists, err := storage.Exists(ctx, "firmware.bin.cab")
+		require.NoError(t, err)
+		assert.True(t, exists, "Written file should exist")
+
+		exists, err = storage.Exists(ctx, "missing.cab")
+		require.NoError(t, err)
+		assert.False(t, exists, "Missing file should not exist")
+	})
+
+	t.Run("AtomicOverwrite", func(t *testing.T) {
+		storage := newStorage(t)
+		require.NoError(t, storage.Write(ctx, "overwrite.cab", strings.NewReader("old")))
+		require.NoError(t, storage.Write(ctx, "overwrite.cab", strings.NewReader("new")))
+
+		err := storage.Write(ctx, "overwrite.cab", &failingReader{data: strings.NewReader("partial")})
+		assert.ErrorContains(t, err, "failed to upload to SFTP", "Interrupted upload should be reported")
+
+		reader, err := storage.Read(ctx, "overwrite.cab")
+		require.NoError(t, err)
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(data), "Interrupted upload should not replace the file")
+
+		files, err := storage.client.ReadDir(cfg.Path)
+		require.NoError(t, err)
+		for _, file := range files {
+			assert.NotContains(t, file.Name(), ".tmp", "Temporary files should not be left behind")
+		}
+	})
+
+	t.Run("RequiresAtomicRename", func(t *testing.T) {
+		require.NoError(t, sftp.SetSFTPExtensions("statvfs@openssh.com"))
+		t.Cleanup(func() {
+			sftp.SetSFTPExtensions("hardlink@openssh.com", posixRenameExtension, "statvfs@openssh.com")
+		})
+
+		_, err := NewSFTPStorage(ctx, cfg)
+		assert.ErrorContains(t, err, posixRenameExtension, "Servers without atomic renames should be rejected")
+	})
+
+	t.Run("NestedKey", func(t *testing.T) {
+		storage := newStorage(t)
+		require.NoError(t, storage.Write(ctx, "dell/firmware.bin.cab", strings.NewReader("cab")))
+
+		exists, err := storage.Exists(ctx, "dell/firmware.bin.cab")
+		require.NoError(t, err)
+		assert.True(t, exists, "Nested file should exist")
+	})
+
+	t.Run("UnknownHost", func(t *testing.T) {
+		_, otherKnownHosts := newSFTPServer(t)
+		unknown := cfg
+		unknown.KnownHosts = otherKnownHosts
+		_, err := NewSFTPStorage(ctx, unknown)
+		assert.ErrorContains(t, err, "failed to establish SSH connection", "Unknown host key should be rejected")
+
+		unknown.KnownHosts = ""
+		_, err = NewSFTPStorage(ctx, unknown)
+		assert.ErrorContains(t, err, "known hosts file is required", "Host key verification should be required")
+	})
+
+	t.Run("InvalidPassword", func(t *testing.T) {
+		invalid := cfg
+		invalid.Password = "invalid"
+		_, err := NewSFTPStorage(ctx, invalid)
+		assert.ErrorContains(t, err, "failed to establish SSH connection", "Invalid password should be rejected")
+	})
+}
@@ -0,0 +1,119 @@
+package firmirror
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// corruptingStorage wraps a storage which alters the data it writes
+type corruptingStorage struct {
+	Storage
+}
+
+func (s corruptingStorage) Write(ctx context.Context, key string, data io.Reader) error {
+	return s.Storage.Write(ctx, key, io.MultiReader(data, strings.NewReader("!")))
+}
+
+func TestWriteVerified(t *testing.T) {
+	ctx := context.TODO()
+	const digest = "8a12ac1ee9d33bb41e4579af5878e1217fd607b44ffe213c16cb33389069d30f" // sha256 of "firmirror"
+
+	t.Run("ChecksumWriter", func(t *testing.T) {
+		dir := t.TempDir()
+		storage, err := NewLocalStorage(dir)
+		require.NoError(t, err)
+
+		require.NoError(t, WriteVerified(ctx, storage, "firmware.bin.cab", strings.NewReader("firmirror"), strings.ToUpper(digest)))
+		assert.Equal(t, "firmirror", readKey(t, storage, "firmware.bin.cab"))
+
+		err = WriteVerified(ctx, storage, "firmware.bin.cab", strings.NewReader("corrupted"), digest)
+		assert.ErrorIs(t, err, ErrChecksumMismatch, "Mismatch should be reported")
+		assert.Equal(t, "firmirror", readKey(t, storage, "firmware.bin.cab"), "Corrupted data should not replace the file")
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "Temporary files should be removed")
+	})
+
+	t.Run("ReadBack", func(t *testing.T) {
+		// Embedding the interface hides WriteChecksum
+		storage := struct{ Storage }{newLocalStorage(t)}
+		require.NoError(t, WriteVerified(ctx, storage, "firmware.bin.cab", strings.NewReader("firmirror"), digest))
+
+		err := WriteVerified(ctx, corruptingStorage{storage}, "firmware.bin.cab", strings.NewReader("firmirror"), digest)
+		assert.ErrorIs(t, err, ErrChecksumMismatch, "Data altered by the storage should be reported")
+		assert.ErrorContains(t, err, "firmware.bin.cab: expected sha256 "+digest)
+	})
+
+	t.Run("MultiStorage", func(t *testing.T) {
+		primary, eu := newLocalStorage(t), newLocalStorage(t)
+		storage, err := NewMultiStorage(ctx, primary,
+			Destination{Name: "eu", Storage: corruptingStorage{struct{ Storage }{eu}}, Policy: PolicyFail},
+		)
+		require.NoError(t, err)
+
+		err = WriteVerified(ctx, storage, "firmware.bin.cab", strings.NewReader("firmirror"), digest)
+		assert.ErrorIs(t, err, ErrChecksumMismatch, "Every destination should be verified")
+		assert.ErrorContains(t, err, "eu: ")
+		assert.Equal(t, "firmirror", readKey(t, primary, "firmware.bin.cab"))
+	})
+}
+
+// testReadRange checks the Stat and ReadRange of a storage
+func testReadRange(t *testing.T, storage interface {
+	Storage
+	RangeStorage
+}) {
+	ctx := context.TODO()
+	require.NoError(t, storage.Write(ctx, "firmware.bin.cab", strings.NewReader("0123456789")))
+
+	info, err := storage.Stat(ctx, "firmware.bin.cab")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), info.Size, "Size should match")
+	assert.NotEmpty(t, info.ETag, "ETag should be set")
+
+	reader, err := storage.ReadRange(ctx, "firmware.bin.cab", 2, 3)
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "234", string(data), "Only the range should be read")
+
+	_, err = storage.Stat(ctx, "missing.cab")
+	assert.Error(t, err, "Missing file should be reported")
+}
+
+// testManagedStorage checks the List and Delete of a storage
+func testManagedStorage(t *testing.T, storage interface {
+	Storage
+	ManagedStorage
+}) {
+	ctx := context.TODO()
+	for _, key := range []string{"metadata.xml.zst", "dell/bios.exe.cab", "dell/nic.exe.cab"} {
+		require.NoError(t, storage.Write(ctx, key, strings.NewReader(key)))
+	}
+
+	keys, err := storage.List(ctx, "dell/")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"dell/bios.exe.cab", "dell/nic.exe.cab"}, keys, "Keys with the prefix should be listed")
+
+	require.NoError(t, storage.Delete(ctx, "dell/bios.exe.cab"))
+	exists, err := storage.Exists(ctx, "dell/bios.exe.cab")
+	require.NoError(t, err)
+	assert.False(t, exists, "Deleted key should not exist")
+	assert.NoError(t, storage.Delete(ctx, "dell/bios.exe.cab"), "Deleting a missing key should succeed")
+
+	keys, err = storage.List(ctx, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"metadata.xml.zst", "dell/nic.exe.cab"}, keys, "Every remaining key should be listed")
+}
+
+func TestLocalStorage_Managed(t *testing.T) {
+	testManagedStorage(t, newLocalStorage(t))
+}
@@ -0,0 +1,320 @@
+package firmirror
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// WebDAVConfig configures the WebDAV storage backend
+type WebDAVConfig struct {
+	URL      string `json:"url"`            // URL of the collection holding the repository
+	User     string `json:"user,omitempty"` // Optional basic authentication
+	Password string `json:"password,omitempty"`
+}
+
+// WebDAVStorage implements Storage interface for WebDAV servers. Files are uploaded to a temporary
+// name then moved, so that clients fetching from the server never see partial files.
+type WebDAVStorage struct {
+	client   *http.Client
+	baseURL  *url.URL
+	user     string
+	password string
+}
+
+// NewWebDAVStorage creates a WebDAV storage backend, creating the base collection if needed
+func NewWebDAVStorage(ctx context.Context, cfg WebDAVConfig) (*WebDAVStorage, error) {
+	baseURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebDAV URL: %w", err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid WebDAV URL: %q is not an HTTP URL", cfg.URL)
+	}
+	baseURL.Path = "/" + strings.Trim(baseURL.Path, "/")
+
+	s := &WebDAVStorage{
+		client:   http.DefaultClient,
+		baseURL:  baseURL,
+		user:     cfg.User,
+		password: cfg.Password,
+	}
+
+	if err := s.mkcolAll(ctx, baseURL.Path); err != nil {
+		return nil, fmt.Errorf("failed to create base collection: %w", err)
+	}
+
+	// Verify the collection is accessible, as the root collection is never created
+	err = s.expect(s.do(ctx, "PROPFIND", baseURL.Path, nil, http.Header{"Depth": {"0"}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to access collection %s: %w", baseURL.Path, err)
+	}
+
+	return s, nil
+}
+
+// resolve returns the URL of the given path, relative to the server root
+func (s *WebDAVStorage) resolve(p string) string {
+	u := *s.baseURL
+	u.Path = p
+	return u.String()
+}
+
+// do sends a request for the given path, relative to the server root
+func (s *WebDAVStorage) do(ctx context.Context, method, p string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.resolve(p), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if s.user != "" {
+		req.SetBasicAuth(s.user, s.password)
+	}
+	return s.client.Do(req)
+}
+
+// errMissingParent is returned by mkcol when the parent collection does not exist
+var errMissingParent = errors.New("parent collection is missing")
+
+// mkcol creates the collection at the given path, succeeding if it already exists
+func (s *WebDAVStorage) mkcol(ctx context.Context, p string) error {
+	resp, err := s.do(ctx, "MKCOL", p+"/", nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusMethodNotAllowed: // 405 means the collection exists
+		return nil
+	case http.StatusConflict:
+		return errMissingParent
+	default:
+		return fmt.Errorf("failed to create collection %s: %s", p, resp.Status)
+	}
+}
+
+// mkcolAll creates the collection at the given path along with any missing parent
+func (s *WebDAVStorage) mkcolAll(ctx context.Context, p string) error {
+	if p == "/" {
+		return nil
+	}
+
+	err := s.mkcol(ctx, p)
+	if errors.Is(err, errMissingParent) {
+		if err := s.mkcolAll(ctx, path.Dir(p)); err != nil {
+			return err
+		}
+		err = s.mkcol(ctx, p)
+	}
+	return err
+}
+
+// Write uploads data to a temporary file next to the key, then moves it over the key
+func (s *WebDAVStorage) Write(ctx context.Context, key string, data io.Reader) error {
+	fullPath := path.Join(s.baseURL.Path, key)
+	dir := path.Dir(fullPath)
+	if dir != s.baseURL.Path {
+		if err := s.mkcolAll(ctx, dir); err != nil {
+			return fmt.Errorf("failed to upload to WebDAV: %w", err)
+		}
+	}
+
+	tmpPath := path.Join(dir, "."+path.Base(fullPath)+"."+uuid.NewString()+".tmp")
+	if err := s.expect(s.do(ctx, http.MethodPut, tmpPath, data, nil)); err != nil {
+		s.remove(ctx, tmpPath)
+		return fmt.Errorf("failed to upload to WebDAV: %w", err)
+	}
+
+	err := s.expect(s.do(ctx, "MOVE", tmpPath, nil, http.Header{
+		"Destination": {s.resolve(fullPath)},
+		"Overwrite":   {"T"},
+	}))
+	if err != nil {
+		s.remove(ctx, tmpPath)
+		return fmt.Errorf("failed to move %s: %w", tmpPath, err)
+	}
+
+	return nil
+}
+
+// expect closes the response of a request, turning unsuccessful statuses into errors
+func (s *WebDAVStorage) expect(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+// remove deletes the file at the given path, ignoring errors as it only cleans up after failures
+func (s *WebDAVStorage) remove(ctx context.Context, p string) {
+	s.expect(s.do(ctx, http.MethodDelete, p, nil, nil))
+}
+
+// Read retrieves data for the given key from the WebDAV server
+func (s *WebDAVStorage) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, path.Join(s.baseURL.Path, key), nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from WebDAV: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download from WebDAV: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Exists checks if a key exists on the WebDAV server
+func (s *WebDAVStorage) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := s.do(ctx, http.MethodHead, path.Join(s.baseURL.Path, key), nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to check file existence: %w", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to check file existence: %s", resp.Status)
+	}
+}
+
+// Stat describes the file stored with the given key on the WebDAV server
+func (s *WebDAVStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	resp, err := s.do(ctx, http.MethodHead, path.Join(s.baseURL.Path, key), nil, nil)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to describe file: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ObjectInfo{}, fmt.Errorf("failed to describe file: %s", resp.Status)
+	}
+
+	info := ObjectInfo{Size: resp.ContentLength, ETag: resp.Header.Get("ETag")}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info, nil
+}
+
+// ReadRange retrieves part of the data for the given key from the WebDAV server
+func (s *WebDAVStorage) ReadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, path.Join(s.baseURL.Path, key), nil, http.Header{
+		"Range": {fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from WebDAV: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		// The server ignored the range and sends the whole file
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to download from WebDAV: %w", err)
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(resp.Body, length), resp.Body}, nil
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download from WebDAV: %s", resp.Status)
+	}
+}
+
+// multistatus is the answer of the server to a PROPFIND request
+type multistatus struct {
+	Responses []struct {
+		Href       string    `xml:"DAV: href"`
+		Collection *struct{} `xml:"DAV: propstat>prop>resourcetype>collection"`
+	} `xml:"DAV: response"`
+}
+
+// List returns all keys with the given prefix on the WebDAV server
+func (s *WebDAVStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	base := strings.TrimSuffix(s.baseURL.Path, "/")
+
+	var keys []string
+	err := s.walk(ctx, base, func(p string) {
+		// Remove the base collection from the returned keys
+		if key := strings.TrimPrefix(p, base+"/"); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return keys, nil
+}
+
+// walk calls fn with the path of every file below the collection at the given path, without
+// trailing slash, listing one collection at a time as servers commonly refuse infinite depth
+func (s *WebDAVStorage) walk(ctx context.Context, collection string, fn func(string)) error {
+	resp, err := s.do(ctx, "PROPFIND", collection+"/", nil, http.Header{"Depth": {"1"}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return fmt.Errorf("failed to list collection %s: %s", collection, resp.Status)
+	}
+
+	var status multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("failed to parse collection %s: %w", collection, err)
+	}
+
+	for _, response := range status.Responses {
+		href, err := url.Parse(response.Href)
+		if err != nil {
+			return fmt.Errorf("invalid href %q: %w", response.Href, err)
+		}
+		p := strings.TrimSuffix(href.Path, "/")
+		switch {
+		case p == collection:
+			// The collection itself
+		case response.Collection != nil:
+			if err := s.walk(ctx, p, fn); err != nil {
+				return err
+			}
+		default:
+			fn(p)
+		}
+	}
+	return nil
+}
+
+// Delete removes the file stored with the given key from the WebDAV server
+func (s *WebDAVStorage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, path.Join(s.baseURL.Path, key), nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound && (resp.StatusCode < 200 || resp.StatusCode >= 300) {
+		return fmt.Errorf("failed to delete file: %s", resp.Status)
+	}
+	return nil
+}
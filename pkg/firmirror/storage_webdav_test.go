@@ -0,0 +1,136 @@
+package firmirror
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+// newWebDAVServer starts a WebDAV server backed by memory, requiring the user "firmirror" with
+// the password "secret"
+func newWebDAVServer(t *testing.T) (*httptest.Server, webdav.FileSystem) {
+	fs := webdav.NewMemFS()
+	handler := &webdav.Handler{FileSystem: fs, LockSystem: webdav.NewMemLS()}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "firmirror" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, fs
+}
+
+func TestWebDAVStorage(t *testing.T) {
+	ctx := context.TODO()
+	server, fs := newWebDAVServer(t)
+	cfg := WebDAVConfig{
+		URL:      server.URL + "/dav/firmware/",
+		User:     "firmirror",
+		Password: "secret",
+	}
+
+	newStorage := func(t *testing.T) *WebDAVStorage {
+		storage, err := NewWebDAVStorage(ctx, cfg)
+		require.NoError(t, err, "WebDAV storage should be created")
+		return storage
+	}
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		_, err := NewWebDAVStorage(ctx, WebDAVConfig{URL: "ftp://firmware.example.com"})
+		assert.ErrorContains(t, err, "is not an HTTP URL")
+
+		_, err = NewWebDAVStorage(ctx, WebDAVConfig{URL: server.URL})
+		assert.ErrorContains(t, err, "401 Unauthorized", "Missing credentials should be reported")
+	})
+
+	t.Run("WriteAndRead", func(t *testing.T) {
+		storage := newStorage(t)
+		require.NoError(t, storage.Write(ctx, "metadata.xml.zst", strings.NewReader("metadata")))
+
+		reader, err := storage.Read(ctx, "metadata.xml.zst")
+		require.NoError(t, err, "File should be readable")
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "metadata", string(data), "Content should match")
+
+		_, err = storage.Read(ctx, "missing.cab")
+		assert.ErrorContains(t, err, "failed to download from WebDAV", "Missing file should be reported")
+	})
+
+	t.Run("ReadRange", func(t *testing.T) {
+		storage := newStorage(t)
+		testReadRange(t, storage)
+	})
+
+	t.Run("ListAndDelete", func(t *testing.T) {
+		storage, err := NewWebDAVStorage(ctx, WebDAVConfig{URL: server.URL + "/dav/managed", User: "firmirror", Password: "secret"})
+		require.NoError(t, err)
+		testManagedStorage(t, storage)
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		storage := newStorage(t)
+		require.NoError(t, storage.Write(ctx, "firmware.bin.cab", strings.NewReader("cab")))
+
+		exists, err := storage.Exists(ctx, "firmware.bin.cab")
+		require.NoError(t, err)
+		assert.True(t, exists, "Written file should exist")
+
+		exists, err = storage.Exists(ctx, "missing.cab")
+		require.NoError(t, err)
+		assert.False(t, exists, "Missing file should not exist")
+	})
+
+	t.Run("AtomicOverwrite", func(t *testing.T) {
+		storage := newStorage(t)
+		require.NoError(t, storage.Write(ctx, "overwrite.cab", strings.NewReader("old")))
+		require.NoError(t, storage.Write(ctx, "overwrite.cab", strings.NewReader("new")))
+
+		err := storage.Write(ctx, "overwrite.cab", &failingReader{data: strings.NewReader("partial")})
+		assert.ErrorContains(t, err, "failed to upload to WebDAV", "Interrupted upload should be reported")
+
+		reader, err := storage.Read(ctx, "overwrite.cab")
+		require.NoError(t, err)
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(data), "Interrupted upload should not replace the file")
+	})
+
+	t.Run("NoTemporaryFiles", func(t *testing.T) {
+		storage := newStorage(t)
+		require.NoError(t, storage.Write(ctx, "clean/firmware.bin.cab", strings.NewReader("cab")))
+		require.NoError(t, storage.Write(ctx, "clean/firmware.bin.cab", strings.NewReader("cab")))
+
+		dir, err := fs.OpenFile(ctx, "/dav/firmware/clean", os.O_RDONLY, 0)
+		require.NoError(t, err)
+		defer dir.Close()
+		files, err := dir.Readdir(-1)
+		require.NoError(t, err)
+		for _, file := range files {
+			assert.NotContains(t, file.Name(), ".tmp", "Temporary files should not be left behind")
+		}
+	})
+
+	t.Run("NestedKey", func(t *testing.T) {
+		storage := newStorage(t)
+		require.NoError(t, storage.Write(ctx, "hpe/gen11/firmware.bin.cab", strings.NewReader("cab")))
+
+		exists, err := storage.Exists(ctx, "hpe/gen11/firmware.bin.cab")
+		require.NoError(t, err)
+		assert.True(t, exists, "Nested file should exist")
+	})
+}
@@ -0,0 +1,53 @@
+package firmirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/criteo/firmirror/pkg/lvfs"
+)
+
+// ValidateRepository checks the repository published in storage: every component must
+// pass lvfs.ValidateSchema and the firmware its releases point to must be stored. The
+// published components are returned along with the invalid ones.
+func ValidateRepository(ctx context.Context, storage Storage) (*lvfs.Components, []lvfs.InvalidComponent, error) {
+	for _, key := range []string{metadataKey, metadataKey + ".jcat"} {
+		exists, err := storage.Exists(ctx, key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check %s existence: %w", key, err)
+		}
+		if !exists {
+			return nil, nil, fmt.Errorf("%s not found in storage", key)
+		}
+	}
+
+	components, err := readMetadata(ctx, storage, metadataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	valid, invalid := lvfs.ValidateComponents(components.Component)
+	for _, component := range valid {
+		var errs []error
+		for _, release := range component.Releases {
+			// Absolute locations point to another server, e.g. the LVFS
+			if strings.Contains(release.Location, "://") {
+				continue
+			}
+			exists, err := storage.Exists(ctx, release.Location)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to check %s existence: %w", release.Location, err)
+			}
+			if !exists {
+				errs = append(errs, fmt.Errorf("release %s: %s not found in storage", release.Version, release.Location))
+			}
+		}
+		if len(errs) > 0 {
+			invalid = append(invalid, lvfs.InvalidComponent{Component: component, Err: errors.Join(errs...)})
+		}
+	}
+
+	return components, invalid, nil
+}
@@ -0,0 +1,97 @@
+package firmirror
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/criteo/firmirror/pkg/lvfs"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeRepository stores the metadata of components, its signature and the given files
+func writeRepository(t *testing.T, storage Storage, components []lvfs.Component, files ...string) {
+	xmlData, err := xml.Marshal(lvfs.Components{Origin: "firmirror", Component: components})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	zstWriter, err := zstd.NewWriter(&buf)
+	require.NoError(t, err)
+	_, err = zstWriter.Write(append([]byte(xml.Header), xmlData...))
+	require.NoError(t, err)
+	require.NoError(t, zstWriter.Close())
+
+	ctx := context.TODO()
+	require.NoError(t, storage.Write(ctx, metadataKey, &buf))
+	require.NoError(t, storage.Write(ctx, metadataKey+".jcat", strings.NewReader("{}")))
+	for _, file := range files {
+		require.NoError(t, storage.Write(ctx, file, strings.NewReader("cab")))
+	}
+}
+
+func TestValidateRepository(t *testing.T) {
+	component := func(id, location string) lvfs.Component {
+		return lvfs.Component{
+			Type:     "firmware",
+			ID:       id,
+			Name:     lvfs.Translations{{Text: "Test Firmware"}},
+			Summary:  lvfs.Translations{{Text: "Test firmware"}},
+			Provides: []lvfs.Firmware{{Type: "flashed", Text: "dadc32f0-d6fc-575c-bad8-140b0e1d6850"}},
+			Releases: []lvfs.Release{{Version: "1.0.0", Location: location}},
+		}
+	}
+
+	t.Run("ValidRepository", func(t *testing.T) {
+		storage, err := NewLocalStorage(t.TempDir())
+		require.NoError(t, err)
+		writeRepository(t, storage, []lvfs.Component{
+			component("com.test.local", "local.bin.cab"),
+			component("com.test.remote", "https://fwupd.org/downloads/remote.cab"),
+		}, "local.bin.cab")
+
+		components, invalid, err := ValidateRepository(context.TODO(), storage)
+		require.NoError(t, err, "Repository should be readable")
+		assert.Len(t, components.Component, 2, "Should return the published components")
+		assert.Empty(t, invalid, "Repository should be valid")
+	})
+
+	t.Run("ReportsInvalidComponents", func(t *testing.T) {
+		storage, err := NewLocalStorage(t.TempDir())
+		require.NoError(t, err)
+		broken := component("com.test.broken", "broken.bin.cab")
+		broken.Provides = nil
+		writeRepository(t, storage, []lvfs.Component{
+			component("com.test.missing", "missing.bin.cab"),
+			broken,
+		}, "broken.bin.cab")
+
+		_, invalid, err := ValidateRepository(context.TODO(), storage)
+		require.NoError(t, err, "Repository should be readable")
+		require.Len(t, invalid, 2, "Both components should be invalid")
+		assert.Equal(t, "com.test.broken", invalid[0].Component.ID)
+		assert.ErrorContains(t, invalid[0].Err, "provides: no device GUID", "Schema problems should be reported")
+		assert.Equal(t, "com.test.missing", invalid[1].Component.ID)
+		assert.ErrorContains(t, invalid[1].Err, "missing.bin.cab not found in storage", "Missing firmware should be reported")
+	})
+
+	t.Run("MissingMetadata", func(t *testing.T) {
+		storage, err := NewLocalStorage(t.TempDir())
+		require.NoError(t, err)
+
+		_, _, err = ValidateRepository(context.TODO(), storage)
+		assert.ErrorContains(t, err, "metadata.xml.zst not found", "Missing metadata should be reported")
+	})
+
+	t.Run("MissingSignature", func(t *testing.T) {
+		storage, err := NewLocalStorage(t.TempDir())
+		require.NoError(t, err)
+		require.NoError(t, storage.Write(context.TODO(), metadataKey, strings.NewReader("")))
+
+		_, _, err = ValidateRepository(context.TODO(), storage)
+		assert.ErrorContains(t, err, "metadata.xml.zst.jcat not found", "Missing signature should be reported")
+	})
+}
@@ -1,12 +1,23 @@
 package firmirror
 
-import "github.com/criteo/firmirror/pkg/lvfs"
+import (
+	"errors"
+
+	"github.com/criteo/firmirror/pkg/lvfs"
+)
+
+// ErrNotRetrieved is returned by ToAppstream when the firmware must be retrieved before
+// it can be converted.
+var ErrNotRetrieved = errors.New("firmware must be retrieved first using RetrieveFirmware")
+
+// ErrSkipped is returned by ToAppstream when a vendor filter excludes the firmware after
+// inspecting its metadata.
+var ErrSkipped = errors.New("firmware excluded by filter")
 
 type Vendor interface {
 	// FetchCatalog retrieves the catalog of firmware for the vendor.
 	FetchCatalog() (Catalog, error)
 	// RetrieveFirmware downloads the firmware file for the given firmware entry to tmpDir.
-	// For vendors like HPE, this step is required before processing.
 	RetrieveFirmware(entry FirmwareEntry, tmpDir string) error
 }
 
@@ -24,5 +35,16 @@ type FirmwareEntry interface {
 	// GetSourceURL returns the original download URL for this firmware
 	GetSourceURL() string
 	// ToAppstream converts this firmware entry to an AppStream component.
+	// It is called before RetrieveFirmware, and may return ErrNotRetrieved
+	// if the firmware file is needed for the conversion.
 	ToAppstream() (*lvfs.Component, error)
 }
+
+// CatalogRelease is implemented by firmware entries whose catalog already describes the
+// release, so that unchanged firmware can be skipped before anything is downloaded.
+type CatalogRelease interface {
+	// GetVersion returns the firmware version announced by the catalog
+	GetVersion() string
+	// GetGUIDs returns the GUIDs of the devices the firmware applies to
+	GetGUIDs() []string
+}
@@ -0,0 +1,139 @@
+package firmirror
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/criteo/firmirror/pkg/lvfs"
+)
+
+// checksumHashes are the checksum types VerifyRepository can check
+var checksumHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// CorruptObject is a stored file whose content doesn't match the checksums of the metadata
+type CorruptObject struct {
+	Key string
+	Err error
+}
+
+// VerifyReport lists the files checked by VerifyRepository
+type VerifyReport struct {
+	Verified   int             // Files matching their checksums
+	Unverified []string        // Stored files without container checksum, e.g. mirrored before they were recorded
+	Missing    []string        // Files not found in storage
+	Corrupt    []CorruptObject // Files not matching their checksums
+}
+
+// OK reports whether no file is missing or corrupt
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Corrupt) == 0
+}
+
+// VerifyRepository checks that the file of every release of the metadata in storage exists
+// and matches the container checksums of the release
+func VerifyRepository(ctx context.Context, storage Storage) (*VerifyReport, error) {
+	exists, err := storage.Exists(ctx, metadataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %s existence: %w", metadataKey, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%s not found in storage", metadataKey)
+	}
+
+	components, err := readMetadata(ctx, storage, metadataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{}
+	checked := map[string]bool{}
+	for _, component := range components.Component {
+		for _, release := range component.Releases {
+			// Absolute locations point to another server, e.g. the LVFS
+			if release.Location == "" || strings.Contains(release.Location, "://") || checked[release.Location] {
+				continue
+			}
+			checked[release.Location] = true
+
+			if err := verifyRelease(ctx, storage, release, report); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// verifyRelease checks the file of the release and adds the result to the report
+func verifyRelease(ctx context.Context, storage Storage, release lvfs.Release, report *VerifyReport) error {
+	key := release.Location
+
+	exists, err := storage.Exists(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to check %s existence: %w", key, err)
+	}
+	if !exists {
+		report.Missing = append(report.Missing, key)
+		return nil
+	}
+
+	var checksums []lvfs.Checksum
+	hashes := map[string]hash.Hash{}
+	for _, checksum := range release.Checksums {
+		newHash, ok := checksumHashes[checksum.Type]
+		if checksum.Target != lvfs.ChecksumContainer || !ok {
+			continue
+		}
+		if checksum.Filename != "" && checksum.Filename != path.Base(key) {
+			continue
+		}
+		checksums = append(checksums, checksum)
+		if hashes[checksum.Type] == nil {
+			hashes[checksum.Type] = newHash()
+		}
+	}
+	if len(checksums) == 0 {
+		report.Unverified = append(report.Unverified, key)
+		return nil
+	}
+
+	reader, err := storage.Read(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer reader.Close()
+
+	writers := make([]io.Writer, 0, len(hashes))
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), reader); err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	for _, checksum := range checksums {
+		actual := hex.EncodeToString(hashes[checksum.Type].Sum(nil))
+		if !strings.EqualFold(checksum.Value, actual) {
+			report.Corrupt = append(report.Corrupt, CorruptObject{
+				Key: key,
+				Err: fmt.Errorf("%w: expected %s %s, got %s", ErrChecksumMismatch, checksum.Type, checksum.Value, actual),
+			})
+			return nil
+		}
+	}
+
+	report.Verified++
+	return nil
+}
@@ -0,0 +1,80 @@
+package firmirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/criteo/firmirror/pkg/lvfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyRepository(t *testing.T) {
+	ctx := context.TODO()
+	sum := sha256.Sum256([]byte("cab")) // Content of the files stored by writeRepository
+	digest := hex.EncodeToString(sum[:])
+
+	release := func(location string, checksums ...lvfs.Checksum) lvfs.Release {
+		return lvfs.Release{Version: "1.0.0", Location: location, Checksums: checksums}
+	}
+	container := func(filename, value string) lvfs.Checksum {
+		return lvfs.Checksum{Filename: filename, Target: lvfs.ChecksumContainer, Type: "sha256", Value: value}
+	}
+
+	t.Run("ReportsObjects", func(t *testing.T) {
+		storage := newLocalStorage(t)
+		writeRepository(t, storage, []lvfs.Component{
+			{ID: "com.test.valid", Releases: []lvfs.Release{
+				release("valid.bin.cab",
+					lvfs.Checksum{Filename: "valid.bin", Target: lvfs.ChecksumContent, Type: "sha256", Value: "ignored"},
+					container("valid.bin.cab", strings.ToUpper(digest))),
+			}},
+			{ID: "com.test.shared", Releases: []lvfs.Release{
+				release("valid.bin.cab", container("valid.bin.cab", digest)),
+			}},
+			{ID: "com.test.corrupt", Releases: []lvfs.Release{
+				release("corrupt.bin.cab", container("corrupt.bin.cab", digest), container("corrupt.bin.cab", "0000")),
+			}},
+			{ID: "com.test.missing", Releases: []lvfs.Release{
+				release("missing.bin.cab", container("missing.bin.cab", digest)),
+			}},
+			{ID: "com.test.legacy", Releases: []lvfs.Release{
+				release("legacy.bin.cab", lvfs.Checksum{Filename: "legacy.bin", Target: lvfs.ChecksumContent, Type: "sha256", Value: digest}),
+			}},
+			{ID: "com.test.remote", Releases: []lvfs.Release{
+				release("https://fwupd.org/downloads/remote.cab", container("remote.cab", digest)),
+			}},
+		}, "valid.bin.cab", "corrupt.bin.cab", "legacy.bin.cab")
+
+		report, err := VerifyRepository(ctx, storage)
+		require.NoError(t, err, "Repository should be readable")
+		assert.Equal(t, 1, report.Verified, "Files shared by several releases should be verified once")
+		assert.Equal(t, []string{"missing.bin.cab"}, report.Missing, "Missing files should be reported")
+		assert.Equal(t, []string{"legacy.bin.cab"}, report.Unverified, "Files without container checksum should be reported")
+		require.Len(t, report.Corrupt, 1, "Corrupt files should be reported")
+		assert.Equal(t, "corrupt.bin.cab", report.Corrupt[0].Key)
+		assert.ErrorIs(t, report.Corrupt[0].Err, ErrChecksumMismatch)
+		assert.ErrorContains(t, report.Corrupt[0].Err, "expected sha256 0000, got "+digest)
+		assert.False(t, report.OK())
+	})
+
+	t.Run("ValidRepository", func(t *testing.T) {
+		storage := newLocalStorage(t)
+		writeRepository(t, storage, []lvfs.Component{
+			{ID: "com.test.valid", Releases: []lvfs.Release{release("valid.bin.cab", container("", digest))}},
+		}, "valid.bin.cab")
+
+		report, err := VerifyRepository(ctx, storage)
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Verified)
+		assert.True(t, report.OK(), "Repository should be intact")
+	})
+
+	t.Run("MissingMetadata", func(t *testing.T) {
+		_, err := VerifyRepository(ctx, newLocalStorage(t))
+		assert.ErrorContains(t, err, "metadata.xml.zst not found", "Missing metadata should be reported")
+	})
+}
@@ -0,0 +1,279 @@
+package lvfs
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// xmlNamespace is the namespace of the xml:lang attribute
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// Description is an AppStream description. Only paragraphs and lists are allowed by
+// the specification, so the markup is built by MarshalXML and is always well-formed.
+type Description struct {
+	Lang   string
+	Blocks []Block
+}
+
+// Block is either a paragraph, or a list when Items is set
+type Block struct {
+	Paragraph string
+	Items     []string
+	Ordered   bool
+}
+
+// IsList reports whether the block is a list
+func (b Block) IsList() bool {
+	return len(b.Items) > 0
+}
+
+// bulletPrefixes are the markers vendors use for list items in plain text
+var bulletPrefixes = []string{"- ", "* ", "• ", "· "}
+
+// NewDescription converts plain vendor text into a description. Blank lines separate
+// paragraphs, and consecutive lines starting with a bullet become a list.
+func NewDescription(lang, text string) Description {
+	text = SanitizeText(text)
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+
+	description := Description{Lang: lang}
+	var paragraph, items []string
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			description.Blocks = append(description.Blocks, Block{Paragraph: strings.Join(paragraph, " ")})
+			paragraph = nil
+		}
+	}
+	flushList := func() {
+		if len(items) > 0 {
+			description.Blocks = append(description.Blocks, Block{Items: items})
+			items = nil
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = collapseSpaces(line)
+		if line == "" {
+			flushParagraph()
+			flushList()
+			continue
+		}
+
+		if item, ok := listItem(line); ok {
+			flushParagraph()
+			items = append(items, item)
+			continue
+		}
+
+		if len(items) > 0 {
+			// A line following a list item without a bullet continues that item
+			items[len(items)-1] += " " + line
+			continue
+		}
+		paragraph = append(paragraph, line)
+	}
+	flushParagraph()
+	flushList()
+
+	return description
+}
+
+// listItem returns the text of a bulleted line
+func listItem(line string) (string, bool) {
+	for _, prefix := range bulletPrefixes {
+		if item, ok := strings.CutPrefix(line, prefix); ok && strings.TrimSpace(item) != "" {
+			return strings.TrimSpace(item), true
+		}
+	}
+	return "", false
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// SanitizeText drops invalid UTF-8 sequences and the characters XML 1.0 does not allow,
+// such as control characters, from vendor strings
+func SanitizeText(s string) string {
+	s = strings.ToValidUTF8(s, "")
+	return strings.Map(func(r rune) rune {
+		if !isXMLChar(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// isXMLChar reports whether r is in the Char production of the XML 1.0 specification
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
+
+// validateText returns an error if s would not be preserved in the metadata
+func validateText(s string) error {
+	if !utf8.ValidString(s) {
+		return errors.New("invalid UTF-8")
+	}
+	for _, r := range s {
+		if !isXMLChar(r) {
+			return fmt.Errorf("invalid XML character %U", r)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the description holds well-formed, non-empty blocks
+func (d Description) Validate() error {
+	if len(d.Blocks) == 0 {
+		return errors.New("empty description")
+	}
+	for _, block := range d.Blocks {
+		texts := block.Items
+		if !block.IsList() {
+			texts = []string{block.Paragraph}
+		}
+		for _, text := range texts {
+			if strings.TrimSpace(text) == "" {
+				return errors.New("empty paragraph or list item")
+			}
+			if err := validateText(text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d Description) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if d.Lang != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Space: xmlNamespace, Local: "lang"}, Value: d.Lang})
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, block := range d.Blocks {
+		if !block.IsList() {
+			if err := e.EncodeElement(block.Paragraph, xml.StartElement{Name: xml.Name{Local: "p"}}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		list := xml.StartElement{Name: xml.Name{Local: "ul"}}
+		if block.Ordered {
+			list.Name.Local = "ol"
+		}
+		if err := e.EncodeToken(list); err != nil {
+			return err
+		}
+		for _, item := range block.Items {
+			if err := e.EncodeElement(item, xml.StartElement{Name: xml.Name{Local: "li"}}); err != nil {
+				return err
+			}
+		}
+		if err := e.EncodeToken(list.End()); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalXML reads paragraphs and lists. Inline markup such as <em> is flattened into
+// its text, and text outside of any paragraph becomes a paragraph.
+func (d *Description) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "lang" && (attr.Name.Space == xmlNamespace || attr.Name.Space == "xml") {
+			d.Lang = attr.Value
+		}
+	}
+
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "ul" || t.Name.Local == "ol" {
+				items, err := decodeList(dec)
+				if err != nil {
+					return err
+				}
+				if len(items) > 0 {
+					d.Blocks = append(d.Blocks, Block{Items: items, Ordered: t.Name.Local == "ol"})
+				}
+				continue
+			}
+
+			text, err := decodeText(dec)
+			if err != nil {
+				return err
+			}
+			if text != "" {
+				d.Blocks = append(d.Blocks, Block{Paragraph: text})
+			}
+		case xml.CharData:
+			if text := collapseSpaces(string(t)); text != "" {
+				d.Blocks = append(d.Blocks, Block{Paragraph: text})
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// decodeList reads the items of a list until its end element
+func decodeList(dec *xml.Decoder) ([]string, error) {
+	var items []string
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch token.(type) {
+		case xml.StartElement:
+			text, err := decodeText(dec)
+			if err != nil {
+				return nil, err
+			}
+			if text != "" {
+				items = append(items, text)
+			}
+		case xml.EndElement:
+			return items, nil
+		}
+	}
+}
+
+// decodeText returns the text of an element, including the text of nested elements
+func decodeText(dec *xml.Decoder) (string, error) {
+	var text strings.Builder
+	for depth := 0; ; {
+		token, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if depth == 0 {
+				return collapseSpaces(text.String()), nil
+			}
+			depth--
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package lvfs
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDescription(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []Block
+	}{
+		{
+			name:     "Empty",
+			text:     "  \n ",
+			expected: nil,
+		},
+		{
+			name:     "SingleParagraph",
+			text:     "Initial release",
+			expected: []Block{{Paragraph: "Initial release"}},
+		},
+		{
+			name: "WrappedLinesAndParagraphs",
+			text: "Fixes an issue\r\nwith the fan   controller.\r\n\r\nReboot required.",
+			expected: []Block{
+				{Paragraph: "Fixes an issue with the fan controller."},
+				{Paragraph: "Reboot required."},
+			},
+		},
+		{
+			name: "List",
+			text: "Fixes:\n- Link flap on port 2\n* Wrong PCIe speed\n  reported by the BMC\n\nUpdate recommended.",
+			expected: []Block{
+				{Paragraph: "Fixes:"},
+				{Items: []string{"Link flap on port 2", "Wrong PCIe speed reported by the BMC"}},
+				{Paragraph: "Update recommended."},
+			},
+		},
+		{
+			name:     "InvalidCharacters",
+			text:     "Bad\x00 byte\xff and \x1bescape",
+			expected: []Block{{Paragraph: "Bad byte and escape"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			description := NewDescription("fr", test.text)
+			assert.Equal(t, "fr", description.Lang, "Language should be kept")
+			assert.Equal(t, test.expected, description.Blocks, "Blocks should match")
+		})
+	}
+}
+
+func TestDescription_MarshalXML(t *testing.T) {
+	description := Description{
+		Lang: "de",
+		Blocks: []Block{
+			{Paragraph: "Speed < 10Gb/s & <b>bold</b>"},
+			{Items: []string{"One", "Two & three"}},
+			{Items: []string{"First"}, Ordered: true},
+		},
+	}
+
+	out, err := xml.Marshal(struct {
+		XMLName     xml.Name    `xml:"release"`
+		Description Description `xml:"description"`
+	}{Description: description})
+	require.NoError(t, err, "Description should marshal")
+	assert.Equal(t, `<release><description xml:lang="de">`+
+		`<p>Speed &lt; 10Gb/s &amp; &lt;b&gt;bold&lt;/b&gt;</p>`+
+		`<ul><li>One</li><li>Two &amp; three</li></ul>`+
+		`<ol><li>First</li></ol>`+
+		`</description></release>`, string(out), "Markup should be escaped and well-formed")
+
+	var parsed struct {
+		Description Description `xml:"description"`
+	}
+	require.NoError(t, xml.Unmarshal(out, &parsed), "Description should unmarshal")
+	assert.Equal(t, description, parsed.Description, "Description should survive a round-trip")
+}
+
+func TestDescription_UnmarshalXML(t *testing.T) {
+	// Metadata written by previous versions, or by other tools, may contain inline markup
+	input := `<description xml:lang="ja">
+		Loose text
+		<p>Fixes an <em>important</em>
+		   issue</p>
+		<ul><li>One</li><li></li><li><code>two</code></li></ul>
+	</description>`
+
+	var description Description
+	require.NoError(t, xml.Unmarshal([]byte(input), &description), "Description should unmarshal")
+	assert.Equal(t, Description{
+		Lang: "ja",
+		Blocks: []Block{
+			{Paragraph: "Loose text"},
+			{Paragraph: "Fixes an important issue"},
+			{Items: []string{"One", "two"}},
+		},
+	}, description, "Inline markup should be flattened")
+}
+
+func TestSanitizeText(t *testing.T) {
+	assert.Equal(t, "tab\tnewline\nok", SanitizeText("tab\tnewline\nok"), "Whitespace should be kept")
+	assert.Equal(t, "caf", SanitizeText("caf\xe9"), "Invalid UTF-8 should be dropped")
+	assert.Equal(t, "beep", SanitizeText("be\x07ep￾"), "Characters XML forbids should be dropped")
+	assert.Equal(t, "日本語", SanitizeText("日本語"), "Valid text should be unchanged")
+}
+
+func TestComponent_Validate(t *testing.T) {
+	valid := func() *Component {
+		return &Component{
+			ID:          "com.hpe.network",
+			Name:        Translations{{Text: "Network"}},
+			Description: []Description{NewDescription("", "Network firmware")},
+			Releases: []Release{
+				{Version: "1.0", Description: []Description{NewDescription("", "Fixes")}},
+			},
+		}
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		assert.NoError(t, valid().Validate(), "Component should be valid")
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		var component *Component
+		assert.Error(t, component.Validate(), "Nil component should be invalid")
+	})
+
+	t.Run("InvalidText", func(t *testing.T) {
+		component := valid()
+		component.Name = Translations{{Text: "Bad\x00name"}}
+		assert.ErrorContains(t, component.Validate(), "name", "Invalid characters should be reported")
+	})
+
+	t.Run("EmptyDescription", func(t *testing.T) {
+		component := valid()
+		component.Releases[0].Description = []Description{{Lang: "fr"}}
+		assert.ErrorContains(t, component.Validate(), "release 1.0 description", "Empty descriptions should be reported")
+	})
+
+	t.Run("DuplicateLanguage", func(t *testing.T) {
+		component := valid()
+		component.Description = append(component.Description, NewDescription("", "Again"))
+		assert.ErrorContains(t, component.Validate(), "duplicate language", "Duplicate languages should be reported")
+	})
+}
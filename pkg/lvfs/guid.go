@@ -0,0 +1,44 @@
+package lvfs
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// GUIDFromInstanceID returns the GUID fwupd derives from a device instance ID,
+// e.g. "REDFISH\VENDOR_Dell&SOFTWAREID_159"
+func GUIDFromInstanceID(instanceID string) string {
+	return uuid.NewSHA1(uuid.NameSpaceDNS, []byte(instanceID)).String()
+}
+
+// GUIDsFromInstanceIDs returns the GUIDs of the instance IDs without duplicates. As in fwupd,
+// instance IDs which already are GUIDs are used as-is.
+func GUIDsFromInstanceIDs(instanceIDs []string) []string {
+	var guids []string
+	for _, instanceID := range instanceIDs {
+		guid := NormalizeGUID(instanceID)
+		if !IsGUID(guid) {
+			guid = GUIDFromInstanceID(instanceID)
+		}
+		if !slices.Contains(guids, guid) {
+			guids = append(guids, guid)
+		}
+	}
+	return guids
+}
+
+// IsGUID reports whether s is a GUID in its canonical 8-4-4-4-12 form
+func IsGUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	_, err := uuid.Parse(s)
+	return err == nil
+}
+
+// NormalizeGUID returns the lowercase form fwupd uses for GUIDs
+func NormalizeGUID(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
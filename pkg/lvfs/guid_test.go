@@ -0,0 +1,26 @@
+package lvfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGUIDFromInstanceID(t *testing.T) {
+	// Test vector of fwupd_guid_hash_string in the fwupd self tests, also the RFC 4122 name-based
+	// UUID of python.org in the DNS namespace
+	assert.Equal(t, "886313e1-3b8a-5372-9b90-0c9aee199e5d", GUIDFromInstanceID("python.org"))
+}
+
+func TestGUIDsFromInstanceIDs(t *testing.T) {
+	guids := GUIDsFromInstanceIDs([]string{
+		"python.org",
+		"A0B1C2D3-E4F5-4A5B-8C9D-0E1F2A3B4C5D",
+		"a0b1c2d3-e4f5-4a5b-8c9d-0e1f2a3b4c5d",
+		"python.org",
+	})
+	assert.Equal(t, []string{
+		"886313e1-3b8a-5372-9b90-0c9aee199e5d",
+		"a0b1c2d3-e4f5-4a5b-8c9d-0e1f2a3b4c5d",
+	}, guids, "GUIDs should be used as-is, lowercased, without duplicates")
+}
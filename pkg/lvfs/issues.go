@@ -0,0 +1,63 @@
+package lvfs
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// issuePatterns maps the AppStream issue type of each advisory identifier format. Only the
+// types fwupd and the LVFS recognize are used: HPE security bulletins such as HPESBHF04456 have
+// none, and are published through the CVEs they reference.
+var issuePatterns = []struct {
+	Type    string
+	Pattern *regexp.Regexp
+}{
+	{Type: "cve", Pattern: regexp.MustCompile(`(?i)\bCVE-\d{4}-\d{4,}\b`)},
+	{Type: "dell", Pattern: regexp.MustCompile(`(?i)\bDSA-\d{4}-\d{3,}\b`)},
+}
+
+// IssueType returns the issue type of an advisory identifier such as "CVE-2024-1234" or
+// "DSA-2024-001", or false if the identifier is not recognized
+func IssueType(id string) (string, bool) {
+	id = strings.TrimSpace(id)
+	for _, p := range issuePatterns {
+		if id != "" && p.Pattern.FindString(id) == id {
+			return p.Type, true
+		}
+	}
+	return "", false
+}
+
+// ExtractIssues returns the advisory identifiers mentioned in the given texts, uppercased
+// and in order of appearance
+func ExtractIssues(texts ...string) []Issue {
+	var issues []Issue
+	for _, text := range texts {
+		// Collect the matches of every pattern, then sort them by position
+		type match struct {
+			pos   int
+			issue Issue
+		}
+		var matches []match
+		for _, p := range issuePatterns {
+			for _, loc := range p.Pattern.FindAllStringIndex(text, -1) {
+				matches = append(matches, match{pos: loc[0], issue: Issue{Type: p.Type, Text: strings.ToUpper(text[loc[0]:loc[1]])}})
+			}
+		}
+		slices.SortStableFunc(matches, func(a, b match) int { return a.pos - b.pos })
+
+		for _, m := range matches {
+			issues = AddIssue(issues, m.issue)
+		}
+	}
+	return issues
+}
+
+// AddIssue appends issue to issues unless it is already present
+func AddIssue(issues []Issue, issue Issue) []Issue {
+	if slices.Contains(issues, issue) {
+		return issues
+	}
+	return append(issues, issue)
+}
@@ -0,0 +1,62 @@
+package lvfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractIssues(t *testing.T) {
+	tests := []struct {
+		name     string
+		texts    []string
+		expected []Issue
+	}{
+		{
+			name:     "None",
+			texts:    []string{"Fixes an issue with the fan controller", ""},
+			expected: nil,
+		},
+		{
+			name:  "OrderOfAppearance",
+			texts: []string{"Addresses DSA-2024-001 (cve-2023-45678, CVE-2024-1234)."},
+			expected: []Issue{
+				{Type: "dell", Text: "DSA-2024-001"},
+				{Type: "cve", Text: "CVE-2023-45678"},
+				{Type: "cve", Text: "CVE-2024-1234"},
+			},
+		},
+		{
+			name:  "DuplicatesAcrossTexts",
+			texts: []string{"See HPESBHF04456 for CVE-2024-1234", "CVE-2024-1234 is fixed"},
+			expected: []Issue{
+				{Type: "cve", Text: "CVE-2024-1234"},
+			},
+		},
+		{
+			name:     "PartialIdentifiers",
+			texts:    []string{"CVE-24-1234, XCVE-2024-1234 and HPESBHF123 are not advisories"},
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, ExtractIssues(test.texts...), "Issues should match")
+		})
+	}
+}
+
+func TestIssueType(t *testing.T) {
+	for id, expected := range map[string]string{
+		"CVE-2024-1234":    "cve",
+		" dsa-2024-001 ":   "dell",
+		"HPESBHF04456":     "",
+		"CVE-2024-1234 ok": "",
+		"GHSA-1234":        "",
+	} {
+		issueType, ok := IssueType(id)
+		assert.Equal(t, expected != "", ok, "Recognition of %q should match", id)
+		assert.Equal(t, expected, issueType, "Type of %q should match", id)
+	}
+}
@@ -0,0 +1,75 @@
+package lvfs
+
+import "strings"
+
+// Translation is a localized value. An empty Lang is the untranslated, English value.
+type Translation struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+// Translations holds every localized variant of a value, the untranslated one first
+type Translations []Translation
+
+// String returns the untranslated value
+func (t Translations) String() string {
+	for _, translation := range t {
+		if translation.Lang == "" {
+			return translation.Text
+		}
+	}
+	return ""
+}
+
+// languageAliases maps vendor-specific language codes to their locale
+var languageAliases = map[string]string{
+	"cn": "zh_CN",
+	"tw": "zh_TW",
+	"jp": "ja",
+	"kr": "ko",
+}
+
+// NormalizeLang returns the xml:lang locale of a vendor language code, such as
+// "zh_CN" for "cn" or "zh-cn". English is the default language and returns "".
+func NormalizeLang(lang string) string {
+	lang = strings.ReplaceAll(strings.TrimSpace(lang), "-", "_")
+	if alias, ok := languageAliases[strings.ToLower(lang)]; ok {
+		return alias
+	}
+
+	language, region, found := strings.Cut(lang, "_")
+	language = strings.ToLower(language)
+	if language == "en" {
+		return ""
+	}
+	if !found {
+		return language
+	}
+	return language + "_" + strings.ToUpper(region)
+}
+
+// Descriptions returns the description of each translation, see NewDescription
+func (t Translations) Descriptions() []Description {
+	descriptions := make([]Description, 0, len(t))
+	for _, translation := range t {
+		description := NewDescription(translation.Lang, translation.Text)
+		if len(description.Blocks) == 0 {
+			continue
+		}
+		descriptions = append(descriptions, description)
+	}
+	return descriptions
+}
+
+// Customs returns a custom value with the given key for each translation
+func (t Translations) Customs(key string) []Custom {
+	customs := make([]Custom, 0, len(t))
+	for _, translation := range t {
+		customs = append(customs, Custom{
+			Key:   key,
+			Lang:  translation.Lang,
+			Value: translation.Text,
+		})
+	}
+	return customs
+}
@@ -5,32 +5,40 @@ import "encoding/xml"
 type Components struct {
 	XMLName   xml.Name    `xml:"components"`
 	Origin    string      `xml:"origin,attr"`
+	Version   string      `xml:"version,attr,omitempty"`
 	Component []Component `xml:"component"`
 }
 
 type Component struct {
-	XMLName           xml.Name    `xml:"component"`
-	Type              string      `xml:"type,attr"`
-	ID                string      `xml:"id"`
-	Name              string      `xml:"name"`
-	NameVariantSuffix string      `xml:"name_variant_suffix,omitempty"`
-	Summary           string      `xml:"summary"`
-	DeveloperName     string      `xml:"developer_name,omitempty"`
-	Description       Description `xml:"description"`
-	Provides          []Firmware  `xml:"provides>firmware"`
-	URL               URL         `xml:"url,omitempty"`
-	MetadataLicense   string      `xml:"metadata_license"`
-	ProjectLicense    string      `xml:"project_license"`
-	Releases          []Release   `xml:"releases>release"`
-	Requires          Requires    `xml:"requires,omitempty"`
-	Custom            []Custom    `xml:"custom>value,omitempty"`
-	Keywords          []string    `xml:"keywords>keyword,omitempty"`
-	Categories        []string    `xml:"categories>category,omitempty"`
+	XMLName           xml.Name      `xml:"component"`
+	Type              string        `xml:"type,attr"`
+	ID                string        `xml:"id"`
+	Name              Translations  `xml:"name"`
+	NameVariantSuffix Translations  `xml:"name_variant_suffix,omitempty"`
+	Summary           Translations  `xml:"summary"`
+	DeveloperName     string        `xml:"developer_name,omitempty"`
+	Description       []Description `xml:"description"`
+	Branch            string        `xml:"branch,omitempty"` // Firmware stream of all the releases, e.g. "community"
+	Provides          []Firmware    `xml:"provides>firmware"`
+	URLs              []URL         `xml:"url"`
+	MetadataLicense   string        `xml:"metadata_license"`
+	ProjectLicense    string        `xml:"project_license"`
+	Releases          []Release     `xml:"releases>release"`
+	Requires          Requires      `xml:"requires,omitempty"`
+	Replaces          []string      `xml:"replaces>id,omitempty"`
+	Screenshots       []Screenshot  `xml:"screenshots>screenshot,omitempty"`
+	Custom            []Custom      `xml:"custom>value,omitempty"`
+	Keywords          []string      `xml:"keywords>keyword,omitempty"`
+	Categories        []string      `xml:"categories>category,omitempty"`
+	Tags              []Tag         `xml:"tags>tag,omitempty"`
 }
 
 type Firmware struct {
-	Type string `xml:"type,attr"`
-	Text string `xml:",chardata"`
+	Type    string `xml:"type,attr,omitempty"`
+	Compare string `xml:"compare,attr,omitempty"`
+	Version string `xml:"version,attr,omitempty"`
+	Depth   string `xml:"depth,attr,omitempty"` // Parent (1) or child (-1) device of a requirement
+	Text    string `xml:",chardata"`
 }
 
 type URL struct {
@@ -38,24 +46,62 @@ type URL struct {
 	Text string `xml:",chardata"`
 }
 
+// Urgencies are the valid values of the urgency of a release, from lowest to highest
+var Urgencies = []string{"low", "medium", "high", "critical"}
+
 type Release struct {
-	Urgency         string      `xml:"urgency,attr,omitempty"`
-	Version         string      `xml:"version,attr"`
-	Date            string      `xml:"date,attr"`
-	InstallDuration int         `xml:"install_duration,attr"`
-	Location        string      `xml:"location,omitempty"`
-	Checksums       []Checksum  `xml:"checksum"`
-	Description     Description `xml:"description"`
-	Issues          []Issue     `xml:"issues>issue,omitempty"`
+	Urgency         string        `xml:"urgency,attr,omitempty"`
+	Version         string        `xml:"version,attr"`
+	Date            string        `xml:"date,attr"`
+	Timestamp       int64         `xml:"timestamp,attr,omitempty"`
+	InstallDuration int           `xml:"install_duration,attr"`
+	Location        string        `xml:"location,omitempty"`
+	Checksums       []Checksum    `xml:"checksum"`
+	Description     []Description `xml:"description"`
+	Issues          []Issue       `xml:"issues>issue,omitempty"`
+	URLs            []URL         `xml:"url"`
+	Sizes           []Size        `xml:"size"`
+	Artifacts       []Artifact    `xml:"artifacts>artifact,omitempty"`
+	Tags            []Tag         `xml:"tags>tag,omitempty"`
 }
 
+// Checksum targets
+const (
+	ChecksumContainer = "container" // The cabinet archive
+	ChecksumContent   = "content"   // The firmware payload inside the archive
+	ChecksumDevice    = "device"    // The firmware as reported by the device once installed
+	ChecksumSignature = "signature" // The detached signature of the payload
+)
+
 type Checksum struct {
-	Filename string `xml:"filename,attr"`
-	Target   string `xml:"target,attr"`
+	Filename string `xml:"filename,attr,omitempty"`
+	Target   string `xml:"target,attr,omitempty"`
 	Type     string `xml:"type,attr,omitempty"`
 	Value    string `xml:",chardata"`
 }
 
+// Size is the "installed" or "download" size of a release or artifact, in bytes
+type Size struct {
+	Type  string `xml:"type,attr"`
+	Value int64  `xml:",chardata"`
+}
+
+// Artifact is a downloadable file of a release, either the "binary" cabinet archive or
+// its "source"
+type Artifact struct {
+	Type      string     `xml:"type,attr"`
+	Locations []string   `xml:"location"`
+	Filename  string     `xml:"filename,omitempty"`
+	Checksums []Checksum `xml:"checksum"`
+	Sizes     []Size     `xml:"size"`
+}
+
+// Tag is a free-form label, such as the LVFS tag of a validated release
+type Tag struct {
+	Namespace string `xml:"namespace,attr,omitempty"`
+	Text      string `xml:",chardata"`
+}
+
 type Issue struct {
 	Type string `xml:"type,attr"`
 	Text string `xml:",chardata"`
@@ -64,6 +110,8 @@ type Issue struct {
 type Requires struct {
 	ID       []ID       `xml:"id"`
 	Firmware []Firmware `xml:"firmware"`
+	Hardware []string   `xml:"hardware"` // Computer hardware IDs, alternatives separated by "|"
+	Client   []string   `xml:"client"`   // Features the fwupd client must support, e.g. "detach-action"
 }
 
 type ID struct {
@@ -74,9 +122,42 @@ type ID struct {
 
 type Custom struct {
 	Key   string `xml:"key,attr"`
+	Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
 	Value string `xml:",chardata"`
 }
 
-type Description struct {
-	Value string `xml:",innerxml"`
+// CustomValue returns the custom value of the component with the given key, or "" when unset
+func (c *Component) CustomValue(key string) string {
+	for _, custom := range c.Custom {
+		if custom.Key == key {
+			return custom.Value
+		}
+	}
+	return ""
+}
+
+// SetCustomValue sets the custom value of the component with the given key, replacing any
+// previous one
+func (c *Component) SetCustomValue(key, value string) {
+	for i, custom := range c.Custom {
+		if custom.Key == key {
+			c.Custom[i].Value = value
+			return
+		}
+	}
+	c.Custom = append(c.Custom, Custom{Key: key, Value: value})
+}
+
+// Screenshot illustrates manual steps, such as the button to press to enter bootloader mode
+type Screenshot struct {
+	Type    string       `xml:"type,attr,omitempty"`
+	Caption Translations `xml:"caption,omitempty"`
+	Images  []Image      `xml:"image"`
+}
+
+type Image struct {
+	Type   string `xml:"type,attr,omitempty"`
+	Width  int    `xml:"width,attr,omitempty"`
+	Height int    `xml:"height,attr,omitempty"`
+	Text   string `xml:",chardata"`
 }
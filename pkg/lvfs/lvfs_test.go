@@ -0,0 +1,99 @@
+package lvfs
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadMetadata(t *testing.T, data []byte) Components {
+	var components Components
+	require.NoError(t, xml.Unmarshal(data, &components), "Metadata should unmarshal")
+	return components
+}
+
+func TestComponents_RoundTrip(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "metadata.xml"))
+	require.NoError(t, err, "Should be able to read test metadata")
+
+	components := loadMetadata(t, data)
+	require.Len(t, components.Component, 2, "Should parse every component")
+	assert.Equal(t, "lvfs", components.Origin, "Origin should match")
+	assert.Equal(t, "0.9", components.Version, "Version should match")
+
+	t.Run("Component", func(t *testing.T) {
+		comp := components.Component[0]
+		assert.Equal(t, Translations{{Text: "Bootloader"}, {Lang: "de", Text: "Urlader"}}, comp.NameVariantSuffix, "Name variant suffix should be translated")
+		assert.Equal(t, "Firmware for the Hughski ColorHug2 Colorimeter", comp.Summary.String(), "Summary should match")
+		assert.Equal(t, "community", comp.Branch, "Branch should match")
+		assert.Equal(t, []URL{
+			{Type: "homepage", Text: "http://www.hughski.com/"},
+			{Type: "vcs-browser", Text: "https://github.com/hughski/colorhug2-firmware"},
+		}, comp.URLs, "Every URL should be kept")
+		assert.Equal(t, []Tag{{Namespace: "lvfs", Text: "vendor-hughski"}}, comp.Tags, "Tags should match")
+		assert.Equal(t, "La mise à jour du micrologiciel améliore les performances & ajoute des fonctionnalités.", comp.Description[1].Blocks[0].Paragraph, "Description should be unescaped")
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		release := components.Component[0].Releases[0]
+		assert.Equal(t, int64(1486598400), release.Timestamp, "Timestamp should match")
+		assert.Equal(t, Checksum{Target: ChecksumDevice, Type: "sha256", Value: "5a9a5a6b9b6f9e2f0a1e8b4c1a0f4f4b2c9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f"}, release.Checksums[3], "Device checksum should match")
+		assert.Equal(t, []Size{{Type: "installed", Value: 14336}, {Type: "download", Value: 8192}}, release.Sizes, "Sizes should match")
+		assert.Equal(t, []string{"Fix a crash when reading the sensor", "Use the DFU runtime"}, release.Description[0].Blocks[1].Items, "List should match")
+		assert.Equal(t, []Tag{{Namespace: "lvfs", Text: "hughski-2017q1"}}, release.Tags, "Release tags should match")
+		assert.Len(t, release.URLs, 2, "Release URLs should match")
+
+		require.Len(t, release.Artifacts, 2, "Artifacts should match")
+		binary := release.Artifacts[0]
+		assert.Equal(t, "binary", binary.Type, "Artifact type should match")
+		assert.Len(t, binary.Locations, 2, "Every artifact location should be kept")
+		assert.Equal(t, "hughski-colorhug2-2.0.7.cab", binary.Filename, "Artifact filename should match")
+		assert.Len(t, binary.Checksums, 2, "Artifact checksums should match")
+		assert.Equal(t, []Size{{Type: "download", Value: 8192}}, binary.Sizes, "Artifact size should match")
+	})
+
+	t.Run("Requires", func(t *testing.T) {
+		requires := components.Component[0].Requires
+		assert.Equal(t, []ID{{Compare: "ge", Version: "1.2.10", Text: "org.freedesktop.fwupd"}}, requires.ID, "ID requirements should match")
+		assert.Equal(t, []Firmware{
+			{Compare: "ge", Version: "0.0.1", Text: "bootloader"},
+			{Depth: "1", Text: "3f3ceb4b-5e4f-5e0b-8b2d-7d2e1b6b7a19"},
+		}, requires.Firmware, "Firmware requirements should match")
+		assert.Equal(t, []string{"6de5d951-d755-576b-bd09-c5cf66b27234|f4c2a0a3-5c7e-5a40-8d17-2b9b1c8f3d4a"}, requires.Hardware, "Hardware requirements should match")
+		assert.Equal(t, []string{"detach-action"}, requires.Client, "Client requirements should match")
+	})
+
+	t.Run("Screenshots", func(t *testing.T) {
+		require.Len(t, components.Component[0].Screenshots, 1, "Screenshots should match")
+		screenshot := components.Component[0].Screenshots[0]
+		assert.Equal(t, "default", screenshot.Type, "Screenshot type should match")
+		assert.Len(t, screenshot.Caption, 2, "Caption should be translated")
+		assert.Equal(t, []Image{{Type: "source", Width: 800, Height: 600, Text: "https://fwupd.org/img/colorhug2-button.png"}}, screenshot.Images, "Images should match")
+	})
+
+	t.Run("Replaces", func(t *testing.T) {
+		assert.Equal(t, []string{"com.dell.e7f0b8a4-5cd2-5b6c-9a5f-8a27bbd0f6f1"}, components.Component[1].Replaces, "Replaced IDs should match")
+	})
+
+	t.Run("MarshalAndUnmarshal", func(t *testing.T) {
+		out, err := xml.MarshalIndent(components, "", "  ")
+		require.NoError(t, err, "Metadata should marshal")
+
+		assert.Equal(t, components, loadMetadata(t, out), "Metadata should survive a round-trip")
+		assert.Contains(t, string(out), `<checksum target="device" type="sha256">`, "Device checksum should not have an empty filename")
+		assert.Contains(t, string(out), `<name_variant_suffix xml:lang="de">Urlader</name_variant_suffix>`, "Translations should be marshalled")
+	})
+}
+
+func TestComponents_MarshalOmitsEmpty(t *testing.T) {
+	out, err := xml.Marshal(Component{Type: "firmware", ID: "com.example.firmware"})
+	require.NoError(t, err, "Component should marshal")
+
+	for _, element := range []string{"<url", "<name_variant_suffix", "<branch", "<size", "<screenshot>", "<tag>"} {
+		assert.NotContains(t, string(out), element, "Empty %s should be omitted", element)
+	}
+}
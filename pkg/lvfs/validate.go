@@ -0,0 +1,197 @@
+package lvfs
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Validate checks that the text and markup of the component can be written to the
+// metadata as-is, so a single bad component is rejected instead of corrupting the
+// whole repository
+func (c *Component) Validate() error {
+	if c == nil {
+		return errors.New("empty component")
+	}
+
+	fields := map[string]string{
+		"id":             c.ID,
+		"developer_name": c.DeveloperName,
+	}
+	for _, translation := range c.Name {
+		fields["name["+translation.Lang+"]"] = translation.Text
+	}
+	for _, translation := range c.NameVariantSuffix {
+		fields["name_variant_suffix["+translation.Lang+"]"] = translation.Text
+	}
+	for _, translation := range c.Summary {
+		fields["summary["+translation.Lang+"]"] = translation.Text
+	}
+	for _, custom := range c.Custom {
+		fields["custom["+custom.Key+"]"] = custom.Value
+	}
+	for _, keyword := range c.Keywords {
+		if err := validateText(keyword); err != nil {
+			return fmt.Errorf("keyword: %w", err)
+		}
+	}
+	for name, value := range fields {
+		if err := validateText(value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if err := validateDescriptions(c.Description); err != nil {
+		return fmt.Errorf("description: %w", err)
+	}
+	for _, release := range c.Releases {
+		if err := validateDescriptions(release.Description); err != nil {
+			return fmt.Errorf("release %s description: %w", release.Version, err)
+		}
+	}
+
+	return nil
+}
+
+func validateDescriptions(descriptions []Description) error {
+	langs := map[string]bool{}
+	for _, description := range descriptions {
+		if langs[description.Lang] {
+			return fmt.Errorf("duplicate language %q", description.Lang)
+		}
+		langs[description.Lang] = true
+
+		if err := description.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateSchema checks that the component holds everything fwupd needs to install its
+// releases, on top of the text checks of Validate. All the problems are reported at once.
+func (c *Component) ValidateSchema() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	var errs []error
+	if c.Type != "firmware" {
+		errs = append(errs, fmt.Errorf("type: expected firmware, got %q", c.Type))
+	}
+	if c.ID == "" {
+		errs = append(errs, errors.New("id: missing"))
+	}
+	if c.Name.String() == "" {
+		errs = append(errs, errors.New("name: missing"))
+	}
+	if c.Summary.String() == "" {
+		errs = append(errs, errors.New("summary: missing"))
+	}
+
+	if len(c.Provides) == 0 {
+		errs = append(errs, errors.New("provides: no device GUID"))
+	}
+	for _, provide := range c.Provides {
+		if !IsGUID(provide.Text) {
+			errs = append(errs, fmt.Errorf("provides: invalid GUID %q", provide.Text))
+		}
+	}
+	for _, hardware := range c.Requires.Hardware {
+		for _, guid := range strings.Split(hardware, "|") {
+			if !IsGUID(guid) {
+				errs = append(errs, fmt.Errorf("requires: invalid hardware GUID %q", guid))
+			}
+		}
+	}
+
+	if len(c.Releases) == 0 {
+		errs = append(errs, errors.New("releases: no release"))
+	}
+	format := c.VersionFormat()
+	if format != "" && !slices.Contains(VersionFormats, format) {
+		errs = append(errs, fmt.Errorf("custom: unknown version format %q", format))
+	}
+	versions := map[string]bool{}
+	for _, release := range c.Releases {
+		if err := release.ValidateSchema(format); err != nil {
+			errs = append(errs, err)
+		}
+		if versions[release.Version] {
+			errs = append(errs, fmt.Errorf("release %s: duplicate version", release.Version))
+		}
+		versions[release.Version] = true
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateSchema checks that fwupd can install the release, its version being compared
+// with the version format of its component
+func (r *Release) ValidateSchema(format string) error {
+	var errs []error
+	if err := validateDescriptions(r.Description); err != nil {
+		errs = append(errs, fmt.Errorf("release %s description: %w", r.Version, err))
+	}
+	if !ValidVersion(r.Version, format) {
+		errs = append(errs, fmt.Errorf("release %q: invalid version", r.Version))
+	}
+	if r.Location == "" {
+		errs = append(errs, fmt.Errorf("release %s: missing location", r.Version))
+	}
+	return errors.Join(errs...)
+}
+
+// splitReleases splits a copy of the component between the releases passing
+// Release.ValidateSchema and the others, including the ones reusing a previous version
+func (c *Component) splitReleases() (kept, rejected Component) {
+	kept, rejected = *c, *c
+	kept.Releases, rejected.Releases = nil, nil
+
+	format := c.VersionFormat()
+	versions := map[string]bool{}
+	for _, release := range c.Releases {
+		if release.ValidateSchema(format) != nil || versions[release.Version] {
+			rejected.Releases = append(rejected.Releases, release)
+			continue
+		}
+		versions[release.Version] = true
+		kept.Releases = append(kept.Releases, release)
+	}
+	return kept, rejected
+}
+
+// InvalidComponent is a component rejected by ValidateComponents
+type InvalidComponent struct {
+	Component Component
+	Err       error
+}
+
+// ValidateComponents splits components between the ones that can be published and the
+// ones failing ValidateSchema or reusing the ID of a previous component. A component whose
+// only problems are some of its releases is kept without them, the dropped releases being
+// reported as an invalid copy of the component.
+func ValidateComponents(components []Component) (valid []Component, invalid []InvalidComponent) {
+	ids := map[string]bool{}
+	for _, component := range components {
+		err := component.ValidateSchema()
+		if err != nil {
+			kept, rejected := component.splitReleases()
+			if len(kept.Releases) > 0 && len(rejected.Releases) > 0 && kept.ValidateSchema() == nil {
+				invalid = append(invalid, InvalidComponent{Component: rejected, Err: err})
+				component, err = kept, nil
+			}
+		}
+		if err == nil && ids[component.ID] {
+			err = fmt.Errorf("id: duplicate %q", component.ID)
+		}
+		if err != nil {
+			invalid = append(invalid, InvalidComponent{Component: component, Err: err})
+			continue
+		}
+		ids[component.ID] = true
+		valid = append(valid, component)
+	}
+	return valid, invalid
+}
@@ -0,0 +1,170 @@
+package lvfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComponent_ValidateSchema(t *testing.T) {
+	valid := func() *Component {
+		return &Component{
+			Type:     "firmware",
+			ID:       "com.hpe.network",
+			Name:     Translations{{Text: "Network"}},
+			Summary:  Translations{{Text: "Network firmware"}},
+			Provides: []Firmware{{Type: "flashed", Text: "dadc32f0-d6fc-575c-bad8-140b0e1d6850"}},
+			Releases: []Release{
+				{Version: "1.0", Location: "network-1.0.cab"},
+				{Version: "2.0", Location: "network-2.0.cab"},
+			},
+		}
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		assert.NoError(t, valid().ValidateSchema(), "Component should be valid")
+	})
+
+	t.Run("PlainVersions", func(t *testing.T) {
+		component := valid()
+		component.Releases[0].Version = "A09"
+		component.Releases[1].Version = "A12"
+		assert.Error(t, component.ValidateSchema(), "Versions should be semver-like without format")
+
+		component.SetVersionFormat(VersionFormatPlain)
+		assert.NoError(t, component.ValidateSchema(), "Plain versions should be accepted with their format")
+	})
+
+	t.Run("InvalidText", func(t *testing.T) {
+		component := valid()
+		component.Summary = Translations{{Text: "Bad\x00summary"}}
+		assert.ErrorContains(t, component.ValidateSchema(), "summary", "Text checks should run first")
+	})
+
+	tests := []struct {
+		name   string
+		modify func(c *Component)
+		errors []string
+	}{
+		{
+			name:   "MissingFields",
+			modify: func(c *Component) { c.Type, c.ID, c.Name, c.Summary = "", "", nil, nil },
+			errors: []string{"type: expected firmware", "id: missing", "name: missing", "summary: missing"},
+		},
+		{
+			name:   "NoProvides",
+			modify: func(c *Component) { c.Provides = nil },
+			errors: []string{"provides: no device GUID"},
+		},
+		{
+			name: "InvalidGUIDs",
+			modify: func(c *Component) {
+				c.Provides = append(c.Provides, Firmware{Type: "flashed", Text: "guid-1"})
+				c.Requires.Hardware = []string{"dadc32f0-d6fc-575c-bad8-140b0e1d6850|not-a-guid"}
+			},
+			errors: []string{`provides: invalid GUID "guid-1"`, `requires: invalid hardware GUID "not-a-guid"`},
+		},
+		{
+			name:   "VersionFormatMismatch",
+			modify: func(c *Component) { c.SetVersionFormat(VersionFormatTriplet) },
+			errors: []string{`release "1.0": invalid version`, `release "2.0": invalid version`},
+		},
+		{
+			name:   "UnknownVersionFormat",
+			modify: func(c *Component) { c.SetVersionFormat("dell-bios") },
+			errors: []string{`custom: unknown version format "dell-bios"`},
+		},
+		{
+			name:   "NoRelease",
+			modify: func(c *Component) { c.Releases = nil },
+			errors: []string{"releases: no release"},
+		},
+		{
+			name: "InvalidReleases",
+			modify: func(c *Component) {
+				c.Releases[0].Version = "A08"
+				c.Releases[1].Location = ""
+				c.Releases = append(c.Releases, Release{Version: "2.0", Location: "network-2.0-bis.cab"})
+			},
+			errors: []string{`release "A08": invalid version`, "release 2.0: missing location", "release 2.0: duplicate version"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			component := valid()
+			tt.modify(component)
+
+			err := component.ValidateSchema()
+			require.Error(t, err, "Component should be invalid")
+			for _, msg := range tt.errors {
+				assert.ErrorContains(t, err, msg, "Every problem should be reported")
+			}
+		})
+	}
+}
+
+func TestValidateComponents(t *testing.T) {
+	t.Run("Metadata", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("testdata", "metadata.xml"))
+		require.NoError(t, err, "Should be able to read test metadata")
+		components := loadMetadata(t, data)
+
+		valid, invalid := ValidateComponents(components.Component)
+		assert.Len(t, valid, 2, "LVFS metadata should be valid")
+		assert.Empty(t, invalid, "LVFS metadata should be valid")
+	})
+
+	t.Run("RejectsInvalidAndDuplicates", func(t *testing.T) {
+		component := Component{
+			Type:     "firmware",
+			ID:       "com.dell.network",
+			Name:     Translations{{Text: "Network"}},
+			Summary:  Translations{{Text: "Network firmware"}},
+			Provides: []Firmware{{Type: "flashed", Text: "dadc32f0-d6fc-575c-bad8-140b0e1d6850"}},
+			Releases: []Release{{Version: "1.0", Location: "network-1.0.cab"}},
+		}
+		broken := component
+		broken.ID = "com.dell.broken"
+		broken.Releases = nil
+
+		valid, invalid := ValidateComponents([]Component{component, broken, component})
+		assert.Equal(t, []Component{component}, valid, "Only the first valid component should be kept")
+		require.Len(t, invalid, 2, "Invalid components should be reported")
+		assert.Equal(t, "com.dell.broken", invalid[0].Component.ID, "Broken component should be rejected")
+		assert.ErrorContains(t, invalid[0].Err, "no release", "Reason should be given")
+		assert.ErrorContains(t, invalid[1].Err, `id: duplicate "com.dell.network"`, "Duplicate should be rejected")
+	})
+
+	t.Run("DropsInvalidReleases", func(t *testing.T) {
+		component := Component{
+			Type:     "firmware",
+			ID:       "com.dell.network",
+			Name:     Translations{{Text: "Network"}},
+			Summary:  Translations{{Text: "Network firmware"}},
+			Provides: []Firmware{{Type: "flashed", Text: "dadc32f0-d6fc-575c-bad8-140b0e1d6850"}},
+			Releases: []Release{
+				{Version: "A08", Location: "network-A08.cab"},
+				{Version: "1.0", Location: "network-1.0.cab"},
+				{Version: "1.0", Location: "network-1.0-bis.cab"},
+			},
+		}
+
+		valid, invalid := ValidateComponents([]Component{component})
+		require.Len(t, valid, 1, "Component should be kept with its valid release")
+		assert.Equal(t, []Release{{Version: "1.0", Location: "network-1.0.cab"}}, valid[0].Releases, "Only the valid release should be kept")
+		require.Len(t, invalid, 1, "Dropped releases should be reported")
+		assert.Equal(t, "com.dell.network", invalid[0].Component.ID, "Dropped releases should keep their component")
+		assert.Equal(t, []Release{component.Releases[0], component.Releases[2]}, invalid[0].Component.Releases, "Invalid and duplicate releases should be dropped")
+		assert.ErrorContains(t, invalid[0].Err, `release "A08": invalid version`, "Reason should be given")
+
+		component.Releases = component.Releases[:1]
+		valid, invalid = ValidateComponents([]Component{component})
+		assert.Empty(t, valid, "Component without valid release should be rejected")
+		require.Len(t, invalid, 1, "Component should be reported")
+		assert.Equal(t, component, invalid[0].Component, "Whole component should be rejected")
+	})
+}
@@ -0,0 +1,173 @@
+package lvfs
+
+import (
+	"cmp"
+	"regexp"
+	"strings"
+)
+
+// VersionFormatKey is the custom value telling fwupd how to compare the versions of a component
+const VersionFormatKey = "LVFS::VersionFormat"
+
+// Version formats fwupd understands for vendor version strings
+const (
+	VersionFormatPlain   = "plain"   // Opaque string, e.g. "A12"
+	VersionFormatNumber  = "number"  // e.g. "12"
+	VersionFormatPair    = "pair"    // e.g. "1.48"
+	VersionFormatTriplet = "triplet" // e.g. "2.19.1"
+	VersionFormatQuad    = "quad"    // e.g. "15.10.10.00"
+)
+
+// VersionFormats lists the version formats that can be configured
+var VersionFormats = []string{
+	VersionFormatPlain,
+	VersionFormatNumber,
+	VersionFormatPair,
+	VersionFormatTriplet,
+	VersionFormatQuad,
+}
+
+// numericFormats maps the number of dot-separated numeric segments to its version format
+var numericFormats = map[int]string{
+	1: VersionFormatNumber,
+	2: VersionFormatPair,
+	3: VersionFormatTriplet,
+	4: VersionFormatQuad,
+}
+
+// versionPattern matches the semver-like versions fwupd can compare: numeric segments
+// separated by dots, with an optional pre-release suffix, e.g. "2.19.1" or "1.0.0-A01"
+var versionPattern = regexp.MustCompile(`^\d+(\.\d+)*(-[0-9A-Za-z]+(\.[0-9A-Za-z]+)*)?$`)
+
+// IsVersion reports whether version is semver-like, which fwupd can compare without a version format
+func IsVersion(version string) bool {
+	return versionPattern.MatchString(version)
+}
+
+// RedfishVersion returns the version the fwupd Redfish plugin reports for a device, as it
+// only keeps the "v" prefixed part of versions such as "U46 v2.10 (05/22/2024)"
+func RedfishVersion(version string) string {
+	for _, field := range strings.Fields(version) {
+		if len(field) > 1 && field[0] == 'v' && isDigit(field[1]) {
+			return field[1:]
+		}
+	}
+	return strings.TrimSpace(version)
+}
+
+// DetectVersionFormat returns the format of version from its dot-separated numeric
+// segments, falling back to plain for other versions such as "A12" or "1.0.0-A01"
+func DetectVersionFormat(version string) string {
+	segments := strings.Split(version, ".")
+	for _, segment := range segments {
+		if !isNumber(segment) {
+			return VersionFormatPlain
+		}
+	}
+	if format, ok := numericFormats[len(segments)]; ok {
+		return format
+	}
+	return VersionFormatPlain
+}
+
+// ValidVersion reports whether version can be used with the given version format. Numeric
+// formats need the matching number of segments, plain versions only need to be trimmed and
+// versions without format must be semver-like, see IsVersion.
+func ValidVersion(version, format string) bool {
+	switch format {
+	case "":
+		return IsVersion(version)
+	case VersionFormatPlain:
+		return version != "" && strings.TrimSpace(version) == version
+	default:
+		return DetectVersionFormat(version) == format
+	}
+}
+
+// CompareVersions compares two versions, returning -1, 0 or +1. Dot-separated segments are
+// compared in turn, runs of digits by value and other runs alphabetically, so that
+// "A9" < "A12" and "2.9" < "2.10". A version extending another one is greater.
+func CompareVersions(a, b string) int {
+	segmentsA, segmentsB := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(segmentsA) && i < len(segmentsB); i++ {
+		if c := compareSegments(segmentsA[i], segmentsB[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(segmentsA), len(segmentsB))
+}
+
+func compareSegments(a, b string) int {
+	for a != "" && b != "" {
+		var runA, runB string
+		runA, a = splitRun(a)
+		runB, b = splitRun(b)
+
+		numberA, numberB := isNumber(runA), isNumber(runB)
+		switch {
+		case numberA && numberB:
+			// Leading zeros do not matter and runs may not fit an int64
+			runA, runB = strings.TrimLeft(runA, "0"), strings.TrimLeft(runB, "0")
+			if c := cmp.Compare(len(runA), len(runB)); c != 0 {
+				return c
+			}
+			if c := strings.Compare(runA, runB); c != 0 {
+				return c
+			}
+		case numberA:
+			// Numbers sort after letters, as rpm does
+			return 1
+		case numberB:
+			return -1
+		default:
+			if c := strings.Compare(runA, runB); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// splitRun returns the leading run of digits or non-digits of s, and the rest of s
+func splitRun(s string) (run, rest string) {
+	digit := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digit {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isNumber(s string) bool {
+	return s != "" && strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }) == -1
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// VersionFormat returns the LVFS::VersionFormat of the component, or "" when unset
+func (c *Component) VersionFormat() string {
+	return c.CustomValue(VersionFormatKey)
+}
+
+// SetVersionFormat sets the LVFS::VersionFormat of the component, replacing any previous one
+func (c *Component) SetVersionFormat(format string) {
+	c.SetCustomValue(VersionFormatKey, format)
+}
+
+// DetectVersionFormat returns the version format shared by the releases of the component,
+// or plain when they differ, for components published without version format. It returns ""
+// without releases.
+func (c *Component) DetectVersionFormat() string {
+	format := ""
+	for _, release := range c.Releases {
+		switch detected := DetectVersionFormat(release.Version); {
+		case format == "":
+			format = detected
+		case format != detected:
+			return VersionFormatPlain
+		}
+	}
+	return format
+}
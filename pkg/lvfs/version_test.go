@@ -0,0 +1,164 @@
+package lvfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		valid   bool
+	}{
+		{"1.48", true},
+		{"22.41.1000", true},
+		{"2", true},
+		{"1.0.0-A01", true},
+		{"1.0.0-rc.1", true},
+		{"", false},
+		{"A08", false},
+		{"1..0", false},
+		{"1.0 (Jun 2024)", false},
+		{"v1.0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			assert.Equal(t, tt.valid, IsVersion(tt.version), "Version validity should match")
+		})
+	}
+}
+
+func TestRedfishVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{"1.48", "1.48"},
+		{" 22.41.1000 ", "22.41.1000"},
+		{"U46 v2.10", "2.10"},
+		{"U46 v2.10 (05/22/2024)", "2.10"},
+		{"vendor 1.0", "vendor 1.0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.version, func(t *testing.T) {
+			assert.Equal(t, test.expected, RedfishVersion(test.version), "Version should match the one fwupd reports")
+		})
+	}
+}
+
+func TestDetectVersionFormat(t *testing.T) {
+	tests := []struct {
+		version string
+		format  string
+	}{
+		{"12", VersionFormatNumber},
+		{"1.48", VersionFormatPair},
+		{"2.19.1", VersionFormatTriplet},
+		{"22.41.1000", VersionFormatTriplet},
+		{"15.10.10.00", VersionFormatQuad},
+		{"1.2.3.4.5", VersionFormatPlain},
+		{"A12", VersionFormatPlain},
+		{"1.0.0-A01", VersionFormatPlain},
+		{"U46 v2.10", VersionFormatPlain},
+		{"", VersionFormatPlain},
+		{"1..2", VersionFormatPlain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			assert.Equal(t, tt.format, DetectVersionFormat(tt.version), "Format should match")
+		})
+	}
+}
+
+func TestValidVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		format  string
+		valid   bool
+	}{
+		{"SemverWithoutFormat", "2.19.1", "", true},
+		{"PlainWithoutFormat", "A12", "", false},
+		{"Plain", "A12", VersionFormatPlain, true},
+		{"PlainWithSpaces", "U46 v2.10", VersionFormatPlain, true},
+		{"PlainUntrimmed", " A12", VersionFormatPlain, false},
+		{"PlainEmpty", "", VersionFormatPlain, false},
+		{"Pair", "1.48", VersionFormatPair, true},
+		{"PairMismatch", "1.48.1", VersionFormatPair, false},
+		{"Quad", "15.10.10.00", VersionFormatQuad, true},
+		{"NumericWithLetters", "A12", VersionFormatNumber, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.valid, ValidVersion(tt.version, tt.format), "Version validity should match")
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.48", "1.48", 0},
+		{"1.48", "1.50", -1},
+		{"2.9", "2.10", -1},
+		{"2.19.1", "2.2.0", 1},
+		{"22.41.1000", "22.41.999", 1},
+		{"15.10.10.00", "15.10.10.0", 0},
+		{"1.0", "1.0.1", -1},
+		{"A9", "A12", -1},
+		{"A12", "A12", 0},
+		{"B01", "A12", 1},
+		{"1.0.0-A01", "1.0.0-A02", -1},
+		{"1.a", "1.1", -1},
+		{"99999999999999999999999", "100000000000000000000000", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, CompareVersions(tt.a, tt.b), "Comparison should match")
+			assert.Equal(t, -tt.want, CompareVersions(tt.b, tt.a), "Comparison should be antisymmetric")
+		})
+	}
+}
+
+func TestComponent_SetVersionFormat(t *testing.T) {
+	component := &Component{Custom: []Custom{{Key: "LVFS::UpdateProtocol", Value: "org.dmtf.redfish"}}}
+	assert.Empty(t, component.VersionFormat(), "Format should be unset")
+
+	component.SetVersionFormat(VersionFormatPlain)
+	assert.Equal(t, VersionFormatPlain, component.VersionFormat(), "Format should be set")
+
+	component.SetVersionFormat(VersionFormatTriplet)
+	assert.Equal(t, VersionFormatTriplet, component.VersionFormat(), "Format should be replaced")
+	assert.Len(t, component.Custom, 2, "Format should only be set once")
+}
+
+func TestComponent_DetectVersionFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []string
+		expected string
+	}{
+		{name: "NoRelease", expected: ""},
+		{name: "Plain", versions: []string{"A12", "A11"}, expected: VersionFormatPlain},
+		{name: "Triplet", versions: []string{"2.19.1", "2.18.0"}, expected: VersionFormatTriplet},
+		{name: "Mixed", versions: []string{"2.19.1", "2.18"}, expected: VersionFormatPlain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			component := &Component{}
+			for _, version := range tt.versions {
+				component.Releases = append(component.Releases, Release{Version: version})
+			}
+			assert.Equal(t, tt.expected, component.DetectVersionFormat(), "Format should be detected from the releases")
+		})
+	}
+}
@@ -1,21 +1,42 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"slices"
+	"sync"
 	"time"
 )
 
 const maxRetries = 3
 
+// ErrRangeNotSupported is returned when a server does not support HTTP range requests
+var ErrRangeNotSupported = errors.New("range requests not supported")
+
 func DownloadFile(url string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := doWithRetry(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// doWithRetry sends the request until one of the expected status codes is returned,
+// retrying on network errors, 5xx errors and rate limiting
+func doWithRetry(req *http.Request, expectedStatus ...int) (*http.Response, error) {
 	var resp *http.Response
 	var err error
 
 	for attempt := 0; attempt <= maxRetries; attempt++ {
-		resp, err = http.Get(url)
+		resp, err = http.DefaultClient.Do(req)
 		if err != nil {
 			if attempt < maxRetries {
 				time.Sleep(time.Duration(attempt+1) * time.Second)
@@ -24,8 +45,8 @@ func DownloadFile(url string) (io.ReadCloser, error) {
 			return nil, fmt.Errorf("failed after %d attempts: %w", maxRetries+1, err)
 		}
 
-		if resp.StatusCode == http.StatusOK {
-			return resp.Body, nil
+		if slices.Contains(expectedStatus, resp.StatusCode) {
+			return resp, nil
 		}
 
 		resp.Body.Close()
@@ -58,3 +79,129 @@ func DownloadFileToDest(url, file string) error {
 	_, err = io.Copy(out, resp)
 	return err
 }
+
+const (
+	// httpChunkSize is the size of the aligned chunks HTTPReaderAt fetches, so that the many
+	// small reads of archive/zip are served from a few requests
+	httpChunkSize = 64 << 10
+	// httpCachedChunks is the number of chunks HTTPReaderAt keeps, the least recently used
+	// being evicted first
+	httpCachedChunks = 16
+)
+
+// HTTPReaderAt implements io.ReaderAt on top of HTTP range requests,
+// so that only the needed parts of a remote file are downloaded
+type HTTPReaderAt struct {
+	url  string
+	size int64
+
+	mu     sync.Mutex
+	chunks []httpChunk // Most recently used last
+}
+
+// httpChunk is a fetched part of the remote file
+type httpChunk struct {
+	index int64
+	data  []byte
+}
+
+// NewHTTPReaderAt checks that the server supports range requests for the given URL
+// and returns a reader for it. ErrRangeNotSupported is returned otherwise.
+// Support is probed with a request of the first byte, as many servers and CDNs honour
+// ranges without advertising them with Accept-Ranges.
+func NewHTTPReaderAt(url string) (*HTTPReaderAt, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := doWithRetry(req, http.StatusPartialContent, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, ErrRangeNotSupported
+	}
+
+	// Content-Range is "bytes 0-0/<size>", the size being * when unknown
+	var size int64
+	if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes 0-0/%d", &size); err != nil {
+		return nil, ErrRangeNotSupported
+	}
+
+	return &HTTPReaderAt{url: url, size: size}, nil
+}
+
+// Size returns the size of the remote file
+func (r *HTTPReaderAt) Size() int64 {
+	return r.size
+}
+
+// ReadAt reads len(p) bytes of the remote file starting at offset off
+func (r *HTTPReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	end := min(off+int64(len(p)), r.size)
+	n := 0
+	for pos := off; pos < end; {
+		chunk, err := r.chunk(pos / httpChunkSize)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:end-off], chunk[pos%httpChunkSize:])
+		n += copied
+		pos += int64(copied)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// chunk returns the data of the chunk at index, fetching it unless cached
+func (r *HTTPReaderAt) chunk(index int64) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, chunk := range r.chunks {
+		if chunk.index == index {
+			r.chunks = append(slices.Delete(r.chunks, i, i+1), chunk)
+			return chunk.data, nil
+		}
+	}
+
+	start := index * httpChunkSize
+	end := min(start+httpChunkSize, r.size)
+
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+
+	resp, err := doWithRetry(req, http.StatusPartialContent)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data := make([]byte, end-start)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, err
+	}
+
+	if len(r.chunks) == httpCachedChunks {
+		r.chunks = slices.Delete(r.chunks, 0, 1)
+	}
+	r.chunks = append(r.chunks, httpChunk{index: index, data: data})
+	return data, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
@@ -75,6 +76,17 @@ func (dv *DellVendor) fetchCatalog() (*DellCatalog, error) {
 func (dv *DellVendor) filterCatalog(catalog *DellCatalog) *DellCatalog {
 	filteredComponents := []DellSoftwareComponent{}
 
+	bundles := catalog.SoftwareBundle
+	if len(dv.BundleIDs) > 0 || dv.LatestBundle {
+		bundles = dv.selectBundles(catalog.SoftwareBundle)
+	}
+	bundledPaths := make(map[string]bool)
+	for _, bundle := range bundles {
+		for _, pkg := range bundle.Packages {
+			bundledPaths[pkg.Path] = true
+		}
+	}
+
 	for _, fw := range catalog.SoftwareComponents {
 		// Only select firmware, not drivers
 		// FIXME include BIOS ?
@@ -82,6 +94,11 @@ func (dv *DellVendor) filterCatalog(catalog *DellCatalog) *DellCatalog {
 			continue
 		}
 
+		// When bundles are selected, only keep their content
+		if (len(dv.BundleIDs) > 0 || dv.LatestBundle) && !bundledPaths[fw.Path] {
+			continue
+		}
+
 		// If no SystemIDs filter is set, include all firmware
 		if len(dv.SystemIDs) == 0 {
 			filteredComponents = append(filteredComponents, fw)
@@ -100,10 +117,57 @@ func (dv *DellVendor) filterCatalog(catalog *DellCatalog) *DellCatalog {
 	}
 
 	filteredCatalog := *catalog // Copy the catalog
+	filteredCatalog.SoftwareBundle = bundles
 	filteredCatalog.SoftwareComponents = filteredComponents
+	filteredCatalog.VersionFormats = versionFormatOverrides(dv.VersionFormats)
 	return &filteredCatalog
 }
 
+// selectBundles returns the bundles matching BundleIDs and, if LatestBundle is set,
+// the most recent bundle of each bundle type for every selected system.
+func (dv *DellVendor) selectBundles(bundles []DellSoftwareBundle) []DellSoftwareBundle {
+	selected := []DellSoftwareBundle{}
+	seen := make(map[string]bool)
+
+	for _, bundle := range bundles {
+		if slices.Contains(dv.BundleIDs, bundle.BundleID) || (bundle.Identifier != "" && slices.Contains(dv.BundleIDs, bundle.Identifier)) {
+			selected = append(selected, bundle)
+			seen[bundle.BundleID] = true
+		}
+	}
+
+	if !dv.LatestBundle {
+		return selected
+	}
+
+	// Keep the latest bundle per system and bundle type, as Dell publishes
+	// distinct bundles for each target OS
+	latest := make(map[string]DellSoftwareBundle)
+	for _, bundle := range bundles {
+		for _, brand := range bundle.TargetSystems {
+			for _, model := range brand.Models {
+				if len(dv.SystemIDs) > 0 && !slices.Contains(dv.SystemIDs, model.SystemID) {
+					continue
+				}
+				key := model.SystemID + "/" + bundle.BundleType
+				if current, ok := latest[key]; !ok || bundle.DateTime.After(current.DateTime) {
+					latest[key] = bundle
+				}
+			}
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(latest)) {
+		bundle := latest[key]
+		if !seen[bundle.BundleID] {
+			selected = append(selected, bundle)
+			seen[bundle.BundleID] = true
+		}
+	}
+
+	return selected
+}
+
 func (dv *DellVendor) RetrieveFirmware(entry firmirror.FirmwareEntry, tmpDir string) error {
 	dellEntry, ok := entry.(*DellFirmwareEntry)
 	if !ok {
@@ -122,12 +186,22 @@ func (dv *DellVendor) RetrieveFirmware(entry firmirror.FirmwareEntry, tmpDir str
 }
 
 func (dc *DellCatalog) ListEntries() []firmirror.FirmwareEntry {
+	bundlesByPath := make(map[string][]*DellSoftwareBundle)
+	for i := range dc.SoftwareBundle {
+		bundle := &dc.SoftwareBundle[i]
+		for _, pkg := range bundle.Packages {
+			bundlesByPath[pkg.Path] = append(bundlesByPath[pkg.Path], bundle)
+		}
+	}
+
 	entries := []firmirror.FirmwareEntry{}
 	for _, fw := range dc.SoftwareComponents {
 		entries = append(entries, &DellFirmwareEntry{
 			Filename:              filepath.Base(fw.Path),
 			DellSoftwareComponent: &fw,
 			SourceURL:             dc.BaseLocation + "/" + fw.Path,
+			Bundles:               bundlesByPath[fw.Path],
+			VersionFormats:        dc.VersionFormats,
 		})
 	}
 	return entries
@@ -142,28 +216,53 @@ func (dfe *DellFirmwareEntry) GetSourceURL() string {
 }
 
 func (dfe *DellFirmwareEntry) ToAppstream() (*lvfs.Component, error) {
-	return processFirmware(*dfe.DellSoftwareComponent)
+	component, err := processFirmware(*dfe.DellSoftwareComponent, dfe.Bundles)
+	if err != nil {
+		return nil, err
+	}
+	component.SetVersionFormat(getVersionFormat(*dfe.DellSoftwareComponent, dfe.VersionFormats))
+
+	return component, nil
 }
 
-func processFirmware(fw DellSoftwareComponent) (*lvfs.Component, error) {
+// versionFormatOverrides returns the configured overrides keyed on lower-case LU
+// categories, as categories are matched case-insensitively
+func versionFormatOverrides(overrides map[string]string) map[string]string {
+	normalized := make(map[string]string, len(overrides))
+	for category, format := range overrides {
+		normalized[strings.ToLower(category)] = format
+	}
+	return normalized
+}
+
+// getVersionFormat returns the version format of a component, from the overrides keyed on
+// its lower-case LU category, or else detected from its version: older firmware use
+// versions such as "A12", which fwupd must not compare as numbers
+func getVersionFormat(fw DellSoftwareComponent, overrides map[string]string) string {
+	if format, ok := overrides[strings.ToLower(fw.LUCategory.Value)]; ok {
+		return format
+	}
+	return lvfs.DetectVersionFormat(fw.VendorVersion)
+}
+
+func processFirmware(fw DellSoftwareComponent, bundles []*DellSoftwareBundle) (*lvfs.Component, error) {
 	out := lvfs.Component{
 		Type:            "firmware",
 		MetadataLicense: "proprietary",
 		ProjectLicense:  "proprietary",
 	}
 
-	out.Name, _ = getString(fw.Name, "en")
-	out.ID = fmt.Sprintf("com.%s.%s", strings.ToLower("Dell"), uuid.NewSHA1(uuid.NameSpaceDNS, []byte(out.Name)).String())
+	out.Name, _ = getTranslations(fw.Name)
+	out.ID = stableID(fw)
+	if legacyID := legacyID(out.Name.String()); legacyID != out.ID {
+		out.Replaces = append(out.Replaces, legacyID)
+	}
 
-	for _, brand := range fw.SupportedSystems {
-		for _, system := range brand.Models {
-			for _, dev := range fw.SupportedDevices {
-				out.Provides = append(out.Provides, lvfs.Firmware{
-					Type: "flashed",
-					Text: uuid.NewSHA1(uuid.NameSpaceDNS, fmt.Appendf(nil, "REDFISH\\VENDOR_Dell&SYSTEMID_%s&SOFTWAREID_%s", system.SystemID, dev.ComponentID)).String(),
-				})
-			}
-		}
+	for _, guid := range lvfs.GUIDsFromInstanceIDs(deviceInstanceIDs(fw)) {
+		out.Provides = append(out.Provides, lvfs.Firmware{
+			Type: "flashed",
+			Text: guid,
+		})
 	}
 
 	if fw.RebootRequired {
@@ -171,31 +270,27 @@ func processFirmware(fw DellSoftwareComponent) (*lvfs.Component, error) {
 			Key:   "LVFS::DeviceFlags",
 			Value: "skips-restart",
 		})
-		rebootMessage, err := getString(fw.ImportantInfo, "en")
+		rebootMessage, err := getTranslations(fw.ImportantInfo)
 		if err != nil {
 			return nil, err
 		}
 
-		out.Custom = append(out.Custom, lvfs.Custom{
-			Key:   "LVFS::UpdateMessage",
-			Value: rebootMessage,
-		})
+		out.Custom = append(out.Custom, rebootMessage.Customs("LVFS::UpdateMessage")...)
 	}
 
-	summary, err := getString(fw.Description, "en")
+	summary, err := getTranslations(fw.Description)
 	if err != nil {
 		return nil, err
 	}
 	out.Summary = summary
-	out.Description = lvfs.Description{
-		Value: "<p>" + summary + "</p>",
-	}
+	out.Description = summary.Descriptions()
 
 	out.Releases = append(out.Releases, lvfs.Release{
 		Version:     fw.VendorVersion,
 		Date:        fw.DateTime.Format(time.DateOnly),
-		Description: out.Description,
+		Description: releaseNotes(fw, summary).Descriptions(),
 		Urgency:     getUrgency(fw.Criticality.Value),
+		Issues:      releaseIssues(fw),
 	})
 
 	switch fw.LUCategory.Value {
@@ -222,16 +317,169 @@ func processFirmware(fw DellSoftwareComponent) (*lvfs.Component, error) {
 		Value: "signed",
 	})
 
+	// Record bundle membership so operators can install the set Dell validated together
+	var bundleIDs []string
+	for _, bundle := range bundles {
+		out.Keywords = append(out.Keywords, "dell-bundle-"+bundle.BundleID)
+		bundleIDs = append(bundleIDs, bundle.BundleID)
+	}
+	if len(bundleIDs) > 0 {
+		out.Custom = append(out.Custom, lvfs.Custom{
+			Key:   "Dell::Bundles",
+			Value: strings.Join(bundleIDs, ","),
+		})
+	}
+
 	return &out, nil
 }
 
-func getString(strings DellTranslatable, language string) (string, error) {
+// stableID returns a component ID derived from the primary device component ID, the first
+// of the package, which unlike the display name is stable across releases. It doesn't
+// depend on the other devices and systems, which Dell adds to packages over time. Dell
+// uses the same component ID for the BIOS of every system, so the primary system of BIOS
+// packages, the first of their family, is part of their ID.
+func stableID(fw DellSoftwareComponent) string {
+	var componentID string
+	for _, device := range fw.SupportedDevices {
+		if device.ComponentID != "" {
+			componentID = device.ComponentID
+			break
+		}
+	}
+	if componentID == "" {
+		name, _ := getTranslations(fw.Name)
+		return legacyID(name.String())
+	}
+	key := "componentID=" + componentID
+
+	if fw.LUCategory.Value == "BIOS" {
+		if systemIDs := supportedSystemIDs(fw); len(systemIDs) > 0 {
+			key += ";systemID=" + systemIDs[0]
+		}
+	}
+
+	return "com.dell." + uuid.NewSHA1(uuid.NameSpaceDNS, []byte(key)).String()
+}
+
+// legacyID returns the component ID previous versions derived from the display name
+func legacyID(name string) string {
+	return fmt.Sprintf("com.%s.%s", strings.ToLower("Dell"), uuid.NewSHA1(uuid.NameSpaceDNS, []byte(name)).String())
+}
+
+// supportedSystemIDs returns the system IDs the firmware applies to, in catalog order
+func supportedSystemIDs(fw DellSoftwareComponent) []string {
+	var systemIDs []string
+	for _, brand := range fw.SupportedSystems {
+		for _, system := range brand.Models {
+			if system.SystemID != "" && !slices.Contains(systemIDs, system.SystemID) {
+				systemIDs = append(systemIDs, system.SystemID)
+			}
+		}
+	}
+	return systemIDs
+}
+
+// deviceInstanceIDs returns the instance IDs fwupd reports for the devices updated by this
+// component. The Redfish plugin builds REDFISH\VENDOR_Dell&SYSTEMID_xxxx&SOFTWAREID_yyyy instance
+// IDs from the iDRAC firmware inventory, where the SoftwareId is the Dell component ID. Devices
+// updated through UEFI capsules are instead reported with their FMP GUID as SoftwareId, which
+// fwupd uses as-is.
+func deviceInstanceIDs(fw DellSoftwareComponent) []string {
+	var instanceIDs []string
+	add := func(instanceID string) {
+		if !slices.Contains(instanceIDs, instanceID) {
+			instanceIDs = append(instanceIDs, instanceID)
+		}
+	}
+
+	systemIDs := supportedSystemIDs(fw)
+
+	addSoftwareID := func(softwareID string) {
+		if softwareID == "" {
+			return
+		}
+		if lvfs.IsGUID(softwareID) {
+			add(lvfs.NormalizeGUID(softwareID))
+			return
+		}
+		// Without system restriction, the component applies to any system
+		if len(systemIDs) == 0 {
+			add("REDFISH\\VENDOR_Dell&SOFTWAREID_" + softwareID)
+			return
+		}
+		for _, systemID := range systemIDs {
+			add(fmt.Sprintf("REDFISH\\VENDOR_Dell&SYSTEMID_%s&SOFTWAREID_%s", systemID, softwareID))
+		}
+	}
+
+	for _, dev := range fw.SupportedDevices {
+		addSoftwareID(dev.ComponentID)
+		for _, image := range dev.Images {
+			if !image.Skip {
+				addSoftwareID(image.ID)
+			}
+		}
+		if lvfs.IsGUID(dev.RollbackInformation.FMPIdentifier) {
+			add(lvfs.NormalizeGUID(dev.RollbackInformation.FMPIdentifier))
+		}
+	}
+
+	// FMP wrappers expose legacy devices through an ESRT entry named after the wrapper
+	for _, wrapper := range fw.FMPWrapperInformations {
+		if wrapper.Update.Supported && lvfs.IsGUID(wrapper.Identifier) {
+			add(lvfs.NormalizeGUID(wrapper.Identifier))
+		}
+	}
+
+	return instanceIDs
+}
+
+// releaseNotes returns the revision history of the firmware in every language it is
+// translated to. It falls back to the summary when Dell provides none. The important
+// information is left out, as it is published as the update message of the device.
+func releaseNotes(fw DellSoftwareComponent, summary lvfs.Translations) lvfs.Translations {
+	history, err := getTranslations(fw.RevisionHistory)
+	if err != nil {
+		return summary
+	}
+	return history
+}
+
+// releaseIssues returns the security advisories mentioned in the English texts of the firmware
+func releaseIssues(fw DellSoftwareComponent) []lvfs.Issue {
+	var texts []string
+	for _, t := range []DellTranslatable{fw.Name, fw.Description, fw.ImportantInfo, fw.RevisionHistory, fw.Criticality.DellTranslatable} {
+		if translations, err := getTranslations(t); err == nil {
+			texts = append(texts, translations.String())
+		}
+	}
+	return lvfs.ExtractIssues(texts...)
+}
+
+// getTranslations returns every language of a translatable string, English being the
+// untranslated value, sanitized. It fails when the English value is missing.
+func getTranslations(strings DellTranslatable) (lvfs.Translations, error) {
+	var out lvfs.Translations
+	seen := map[string]bool{}
 	for _, l := range strings.Display {
-		if l.Lang == language {
-			return l.Value, nil
+		lang := lvfs.NormalizeLang(l.Lang)
+		if seen[lang] {
+			continue
+		}
+		seen[lang] = true
+
+		text := lvfs.SanitizeText(l.Value)
+		if lang == "" {
+			out = slices.Insert(out, 0, lvfs.Translation{Text: text})
+		} else {
+			out = append(out, lvfs.Translation{Lang: lang, Text: text})
 		}
 	}
-	return "", fmt.Errorf("language not found: %s", language)
+
+	if !seen[""] {
+		return out, fmt.Errorf("language not found: en")
+	}
+	return out, nil
 }
 
 func getUrgency(criticality int64) string {
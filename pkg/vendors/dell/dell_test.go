@@ -9,7 +9,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/criteo/firmirror/pkg/lvfs"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // mockServer creates a test HTTP server that serves the test catalog
@@ -153,6 +155,84 @@ func TestDellVendor_FetchCatalog(t *testing.T) {
 	})
 }
 
+func TestDellVendor_FetchCatalog_Bundles(t *testing.T) {
+	server := mockServer(t)
+	defer server.Close()
+
+	t.Run("LatestBundle", func(t *testing.T) {
+		vendor := &DellVendor{
+			BaseURL:      server.URL,
+			SystemIDs:    []string{"0C60"},
+			LatestBundle: true,
+		}
+
+		catalog, err := vendor.FetchCatalog()
+		assert.NoError(t, err, "FetchCatalog should not return an error")
+
+		dellCatalog, ok := catalog.(*DellCatalog)
+		assert.True(t, ok, "Catalog should be of type *DellCatalog")
+
+		// Only the latest bundle is kept, and it contains a single firmware
+		assert.Len(t, dellCatalog.SoftwareBundle, 1, "Should select only the latest bundle")
+		assert.Equal(t, "BNDL2", dellCatalog.SoftwareBundle[0].BundleID, "Should select the most recent bundle")
+		assert.Len(t, dellCatalog.SoftwareComponents, 1, "Should only keep the bundle content")
+		assert.Equal(t, "FOLDER01/firmware1.exe", dellCatalog.SoftwareComponents[0].Path)
+	})
+
+	t.Run("ExplicitBundleID", func(t *testing.T) {
+		vendor := &DellVendor{
+			BaseURL:   server.URL,
+			BundleIDs: []string{"BNDL1"},
+		}
+
+		catalog, err := vendor.FetchCatalog()
+		assert.NoError(t, err, "FetchCatalog should not return an error")
+
+		dellCatalog, ok := catalog.(*DellCatalog)
+		assert.True(t, ok, "Catalog should be of type *DellCatalog")
+
+		assert.Len(t, dellCatalog.SoftwareBundle, 1, "Should select the requested bundle")
+		assert.Len(t, dellCatalog.SoftwareComponents, 2, "Should keep both firmware from the bundle")
+	})
+
+	t.Run("UnknownBundleID", func(t *testing.T) {
+		vendor := &DellVendor{
+			BaseURL:   server.URL,
+			BundleIDs: []string{"NOPE"},
+		}
+
+		catalog, err := vendor.FetchCatalog()
+		assert.NoError(t, err, "FetchCatalog should not return an error")
+
+		dellCatalog, ok := catalog.(*DellCatalog)
+		assert.True(t, ok, "Catalog should be of type *DellCatalog")
+		assert.Empty(t, dellCatalog.SoftwareComponents, "Should have no component for an unknown bundle")
+	})
+
+	t.Run("BundleMembershipInMetadata", func(t *testing.T) {
+		vendor := &DellVendor{
+			BaseURL: server.URL,
+		}
+
+		catalog, err := vendor.FetchCatalog()
+		assert.NoError(t, err, "FetchCatalog should not return an error")
+
+		for _, entry := range catalog.ListEntries() {
+			component, err := entry.ToAppstream()
+			assert.NoError(t, err, "ToAppstream should not return an error")
+
+			switch entry.GetFilename() {
+			case "firmware1.exe":
+				assert.ElementsMatch(t, []string{"dell-bundle-BNDL1", "dell-bundle-BNDL2"}, component.Keywords)
+				assert.Contains(t, component.Custom, lvfs.Custom{Key: "Dell::Bundles", Value: "BNDL1,BNDL2"})
+			case "bios.exe":
+				assert.Equal(t, []string{"dell-bundle-BNDL1"}, component.Keywords)
+				assert.Contains(t, component.Custom, lvfs.Custom{Key: "Dell::Bundles", Value: "BNDL1"})
+			}
+		}
+	})
+}
+
 func TestDellVendor_RetrieveFirmware(t *testing.T) {
 	server := mockServer(t)
 	defer server.Close()
@@ -258,12 +338,15 @@ func TestDellFirmwareEntry_ToAppstream(t *testing.T) {
 			},
 			Description: DellTranslatable{
 				Display: []DellTranslatableEntry{
+					{Lang: "de", Value: "Test-Firmware-Beschreibung"},
 					{Lang: "en", Value: "Test firmware description"},
+					{Lang: "zh-cn", Value: "测试固件说明"},
 				},
 			},
 			ImportantInfo: DellTranslatable{
 				Display: []DellTranslatableEntry{
 					{Lang: "en", Value: "Reboot required"},
+					{Lang: "fr", Value: "Redémarrage requis"},
 				},
 			},
 			LUCategory: DellTranslatableWithValue{
@@ -293,8 +376,15 @@ func TestDellFirmwareEntry_ToAppstream(t *testing.T) {
 	assert.Equal(t, "firmware", component.Type, "Component type should be firmware")
 	assert.Equal(t, "proprietary", component.MetadataLicense, "Metadata license should be proprietary")
 	assert.Equal(t, "proprietary", component.ProjectLicense, "Project license should be proprietary")
-	assert.Equal(t, "Test Network Firmware", component.Name, "Component name should match")
-	assert.Equal(t, "Test firmware description", component.Summary, "Component summary should match")
+	assert.Equal(t, "Test Network Firmware", component.Name.String(), "Component name should match")
+	assert.Equal(t, "Test firmware description", component.Summary.String(), "Component summary should match")
+	assert.Equal(t, lvfs.Translations{
+		{Text: "Test firmware description"},
+		{Lang: "de", Text: "Test-Firmware-Beschreibung"},
+		{Lang: "zh_CN", Text: "测试固件说明"},
+	}, component.Summary, "Component summary should contain every language")
+	assert.Len(t, component.Description, 3, "Description should contain every language")
+	assert.Equal(t, "zh_CN", component.Description[2].Lang, "Description language should be normalized")
 
 	// Verify releases
 	assert.Len(t, component.Releases, 1, "Should have exactly one release")
@@ -314,11 +404,68 @@ func TestDellFirmwareEntry_ToAppstream(t *testing.T) {
 	assert.Contains(t, customKeys, "LVFS::UpdateMessage", "Should contain UpdateMessage custom field")
 	assert.Contains(t, customKeys, "LVFS::UpdateProtocol", "Should contain UpdateProtocol custom field")
 	assert.Contains(t, customKeys, "LVFS::DeviceIntegrity", "Should contain DeviceIntegrity custom field")
+	assert.Equal(t, lvfs.VersionFormatTriplet, component.VersionFormat(), "Version format should be detected")
+	assert.Contains(t, component.Custom, lvfs.Custom{Key: "LVFS::UpdateMessage", Lang: "fr", Value: "Redémarrage requis"}, "Should contain translated UpdateMessage")
 
 	// Verify provides section
 	assert.NotEmpty(t, component.Provides, "Should have provides entries")
 }
 
+func TestGetVersionFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		category  string
+		overrides map[string]string
+		expected  string
+	}{
+		{name: "Triplet", version: "2.19.1", category: "BIOS", expected: lvfs.VersionFormatTriplet},
+		{name: "Quad", version: "15.10.10.00", category: "Network", expected: lvfs.VersionFormatQuad},
+		{name: "Plain", version: "A12", category: "SAS Drive", expected: lvfs.VersionFormatPlain},
+		{
+			name:      "Override",
+			version:   "A12",
+			category:  "SAS Drive",
+			overrides: map[string]string{"Sas Drive": lvfs.VersionFormatNumber, "BIOS": lvfs.VersionFormatPlain},
+			expected:  lvfs.VersionFormatNumber,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fw := DellSoftwareComponent{
+				VendorVersion: tt.version,
+				LUCategory:    DellTranslatableWithValue{Value: tt.category},
+			}
+			assert.Equal(t, tt.expected, getVersionFormat(fw, versionFormatOverrides(tt.overrides)), "Version format should match")
+		})
+	}
+}
+
+func TestGetTranslations(t *testing.T) {
+	t.Run("MissingEnglish", func(t *testing.T) {
+		_, err := getTranslations(DellTranslatable{
+			Display: []DellTranslatableEntry{{Lang: "fr", Value: "Micrologiciel"}},
+		})
+		assert.Error(t, err, "Should fail without an English value")
+	})
+
+	t.Run("DuplicateLanguages", func(t *testing.T) {
+		translations, err := getTranslations(DellTranslatable{
+			Display: []DellTranslatableEntry{
+				{Lang: "en", Value: "Firmware"},
+				{Lang: "EN", Value: "Firmware again"},
+				{Lang: "ja", Value: "ファームウェア"},
+			},
+		})
+		assert.NoError(t, err, "Should not return an error")
+		assert.Equal(t, lvfs.Translations{
+			{Text: "Firmware"},
+			{Lang: "ja", Text: "ファームウェア"},
+		}, translations, "Should keep the first value of each language")
+	})
+}
+
 // Helper function for parsing time in tests
 func mustParseTime(timeStr string) time.Time {
 	t, err := time.Parse(time.RFC3339, timeStr)
@@ -327,3 +474,227 @@ func mustParseTime(timeStr string) time.Time {
 	}
 	return t
 }
+
+func TestDeviceInstanceIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		fw       DellSoftwareComponent
+		expected []string
+	}{
+		{
+			name: "RedfishPerSystem",
+			fw: DellSoftwareComponent{
+				SupportedSystems: []DellBrand{
+					{Models: []DellModel{{SystemID: "0C60"}, {SystemID: "0C61"}}},
+				},
+				SupportedDevices: []DellDevice{{ComponentID: "DEV001"}},
+			},
+			expected: []string{
+				`REDFISH\VENDOR_Dell&SYSTEMID_0C60&SOFTWAREID_DEV001`,
+				`REDFISH\VENDOR_Dell&SYSTEMID_0C61&SOFTWAREID_DEV001`,
+			},
+		},
+		{
+			name: "RedfishWithoutSystem",
+			fw: DellSoftwareComponent{
+				SupportedDevices: []DellDevice{{ComponentID: "159"}},
+			},
+			expected: []string{
+				`REDFISH\VENDOR_Dell&SOFTWAREID_159`,
+			},
+		},
+		{
+			name: "PayloadImages",
+			fw: DellSoftwareComponent{
+				SupportedSystems: []DellBrand{
+					{Models: []DellModel{{SystemID: "0A2C"}}},
+				},
+				SupportedDevices: []DellDevice{
+					{
+						ComponentID: "159",
+						Images: []DellImage{
+							{ID: "25227"},
+							{ID: "99999", Skip: true},
+							{ID: "159"},
+						},
+					},
+				},
+			},
+			expected: []string{
+				`REDFISH\VENDOR_Dell&SYSTEMID_0A2C&SOFTWAREID_159`,
+				`REDFISH\VENDOR_Dell&SYSTEMID_0A2C&SOFTWAREID_25227`,
+			},
+		},
+		{
+			name: "UEFICapsule",
+			fw: DellSoftwareComponent{
+				SupportedSystems: []DellBrand{
+					{Models: []DellModel{{SystemID: "0A2C"}}},
+				},
+				SupportedDevices: []DellDevice{
+					{
+						ComponentID: "A0B1C2D3-E4F5-4A5B-8C9D-0E1F2A3B4C5D",
+						RollbackInformation: DellRollbackInformation{
+							FMPIdentifier:        "a0b1c2d3-e4f5-4a5b-8c9d-0e1f2a3b4c5d",
+							FMPWrapperIdentifier: "11111111-2222-3333-4444-555555555555",
+						},
+					},
+				},
+				FMPWrapperInformations: []DellFMPWrapperInformation{
+					{
+						Identifier: "6fe1a7b4-4c54-4a02-9a3c-2f9c0c0b4e11",
+						Update:     DellFMPWrapperUpdate{Supported: true},
+					},
+					{
+						Identifier: "00000000-0000-0000-0000-000000000000",
+						Update:     DellFMPWrapperUpdate{Supported: false},
+					},
+				},
+			},
+			expected: []string{
+				"a0b1c2d3-e4f5-4a5b-8c9d-0e1f2a3b4c5d",
+				"6fe1a7b4-4c54-4a02-9a3c-2f9c0c0b4e11",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, deviceInstanceIDs(tt.fw), "Instance IDs should match the ones fwupd reports")
+		})
+	}
+}
+
+func TestReleaseNotes(t *testing.T) {
+	summary := lvfs.Translations{{Text: "Network firmware"}}
+
+	t.Run("RevisionHistory", func(t *testing.T) {
+		fw := DellSoftwareComponent{
+			RevisionHistory: DellTranslatable{
+				Display: []DellTranslatableEntry{
+					{Lang: "en", Value: "Fixes:\n- Link drops at speeds < 10Gb/s\n- PXE & iSCSI boot issues"},
+					{Lang: "fr", Value: "Corrections diverses"},
+				},
+			},
+			ImportantInfo: DellTranslatable{
+				Display: []DellTranslatableEntry{
+					{Lang: "en", Value: "Reboot required"},
+				},
+			},
+		}
+
+		notes := releaseNotes(fw, summary).Descriptions()
+		assert.Equal(t, []lvfs.Description{
+			{Blocks: []lvfs.Block{
+				{Paragraph: "Fixes:"},
+				{Items: []string{"Link drops at speeds < 10Gb/s", "PXE & iSCSI boot issues"}},
+			}},
+			{Lang: "fr", Blocks: []lvfs.Block{
+				{Paragraph: "Corrections diverses"},
+			}},
+		}, notes, "Release notes should be structured, without the important information nor English in translations")
+	})
+
+	t.Run("FallbackToSummary", func(t *testing.T) {
+		notes := releaseNotes(DellSoftwareComponent{}, summary)
+		assert.Equal(t, summary, notes, "Release notes should fall back to the summary")
+	})
+
+	t.Run("FromCatalog", func(t *testing.T) {
+		server := mockServer(t)
+		defer server.Close()
+
+		vendor := &DellVendor{BaseURL: server.URL}
+		catalog, err := vendor.fetchCatalog()
+		assert.NoError(t, err, "fetchCatalog should not return an error")
+
+		component, err := processFirmware(catalog.SoftwareComponents[0], nil)
+		assert.NoError(t, err, "processFirmware should not return an error")
+		assert.Equal(t, []lvfs.Description{
+			{Blocks: []lvfs.Block{{Paragraph: "Initial release"}}},
+		}, component.Releases[0].Description, "Release notes should come from the revision history")
+		assert.Equal(t, []lvfs.Description{
+			{Blocks: []lvfs.Block{{Paragraph: "Test firmware for network adapter"}}},
+		}, component.Description, "Component description should come from the description")
+	})
+}
+
+func TestReleaseIssues(t *testing.T) {
+	fw := DellSoftwareComponent{
+		Description: DellTranslatable{
+			Display: []DellTranslatableEntry{{Lang: "en", Value: "iDRAC firmware"}},
+		},
+		RevisionHistory: DellTranslatable{
+			Display: []DellTranslatableEntry{
+				{Lang: "en", Value: "- Fixed CVE-2024-1234 and CVE-2024-5678, see DSA-2024-001"},
+				{Lang: "fr", Value: "- Correction de CVE-2099-0001"},
+			},
+		},
+		Criticality: DellCriticality{
+			Value: 2,
+			DellTranslatable: DellTranslatable{
+				Display: []DellTranslatableEntry{{Lang: "en", Value: "Urgent: addresses CVE-2024-1234"}},
+			},
+		},
+	}
+
+	assert.Equal(t, []lvfs.Issue{
+		{Type: "cve", Text: "CVE-2024-1234"},
+		{Type: "cve", Text: "CVE-2024-5678"},
+		{Type: "dell", Text: "DSA-2024-001"},
+	}, releaseIssues(fw), "Issues should be extracted once from the English texts")
+
+	component, err := processFirmware(fw, nil)
+	assert.NoError(t, err, "processFirmware should not return an error")
+	assert.Len(t, component.Releases[0].Issues, 3, "Release should list the issues")
+}
+
+func TestStableID(t *testing.T) {
+	firmware := func(name, category string, systems ...string) DellSoftwareComponent {
+		var models []DellModel
+		for _, system := range systems {
+			models = append(models, DellModel{SystemID: system})
+		}
+		return DellSoftwareComponent{
+			Name:             DellTranslatable{Display: []DellTranslatableEntry{{Lang: "en", Value: name}}},
+			Description:      DellTranslatable{Display: []DellTranslatableEntry{{Lang: "en", Value: name}}},
+			LUCategory:       DellTranslatableWithValue{Value: category},
+			SupportedDevices: []DellDevice{{ComponentID: "25227"}, {ComponentID: "159"}},
+			SupportedSystems: []DellBrand{{Models: models}},
+		}
+	}
+
+	t.Run("IndependentOfName", func(t *testing.T) {
+		before := stableID(firmware("Broadcom NetXtreme Firmware", "Network", "0C60"))
+		after := stableID(firmware("Broadcom NetXtreme-E Family Firmware", "Network", "0C60", "0C61"))
+		assert.Equal(t, before, after, "Renaming or adding systems should keep the ID")
+		assert.Equal(t, "com.dell.cf6fd91c-62c9-5fda-b568-e89673371a47", before, "ID should be derived from the primary component ID")
+	})
+
+	t.Run("IndependentOfOtherDevices", func(t *testing.T) {
+		fw := firmware("Broadcom NetXtreme Firmware", "Network", "0C60")
+		before := stableID(fw)
+		fw.SupportedDevices = append(fw.SupportedDevices, DellDevice{ComponentID: "108"})
+		assert.Equal(t, before, stableID(fw), "Adding a device should keep the ID")
+	})
+
+	t.Run("BIOSIncludesSystems", func(t *testing.T) {
+		r750 := stableID(firmware("BIOS", "BIOS", "0C60"))
+		r760 := stableID(firmware("BIOS", "BIOS", "0C61"))
+		assert.NotEqual(t, r750, r760, "BIOS of different systems should have different IDs")
+		assert.Equal(t, r750, stableID(firmware("BIOS", "BIOS", "0C60")), "BIOS ID should be stable")
+		assert.Equal(t, r750, stableID(firmware("BIOS", "BIOS", "0C60", "0C62")), "Adding a system to a BIOS package should keep the ID")
+	})
+
+	t.Run("FallbackToName", func(t *testing.T) {
+		fw := firmware("Test Firmware", "Network")
+		fw.SupportedDevices = nil
+		assert.Equal(t, legacyID("Test Firmware"), stableID(fw), "ID should fall back to the name without devices")
+	})
+
+	t.Run("ReplacesLegacyID", func(t *testing.T) {
+		component, err := processFirmware(firmware("Test Firmware", "Network", "0C60"), nil)
+		require.NoError(t, err, "processFirmware should not return an error")
+		assert.Equal(t, []string{legacyID("Test Firmware")}, component.Replaces, "Component should replace its name-based ID")
+	})
+}
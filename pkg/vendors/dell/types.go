@@ -10,6 +10,13 @@ type DellVendor struct {
 	BaseURL string
 	// SystemIDs filters which system to include. If nil or empty, includes all systems. Example: ["0C60"]
 	SystemIDs []string
+	// BundleIDs restricts firmware to the content of the given software bundles. Example: ["GWVC2"]
+	BundleIDs []string
+	// LatestBundle restricts firmware to the content of the most recent bundle of each selected system
+	LatestBundle bool
+	// VersionFormats overrides the detected version format of components, keyed on their LU
+	// category. Example: {"BIOS": "triplet"}
+	VersionFormats map[string]string
 }
 
 // DellCatalog represents the catalog element of a Dell catalog
@@ -20,6 +27,7 @@ type DellCatalog struct {
 	BaseLocation       string                  `xml:"baseLocation,attr"`
 	SoftwareBundle     []DellSoftwareBundle    `xml:"SoftwareBundle"`
 	SoftwareComponents []DellSoftwareComponent `xml:"SoftwareComponent"`
+	VersionFormats     map[string]string       `xml:"-"`
 }
 
 // DellFirmwareEntry implements the FirmwareEntry interface for Dell
@@ -27,6 +35,9 @@ type DellFirmwareEntry struct {
 	Filename              string
 	DellSoftwareComponent *DellSoftwareComponent
 	SourceURL             string
+	// Bundles lists the software bundles this component belongs to
+	Bundles        []*DellSoftwareBundle
+	VersionFormats map[string]string
 }
 
 // DellSoftwareComponent represents a software component like firmware or driver
@@ -3,26 +3,53 @@ package hpe
 import (
 	"archive/zip"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/criteo/firmirror/pkg/firmirror"
 	"github.com/criteo/firmirror/pkg/lvfs"
 	"github.com/criteo/firmirror/pkg/utils"
 )
 
-// NewHPEVendor creates a new HPE vendor instance
-func NewHPEVendor(repo string) *HPEVendor {
+const (
+	// DefaultBaseURL is the root of the HPE Software Delivery Repository
+	DefaultBaseURL = "https://downloads.linux.hpe.com/SDR/repo"
+	// DefaultRelease is the directory pointing to the most recent release of a repository
+	DefaultRelease = "current"
+)
+
+// NewHPEVendor creates a new HPE vendor instance for a repository such as "fwpp-gen11"
+// or "spp-gen11". An empty baseURL or release selects the defaults, otherwise release
+// pins a dated release directory of the repository.
+func NewHPEVendor(baseURL, repo, release string) *HPEVendor {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
 	return &HPEVendor{
-		BaseURL: "https://downloads.linux.hpe.com/SDR/repo/" + repo,
+		BaseURL: strings.TrimSuffix(baseURL, "/") + "/" + repo,
+		Release: release,
 	}
 }
 
+// releaseURL returns the URL of the release directory of a repository
+func releaseURL(baseURL, release string) string {
+	if release == "" {
+		release = DefaultRelease
+	}
+	return baseURL + "/" + release
+}
+
 // FetchCatalog implements the Vendor interface
 func (hv *HPEVendor) FetchCatalog() (firmirror.Catalog, error) {
 	catalog, err := hv.fetchCatalog()
@@ -36,7 +63,7 @@ func (hv *HPEVendor) FetchCatalog() (firmirror.Catalog, error) {
 }
 
 func (hv *HPEVendor) fetchCatalog() (*HPECatalog, error) {
-	indexurl := hv.BaseURL + "/current/fwrepodata/fwrepo.json"
+	indexurl := releaseURL(hv.BaseURL, hv.Release) + "/fwrepodata/fwrepo.json"
 	jsondata, err := utils.DownloadFile(indexurl)
 	if err != nil {
 		return nil, err
@@ -51,6 +78,7 @@ func (hv *HPEVendor) fetchCatalog() (*HPECatalog, error) {
 	catalog := &HPECatalog{
 		Entries: entries,
 		BaseURL: hv.BaseURL,
+		Release: hv.Release,
 	}
 	return catalog, nil
 }
@@ -60,14 +88,28 @@ func (hv *HPEVendor) filterCatalog(catalog *HPECatalog) *HPECatalog {
 
 	for filename, entry := range catalog.Entries {
 		// Only include entries that end with .fwpkg
-		if strings.HasSuffix(filename, ".fwpkg") {
-			filteredEntries[filename] = entry
+		if !strings.HasSuffix(filename, ".fwpkg") {
+			continue
+		}
+
+		if len(hv.DeviceClasses) > 0 && !slices.ContainsFunc(hv.DeviceClasses, func(class string) bool {
+			return strings.EqualFold(class, entry.DeviceClass)
+		}) {
+			continue
 		}
+
+		filteredEntries[filename] = entry
 	}
 
+	// Supported products are only known from payload.json, so they are
+	// filtered when converting each entry
 	filteredCatalog := &HPECatalog{
-		Entries: filteredEntries,
-		BaseURL: catalog.BaseURL,
+		Entries:        filteredEntries,
+		BaseURL:        catalog.BaseURL,
+		Release:        catalog.Release,
+		Products:       hv.Products,
+		Urgencies:      hv.Urgencies,
+		VersionFormats: hv.VersionFormats,
 	}
 	return filteredCatalog
 }
@@ -81,7 +123,7 @@ func (hv *HPEVendor) RetrieveFirmware(entry firmirror.FirmwareEntry, tmpDir stri
 
 	filepath := filepath.Join(tmpDir, filepath.Base(hpeEntry.Filename))
 	if _, err := os.Stat(filepath); os.IsNotExist(err) {
-		if err := utils.DownloadFileToDest(hv.BaseURL+"/current/"+hpeEntry.Filename, filepath); err != nil {
+		if err := utils.DownloadFileToDest(releaseURL(hv.BaseURL, hv.Release)+"/"+hpeEntry.Filename, filepath); err != nil {
 			return err
 		}
 	}
@@ -97,9 +139,12 @@ func (hc *HPECatalog) ListEntries() []firmirror.FirmwareEntry {
 	for filename, catalogEntry := range hc.Entries {
 		entry := catalogEntry // Create a copy to avoid pointer issues
 		entries = append(entries, &HPEFirmwareEntry{
-			Filename:  filename,
-			Entry:     &entry,
-			SourceURL: hc.BaseURL + "/current/" + filename,
+			Filename:       filename,
+			Entry:          &entry,
+			SourceURL:      releaseURL(hc.BaseURL, hc.Release) + "/" + filename,
+			Products:       hc.Products,
+			Urgencies:      hc.Urgencies,
+			VersionFormats: hc.VersionFormats,
 		})
 	}
 	return entries
@@ -115,35 +160,100 @@ func (hfe *HPEFirmwareEntry) GetSourceURL() string {
 	return hfe.SourceURL
 }
 
+// GetVersion implements the CatalogRelease interface
+func (hfe *HPEFirmwareEntry) GetVersion() string {
+	if hfe.Entry == nil {
+		return ""
+	}
+	return lvfs.RedfishVersion(hfe.Entry.Version)
+}
+
+// GetGUIDs implements the CatalogRelease interface
+func (hfe *HPEFirmwareEntry) GetGUIDs() []string {
+	if hfe.Entry == nil {
+		return nil
+	}
+
+	var guids []string
+	for _, target := range hfe.Entry.Target {
+		if lvfs.IsGUID(target) {
+			guids = append(guids, lvfs.NormalizeGUID(target))
+		}
+	}
+	return guids
+}
+
 // ToAppstream implements the FirmwareEntry interface
-// HPE requires the firmware to be downloaded first, so we use the stored path
+// The conversion reads payload.json out of the fwpkg archive, either from the downloaded
+// file or remotely using range requests so that the whole package isn't downloaded.
 func (hfe *HPEFirmwareEntry) ToAppstream() (*lvfs.Component, error) {
-	if hfe.downloadPath == "" {
-		return nil, fmt.Errorf("firmware must be retrieved first using RetrieveFirmware")
+	payload, err := hfe.readPayload()
+	if err != nil {
+		return nil, err
 	}
 
-	payloadFile, err := readFileFromZip(hfe.downloadPath, "payload.json")
+	if len(hfe.Products) > 0 && !supportsProducts(payload.Package.SupportedProducts, hfe.Products) {
+		return nil, fmt.Errorf("%w: no supported product matches %v", firmirror.ErrSkipped, hfe.Products)
+	}
+
+	var catalogEntry HPECatalogEntry
+	if hfe.Entry != nil {
+		catalogEntry = *hfe.Entry
+	}
+
+	appstream, err := buildAppStream(*payload, catalogEntry, hfe.Filename)
 	if err != nil {
 		return nil, err
 	}
+	appstream.Releases[0].Urgency = getUrgency(payload.Package, hfe.Urgencies)
+	appstream.SetVersionFormat(getVersionFormat(payload.Package, appstream.Releases[0].Version, hfe.VersionFormats))
 
-	var payload HPEPayload
-	if err = json.Unmarshal(payloadFile, &payload); err != nil {
-		return nil, err
+	return appstream, nil
+}
+
+// readPayload returns the package payload.json, which is only read once
+func (hfe *HPEFirmwareEntry) readPayload() (*HPEPayload, error) {
+	if hfe.payload != nil {
+		return hfe.payload, nil
 	}
 
-	appstream, err := buildAppStream(payload)
+	var payloadFile []byte
+	var err error
+	switch {
+	case hfe.downloadPath != "":
+		payloadFile, err = readFileFromZip(hfe.downloadPath, "payload.json")
+	case hfe.SourceURL != "":
+		payloadFile, err = readRemoteFileFromZip(hfe.SourceURL, "payload.json")
+		if errors.Is(err, utils.ErrRangeNotSupported) {
+			return nil, firmirror.ErrNotRetrieved
+		}
+	default:
+		return nil, firmirror.ErrNotRetrieved
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return appstream, nil
+	var payload HPEPayload
+	if err = json.Unmarshal(payloadFile, &payload); err != nil {
+		return nil, err
+	}
+
+	hfe.payload = &payload
+	return hfe.payload, nil
 }
 
 // buildAppStream converts an HPE firmware payload to an AppStream component.
 // Note: we make the assumption that all devices in the payload will have the same version
 // as well as the install duration.
-func buildAppStream(fw HPEPayload) (*lvfs.Component, error) {
+func buildAppStream(fw HPEPayload, catalogEntry HPECatalogEntry, filename string) (*lvfs.Component, error) {
+	if len(fw.Devices.Device) == 0 {
+		return nil, fmt.Errorf("no device in payload")
+	}
+	if len(fw.Devices.Device[0].FirmwareImages) == 0 {
+		return nil, fmt.Errorf("no firmware image for device %q", fw.Devices.Device[0].DeviceName)
+	}
+
 	out := lvfs.Component{
 		Type:            "firmware",
 		MetadataLicense: "proprietary",
@@ -153,64 +263,73 @@ func buildAppStream(fw HPEPayload) (*lvfs.Component, error) {
 	var devices []string
 	for _, dev := range fw.Devices.Device {
 		devices = append(devices, dev.DeviceName)
-		// TODO:properly create GUID
-		// deviceclass ?
+	}
+	for _, guid := range lvfs.GUIDsFromInstanceIDs(deviceInstanceIDs(fw)) {
 		out.Provides = append(out.Provides, lvfs.Firmware{
 			Type: "flashed",
-			Text: dev.Target,
+			Text: guid,
 		})
 	}
 	slices.Sort(devices)
 	devices = slices.Compact(devices)
-	out.Name = strings.Join(devices[:], "/")
+	out.Name = lvfs.Translations{{Text: lvfs.SanitizeText(strings.Join(devices[:], "/"))}}
 
-	manufacturer, err := getString(fw.Package.ManufacturerName, "en")
+	manufacturerName, err := getTranslations(fw.Package.ManufacturerName)
 	if err != nil {
 		return nil, err
 	}
+	manufacturer := manufacturerName.String()
 	out.DeveloperName = manufacturer
-	out.ID = fmt.Sprintf("com.%s.%s", strings.ToLower(strings.ReplaceAll(manufacturer, " ", "")), strings.ReplaceAll(fw.Package.SwKeys[0].Name, " ", ""))
+	out.ID = componentID(manufacturer, packageKey(fw.Package, filename))
+
+	out.Requires, err = buildRequires(fw, catalogEntry, manufacturer)
+	if err != nil {
+		return nil, err
+	}
 
 	if fw.Package.Installation.RebootRequired == "yes" {
 		out.Custom = append(out.Custom, lvfs.Custom{
 			Key:   "LVFS::DeviceFlags",
 			Value: "skips-restart",
 		})
-		rebootMessage, err := getString(fw.Package.Installation.RebootDetails[0].Language, "en")
+		if len(fw.Package.Installation.RebootDetails) == 0 {
+			return nil, fmt.Errorf("no reboot details in payload")
+		}
+		rebootMessage, err := getTranslations(fw.Package.Installation.RebootDetails[0].Language)
 		if err != nil {
 			return nil, err
 		}
 
-		out.Custom = append(out.Custom, lvfs.Custom{
-			Key:   "LVFS::UpdateMessage",
-			Value: rebootMessage,
-		})
+		out.Custom = append(out.Custom, rebootMessage.Customs("LVFS::UpdateMessage")...)
 	}
 
-	summary, err := getString(fw.Package.Name, "en")
+	summary, err := getTranslations(fw.Package.Name)
 	if err != nil {
 		return nil, err
 	}
-	out.Summary = strings.ReplaceAll(strings.ReplaceAll(summary, "\t", ""), "  ", " ")
+	for i := range summary {
+		summary[i].Text = strings.ReplaceAll(strings.ReplaceAll(summary[i].Text, "\t", ""), "  ", " ")
+	}
+	out.Summary = summary
 
-	description, err := getString(fw.Package.Description, "en")
+	description, err := getTranslations(fw.Package.Description)
 	if err != nil {
 		return nil, err
 	}
-	out.Description = lvfs.Description{
-		Value: "<p>" + description + "</p>",
-	}
+	out.Description = description.Descriptions()
 
 	releaseDate, err := time.Parse("2006-01-02T15:04:05", fw.Package.ReleaseDate)
 	if err != nil {
 		return nil, err
 	}
 
+	// The payload has no revision history, and its description is the one of the device
+	// rather than of the release, so releases are published without notes
 	out.Releases = append(out.Releases, lvfs.Release{
-		Version:         fw.Devices.Device[0].Version,
+		Version:         lvfs.RedfishVersion(fw.Devices.Device[0].Version),
 		Date:            releaseDate.Format(time.DateOnly),
 		InstallDuration: fw.Devices.Device[0].FirmwareImages[0].InstallDurationSec,
-		Description:     out.Description,
+		Issues:          lvfs.ExtractIssues(summary.String(), description.String(), catalogEntry.Description),
 	})
 
 	for _, category := range fw.Package.Category {
@@ -236,13 +355,320 @@ func buildAppStream(fw HPEPayload) (*lvfs.Component, error) {
 	return &out, nil
 }
 
-func getString(strings []HPETranslations, language string) (string, error) {
+// urgencies maps the HPE criticality levels to AppStream urgencies, consistently with Dell
+var urgencies = map[string]string{
+	"critical":    "critical",
+	"urgent":      "high",
+	"high":        "high",
+	"recommended": "medium",
+	"medium":      "medium",
+	"optional":    "low",
+	"low":         "low",
+}
+
+// getUrgency returns the urgency of a package. Overrides keyed on its sw_key or category
+// come first, then its criticality and recommendation. It returns "" when unknown.
+func getUrgency(pkg HPEPackage, overrides map[string]string) string {
+	if urgency, ok := lookupPackage(overrides, pkg); ok {
+		return urgency
+	}
+
+	for _, level := range []string{pkg.Criticality, pkg.Recommendation} {
+		if urgency := parseUrgency(level); urgency != "" {
+			return urgency
+		}
+	}
+	return ""
+}
+
+// getVersionFormat returns the version format of a package, from the overrides keyed on its
+// sw_key or category, or else detected from its version
+func getVersionFormat(pkg HPEPackage, version string, overrides map[string]string) string {
+	if format, ok := lookupPackage(overrides, pkg); ok {
+		return format
+	}
+	return lvfs.DetectVersionFormat(version)
+}
+
+// lookupPackage returns the value of the sw_key of a package, or else of its category
+// key or English category name
+func lookupPackage(m map[string]string, pkg HPEPackage) (string, bool) {
+	for _, swKey := range pkg.SwKeys {
+		if value, ok := lookupFold(m, swKey.Name); ok {
+			return value, true
+		}
+	}
+	for _, category := range pkg.Category {
+		if value, ok := m[category.Key]; ok {
+			return value, true
+		}
+		if name, err := getTranslations(category.Languages); err == nil {
+			if value, ok := lookupFold(m, name.String()); ok {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// lookupFold returns the value of a key, matched case-insensitively
+func lookupFold(m map[string]string, key string) (string, bool) {
+	if value, ok := m[key]; ok {
+		return value, true
+	}
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		if strings.EqualFold(k, key) {
+			return m[k], true
+		}
+	}
+	return "", false
+}
+
+// parseUrgency reads the level at the start of texts such as
+// "Critical - HPE requires users update to this version immediately."
+func parseUrgency(level string) string {
+	words := strings.FieldsFunc(strings.ToLower(level), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+	return urgencies[words[0]]
+}
+
+// supportsProducts reports whether one of the supported products matches one of the filters
+func supportsProducts(supported []HPESupportedProduct, filters []string) bool {
+	for _, product := range supported {
+		for _, filter := range filters {
+			if matchesProduct(product.Value, filter) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesProduct reports whether the filter words appear in the product name, e.g.
+// "DL360 Gen10" in "HPE ProLiant DL360 Gen10 Server". A match followed by a model
+// revision is rejected, so that "DL360 Gen10" does not select "DL360 Gen10 Plus".
+func matchesProduct(product, filter string) bool {
+	split := func(s string) []string {
+		return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+	}
+
+	productWords := split(product)
+	filterWords := split(filter)
+	if len(filterWords) == 0 {
+		return false
+	}
+
+	for i := 0; i+len(filterWords) <= len(productWords); i++ {
+		if !slices.Equal(productWords[i:i+len(filterWords)], filterWords) {
+			continue
+		}
+		next := i + len(filterWords)
+		if next < len(productWords) && (productWords[next] == "plus" || productWords[next] == "v2") {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// packageKey returns an identifier of the package that is stable across releases: its
+// sw_key, or its product ID, or as a last resort its filename without the extension
+func packageKey(pkg HPEPackage, filename string) string {
+	for _, swKey := range pkg.SwKeys {
+		if strings.TrimSpace(swKey.Name) != "" {
+			return swKey.Name
+		}
+	}
+	if pkg.ID.Product != "" {
+		return pkg.ID.Product
+	}
+	return strings.TrimSuffix(filename, filepath.Ext(filename))
+}
+
+func componentID(manufacturer, swKey string) string {
+	return fmt.Sprintf("com.%s.%s", strings.ToLower(strings.ReplaceAll(manufacturer, " ", "")), strings.ReplaceAll(swKey, " ", ""))
+}
+
+var requirementRegexp = regexp.MustCompile(`^\s*(\S+)\s*(>=|<=|==|!=|=|>|<)\s*(\S+)\s*$`)
+
+// compareOperators maps comparison operators to the fwupd requirement comparisons
+var compareOperators = map[string]string{
+	">=": "ge",
+	">":  "gt",
+	"<=": "le",
+	"<":  "lt",
+	"=":  "eq",
+	"==": "eq",
+	"!=": "ne",
+}
+
+// reversedOperators is used when a requirement puts the version before its target
+var reversedOperators = map[string]string{
+	">=": "<=",
+	">":  "<",
+	"<=": ">=",
+	"<":  ">",
+	"=":  "=",
+	"==": "==",
+	"!=": "!=",
+}
+
+// buildRequires translates the package installation dependencies and the catalog minimum
+// active version into requirements, so that fwupd refuses update paths HPE does not support.
+// Requirements are written as "<target> <operator> <version>", where the target is either a
+// device GUID or the sw_key of another package.
+func buildRequires(fw HPEPayload, catalogEntry HPECatalogEntry, manufacturer string) (lvfs.Requires, error) {
+	var requires lvfs.Requires
+
+	if v := catalogEntry.MinimumActiveVersion; v != "" && v != "null" {
+		requires.Firmware = append(requires.Firmware, lvfs.Firmware{
+			Compare: "ge",
+			Version: lvfs.RedfishVersion(v),
+		})
+	}
+
+	ownTargets := make(map[string]bool)
+	for _, dev := range fw.Devices.Device {
+		ownTargets[lvfs.NormalizeGUID(dev.Target)] = true
+	}
+
+	for _, requirement := range fw.Package.InstallationDependency.DependencyRequirement.Requires.Requirements {
+		match := requirementRegexp.FindStringSubmatch(requirement)
+		if match == nil {
+			return requires, fmt.Errorf("invalid installation requirement: %q", requirement)
+		}
+
+		target, operator, version := match[1], match[2], match[3]
+		if startsWithDigit(target) && !lvfs.IsGUID(target) {
+			target, version = version, target
+			operator = reversedOperators[operator]
+		}
+		compare := compareOperators[operator]
+
+		switch {
+		case ownTargets[lvfs.NormalizeGUID(target)]:
+			// Minimum version of the device being updated
+			requires.Firmware = append(requires.Firmware, lvfs.Firmware{
+				Compare: compare,
+				Version: version,
+			})
+		case lvfs.IsGUID(target):
+			// Version of another device of the system
+			requires.Firmware = append(requires.Firmware, lvfs.Firmware{
+				Compare: compare,
+				Version: version,
+				Text:    lvfs.NormalizeGUID(target),
+			})
+		default:
+			// Version of another package
+			requires.ID = append(requires.ID, lvfs.ID{
+				Compare: compare,
+				Version: version,
+				Text:    componentID(manufacturer, target),
+			})
+		}
+	}
+
+	return requires, nil
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// deviceInstanceIDs returns the instance IDs fwupd reports for the devices updated by this
+// payload. iLO lists the target GUIDs of each firmware inventory item, which are the payload
+// device targets. PCI devices such as NICs and controllers are also matched on the instance IDs
+// the Redfish plugin builds from their related PCIe function.
+// The payload DeviceClass identifies a family of devices rather than a device, so it is not used.
+func deviceInstanceIDs(fw HPEPayload) []string {
+	var instanceIDs []string
+	add := func(instanceID string) {
+		if !slices.Contains(instanceIDs, instanceID) {
+			instanceIDs = append(instanceIDs, instanceID)
+		}
+	}
+
+	for _, dev := range fw.Devices.Device {
+		if lvfs.IsGUID(dev.Target) {
+			add(lvfs.NormalizeGUID(dev.Target))
+		}
+	}
+
+	for _, dev := range fw.Package.Prerequisites.SupportedDevices {
+		if lvfs.IsGUID(dev.TargetGuid) {
+			add(lvfs.NormalizeGUID(dev.TargetGuid))
+		}
+		for _, instanceID := range pciInstanceIDs(dev) {
+			add(instanceID)
+		}
+	}
+
+	return instanceIDs
+}
+
+// pciInstanceIDs returns the PCI instance IDs of a supported device. The vendor and device
+// pair alone would match every board built around the same chip, so it is only used when
+// the subsystem is unknown.
+func pciInstanceIDs(dev HPESupportedDevice) []string {
+	if dev.Type != "" && !strings.EqualFold(dev.Type, "pci") {
+		return nil
+	}
+
+	ven, okVen := pciID(dev.Ven)
+	devID, okDev := pciID(dev.Dev)
+	if !okVen || !okDev {
+		return nil
+	}
+
+	subven, okSubven := pciID(dev.SubVen)
+	subdev, okSubdev := pciID(dev.SubDev)
+	if okSubven && okSubdev {
+		return []string{fmt.Sprintf("PCI\\VEN_%s&DEV_%s&SUBSYS_%s%s", ven, devID, subven, subdev)}
+	}
+	return []string{fmt.Sprintf("PCI\\VEN_%s&DEV_%s", ven, devID)}
+}
+
+// pciID formats a PCI identifier the way fwupd does, as 4 uppercase hexadecimal digits
+func pciID(value string) (string, bool) {
+	value = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), "0x")
+	id, err := strconv.ParseUint(value, 16, 16)
+	if value == "" || err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%04X", id), true
+}
+
+// getTranslations returns every language of a translated string, English being the
+// untranslated value, sanitized. It fails when the English value is missing.
+func getTranslations(strings []HPETranslations) (lvfs.Translations, error) {
+	var out lvfs.Translations
+	seen := map[string]bool{}
 	for _, l := range strings {
-		if l.Lang == language {
-			return l.XLate, nil
+		lang := lvfs.NormalizeLang(l.Lang)
+		if seen[lang] {
+			continue
+		}
+		seen[lang] = true
+
+		text := lvfs.SanitizeText(l.XLate)
+		if lang == "" {
+			out = slices.Insert(out, 0, lvfs.Translation{Text: text})
+		} else {
+			out = append(out, lvfs.Translation{Lang: lang, Text: text})
 		}
 	}
-	return "", fmt.Errorf("language not found: %s", language)
+
+	if !seen[""] {
+		return out, fmt.Errorf("language not found: en")
+	}
+	return out, nil
 }
 
 func readFileFromZip(zipFile, filename string) ([]byte, error) {
@@ -252,6 +678,26 @@ func readFileFromZip(zipFile, filename string) ([]byte, error) {
 	}
 	defer archive.Close()
 
+	return readZipFile(&archive.Reader, filename)
+}
+
+// readRemoteFileFromZip extracts a file from a remote zip archive. Only the central
+// directory and the file itself are fetched, using range requests.
+func readRemoteFileFromZip(url, filename string) ([]byte, error) {
+	remote, err := utils.NewHTTPReaderAt(url)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := zip.NewReader(remote, remote.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	return readZipFile(archive, filename)
+}
+
+func readZipFile(archive *zip.Reader, filename string) ([]byte, error) {
 	for _, f := range archive.File {
 		if f.Name == filename {
 			reader, err := f.Open()
@@ -2,22 +2,66 @@ package hpe
 
 import (
 	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/criteo/firmirror/pkg/firmirror"
+	"github.com/criteo/firmirror/pkg/lvfs"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewHPEVendor(t *testing.T) {
-	repo := "test-repo"
-	vendor := NewHPEVendor(repo)
+	t.Run("Defaults", func(t *testing.T) {
+		vendor := NewHPEVendor("", "test-repo", "")
 
-	assert.NotNil(t, vendor, "Vendor should not be nil")
-	expectedBaseURL := "https://downloads.linux.hpe.com/SDR/repo/test-repo"
-	assert.Equal(t, expectedBaseURL, vendor.BaseURL, "BaseURL should be correctly constructed")
+		assert.NotNil(t, vendor, "Vendor should not be nil")
+		expectedBaseURL := "https://downloads.linux.hpe.com/SDR/repo/test-repo"
+		assert.Equal(t, expectedBaseURL, vendor.BaseURL, "BaseURL should be correctly constructed")
+		assert.Empty(t, vendor.Release, "Release should default to the current release")
+	})
+
+	t.Run("CustomBaseURLAndRelease", func(t *testing.T) {
+		vendor := NewHPEVendor("https://mirror.example.com/SDR/repo/", "spp-gen11", "2024.09.00.00")
+
+		assert.Equal(t, "https://mirror.example.com/SDR/repo/spp-gen11", vendor.BaseURL, "BaseURL should be correctly constructed")
+		assert.Equal(t, "2024.09.00.00", vendor.Release, "Release should be pinned")
+	})
+}
+
+func TestHPEVendor_PinnedRelease(t *testing.T) {
+	server := mockServer(t)
+	defer server.Close()
+
+	vendor := &HPEVendor{
+		BaseURL: server.URL,
+		Release: "2024.09.00.00",
+	}
+
+	catalog, err := vendor.FetchCatalog()
+	assert.NoError(t, err, "FetchCatalog should not return an error")
+
+	entries := catalog.ListEntries()
+	assert.NotEmpty(t, entries, "Catalog should contain entries")
+	for _, entry := range entries {
+		assert.Equal(t, server.URL+"/2024.09.00.00/"+entry.GetFilename(), entry.GetSourceURL(), "Source URL should use the pinned release")
+	}
+
+	tmpDir := t.TempDir()
+	err = vendor.RetrieveFirmware(entries[0], tmpDir)
+	assert.NoError(t, err, "RetrieveFirmware should not return an error")
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, entries[0].GetFilename()))
+	assert.NoError(t, err, "Should be able to read downloaded file")
+	assert.Equal(t, "Mock firmware content for "+entries[0].GetFilename(), string(content), "Firmware should be downloaded from the pinned release")
 }
 
 func TestHPEVendor_FetchCatalog(t *testing.T) {
@@ -42,6 +86,29 @@ func TestHPEVendor_FetchCatalog(t *testing.T) {
 	}
 }
 
+func TestHPEVendor_FetchCatalog_DeviceClasses(t *testing.T) {
+	server := mockServer(t)
+	defer server.Close()
+
+	vendor := &HPEVendor{
+		BaseURL:       server.URL,
+		DeviceClasses: []string{"2F317B9D-C9E3-4D76-BFF6-B9D0D085A952"},
+		Products:      []string{"DL380 Gen11"},
+	}
+
+	catalog, err := vendor.FetchCatalog()
+	assert.NoError(t, err, "FetchCatalog should not return an error")
+
+	hpeCatalog, ok := catalog.(*HPECatalog)
+	assert.True(t, ok, "Catalog should be of type *HPECatalog")
+	assert.Len(t, hpeCatalog.Entries, 1, "Only the iLO device class should be included")
+	assert.Contains(t, hpeCatalog.Entries, "test-ilo-firmware-v2.1.0.fwpkg")
+
+	for _, entry := range catalog.ListEntries() {
+		assert.Equal(t, []string{"DL380 Gen11"}, entry.(*HPEFirmwareEntry).Products, "Products filter should be passed to entries")
+	}
+}
+
 func TestHPEVendor_RetrieveFirmware(t *testing.T) {
 	server := mockServer(t)
 	defer server.Close()
@@ -157,7 +224,7 @@ func TestHPEFirmwareEntry_ToAppstream_Success(t *testing.T) {
 	tmpDir := t.TempDir()
 
 	// Create a mock firmware zip file with payload.json
-	mockFirmwarePath := createMockHPEFirmware(t, tmpDir)
+	mockFirmwarePath := createMockHPEFirmware(t, tmpDir, "payload.json")
 
 	entry := &HPEFirmwareEntry{
 		Filename:     "test-firmware.fwpkg",
@@ -171,7 +238,7 @@ func TestHPEFirmwareEntry_ToAppstream_Success(t *testing.T) {
 
 	// Verify basic component properties
 	assert.Equal(t, "firmware", component.Type, "Component type should be firmware")
-	assert.Equal(t, "Network Device", component.Name, "Component name should match")
+	assert.Equal(t, "Network Device", component.Name.String(), "Component name should match")
 	assert.Equal(t, "Hewlett Packard Enterprise", component.DeveloperName, "Developer name should be HPE")
 
 	// Verify releases
@@ -199,6 +266,69 @@ func TestHPEFirmwareEntry_ToAppstream_Success(t *testing.T) {
 	assert.Equal(t, "flashed", component.Provides[0].Type, "Provides type should be flashed")
 }
 
+func TestHPEFirmwareEntry_ToAppstream_Translations(t *testing.T) {
+	tmpDir := t.TempDir()
+	mockFirmwarePath := createMockHPEFirmware(t, tmpDir, "payload_nic.json")
+
+	entry := &HPEFirmwareEntry{
+		Filename:     "test-firmware.fwpkg",
+		Entry:        &HPECatalogEntry{},
+		downloadPath: mockFirmwarePath,
+	}
+
+	component, err := entry.ToAppstream()
+	assert.NoError(t, err, "ToAppstream should not return an error")
+
+	assert.Equal(t, lvfs.Translations{
+		{Text: "Test Network Firmware"},
+		{Lang: "ja", Text: "テスト ネットワークファームウェア"},
+	}, component.Summary, "Summary should list English first, then every translation")
+
+	assert.Equal(t, []lvfs.Description{
+		{Blocks: []lvfs.Block{{Paragraph: "Test firmware for network adapter"}}},
+		{Lang: "ja", Blocks: []lvfs.Block{{Paragraph: "ネットワークアダプター用テストファームウェア"}}},
+	}, component.Description, "Description should be translated")
+	assert.Empty(t, component.Releases[0].Description, "Package description should not be repeated as release notes")
+
+	messages := map[string]string{}
+	for _, custom := range component.Custom {
+		if custom.Key == "LVFS::UpdateMessage" {
+			messages[custom.Lang] = custom.Value
+		}
+	}
+	assert.Len(t, messages, 5, "Update message should be emitted for every language")
+	assert.Contains(t, messages[""], "Reboot is required", "English update message should be untranslated")
+	assert.Contains(t, messages, "zh_CN", "HPE cn language should map to zh_CN")
+	assert.Contains(t, messages, "zh_TW", "HPE tw language should map to zh_TW")
+
+	out, err := xml.Marshal(component)
+	assert.NoError(t, err, "Component should marshal")
+	assert.Contains(t, string(out), `<summary xml:lang="ja">`, "Translations should be marshalled with xml:lang")
+	assert.Contains(t, string(out), `<value key="LVFS::UpdateMessage" xml:lang="zh_CN">`, "Update messages should be marshalled with xml:lang")
+
+	var parsed lvfs.Component
+	assert.NoError(t, xml.Unmarshal(out, &parsed), "Component should unmarshal")
+	assert.Equal(t, component.Summary, parsed.Summary, "Translations should survive a round-trip")
+	assert.Equal(t, component.Description, parsed.Description, "Descriptions should survive a round-trip")
+}
+
+func TestHPEFirmwareEntry_ToAppstream_Issues(t *testing.T) {
+	tmpDir := t.TempDir()
+	mockFirmwarePath := createMockHPEFirmware(t, tmpDir, "payload.json")
+
+	entry := &HPEFirmwareEntry{
+		Filename:     "test-firmware.fwpkg",
+		Entry:        &HPECatalogEntry{Description: "Fixes CVE-2024-1234, see HPESBHF04456."},
+		downloadPath: mockFirmwarePath,
+	}
+
+	component, err := entry.ToAppstream()
+	assert.NoError(t, err, "ToAppstream should not return an error")
+	assert.Equal(t, []lvfs.Issue{
+		{Type: "cve", Text: "CVE-2024-1234"},
+	}, component.Releases[0].Issues, "CVEs should be extracted from the catalog description, without the HPE bulletin")
+}
+
 func TestHPEFirmwareEntry_ToAppstream_MissingPayload(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -232,8 +362,8 @@ func TestHPEFirmwareEntry_ToAppstream_MissingPayload(t *testing.T) {
 func mockServer(t *testing.T) *httptest.Server {
 	mux := http.NewServeMux()
 
-	// Serve the test catalog JSON
-	mux.HandleFunc("/current/fwrepodata/fwrepo.json", func(w http.ResponseWriter, r *http.Request) {
+	// Serve the test catalog JSON, for the current and pinned releases
+	mux.HandleFunc("/{release}/fwrepodata/fwrepo.json", func(w http.ResponseWriter, r *http.Request) {
 		catalogPath := filepath.Join("testdata", "catalog.json")
 		content, err := os.ReadFile(catalogPath)
 		if !assert.NoError(t, err, "Should be able to read test catalog") {
@@ -246,7 +376,7 @@ func mockServer(t *testing.T) *httptest.Server {
 	})
 
 	// Serve mock firmware files
-	mux.HandleFunc("/current/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/{release}/{filename}", func(w http.ResponseWriter, r *http.Request) {
 		filename := filepath.Base(r.URL.Path)
 
 		// Return mock firmware content
@@ -257,8 +387,8 @@ func mockServer(t *testing.T) *httptest.Server {
 	return httptest.NewServer(mux)
 }
 
-// Helper function to create a mock HPE firmware zip file with payload.json
-func createMockHPEFirmware(t *testing.T, dir string) string {
+// Helper function to create a mock HPE firmware zip file with a payload.json fixture
+func createMockHPEFirmware(t *testing.T, dir, fixture string) string {
 	firmwarePath := filepath.Join(dir, "test-firmware.fwpkg")
 
 	// Create zip file
@@ -269,8 +399,7 @@ func createMockHPEFirmware(t *testing.T, dir string) string {
 	zipWriter := zip.NewWriter(zipFile)
 	defer zipWriter.Close()
 
-	payloadJSON := filepath.Join("testdata", "payload.json")
-	content, err := os.ReadFile(payloadJSON)
+	content, err := os.ReadFile(filepath.Join("testdata", fixture))
 	assert.NoError(t, err, "Should be able to read test payload")
 
 	payloadFile, err := zipWriter.Create("payload.json")
@@ -281,3 +410,516 @@ func createMockHPEFirmware(t *testing.T, dir string) string {
 
 	return firmwarePath
 }
+
+func TestDeviceInstanceIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		modify   func(*HPEPayload)
+		expected []string
+	}{
+		{
+			name:    "NetworkAdapter",
+			fixture: "payload_nic.json",
+			expected: []string{
+				"a6b1a447-382a-5a4f-15b3-101d15b30042",
+				`PCI\VEN_15B3&DEV_101D&SUBSYS_15B30042`,
+			},
+		},
+		{
+			name:    "ILO",
+			fixture: "payload_ilo.json",
+			expected: []string{
+				"4764a662-b342-4fc7-9ce9-258c5d99e815",
+			},
+		},
+		{
+			name:    "PCIWithoutSubsystem",
+			fixture: "payload.json",
+			modify: func(p *HPEPayload) {
+				p.Devices.Device = nil
+				p.Package.Prerequisites.SupportedDevices = []HPESupportedDevice{
+					{Ven: "0x8086", Dev: "0x1593", Type: "PCI"},
+				}
+			},
+			expected: []string{
+				`PCI\VEN_8086&DEV_1593`,
+			},
+		},
+		{
+			name:    "InvalidIdentifiers",
+			fixture: "payload.json",
+			modify: func(p *HPEPayload) {
+				p.Devices.Device = []HPEDevice{{Target: "null"}}
+				p.Package.Prerequisites.SupportedDevices = []HPESupportedDevice{
+					{Ven: "not-hex", Dev: "1593"},
+					{Ven: "8086", Dev: "1593", Type: "usb"},
+				}
+			},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := loadPayload(t, tt.fixture)
+			if tt.modify != nil {
+				tt.modify(&payload)
+			}
+			assert.Equal(t, tt.expected, deviceInstanceIDs(payload), "Instance IDs should match the ones fwupd reports")
+		})
+	}
+}
+
+// Helper function to load a payload.json fixture
+func loadPayload(t *testing.T, fixture string) HPEPayload {
+	content, err := os.ReadFile(filepath.Join("testdata", fixture))
+	assert.NoError(t, err, "Should be able to read test payload")
+
+	var payload HPEPayload
+	err = json.Unmarshal(content, &payload)
+	assert.NoError(t, err, "Should be able to parse test payload")
+	return payload
+}
+
+func TestGetUrgency(t *testing.T) {
+	network := HPECategory{
+		Key:       "2900095",
+		Languages: []HPETranslations{{Lang: "en", XLate: "Firmware - Network"}},
+	}
+
+	tests := []struct {
+		name      string
+		pkg       HPEPackage
+		overrides map[string]string
+		expected  string
+	}{
+		{
+			name:     "Unknown",
+			pkg:      HPEPackage{},
+			expected: "",
+		},
+		{
+			name:     "Critical",
+			pkg:      HPEPackage{Criticality: "Critical - HPE requires users update to this version immediately."},
+			expected: "critical",
+		},
+		{
+			name:     "Urgent",
+			pkg:      HPEPackage{Criticality: "URGENT"},
+			expected: "high",
+		},
+		{
+			name:     "Recommended",
+			pkg:      HPEPackage{Criticality: "Recommended-HPE recommends users update to this version at their earliest convenience."},
+			expected: "medium",
+		},
+		{
+			name:     "Optional",
+			pkg:      HPEPackage{Criticality: "Optional: users should update to this version if their system is affected"},
+			expected: "low",
+		},
+		{
+			name:     "RecommendationFallback",
+			pkg:      HPEPackage{Criticality: "n/a", Recommendation: "Recommended"},
+			expected: "medium",
+		},
+		{
+			name:      "SwKeyOverride",
+			pkg:       HPEPackage{Criticality: "Optional", SwKeys: []HPESwKey{{Name: "ilo6"}}, Category: []HPECategory{network}},
+			overrides: map[string]string{"ILO6": "critical", "2900095": "high"},
+			expected:  "critical",
+		},
+		{
+			name:      "CategoryKeyOverride",
+			pkg:       HPEPackage{Criticality: "Optional", Category: []HPECategory{network}},
+			overrides: map[string]string{"2900095": "high"},
+			expected:  "high",
+		},
+		{
+			name:      "CategoryNameOverride",
+			pkg:       HPEPackage{Criticality: "Critical", Category: []HPECategory{network}},
+			overrides: map[string]string{"firmware - network": "low"},
+			expected:  "low",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, getUrgency(test.pkg, test.overrides), "Urgency should match")
+		})
+	}
+}
+
+func TestHPEFirmwareEntry_ToAppstream_Urgency(t *testing.T) {
+	payload := loadPayload(t, "payload_ilo.json")
+
+	t.Run("FromCriticality", func(t *testing.T) {
+		entry := &HPEFirmwareEntry{Filename: "ilo6.fwpkg", Entry: &HPECatalogEntry{}, payload: &payload}
+
+		component, err := entry.ToAppstream()
+		assert.NoError(t, err, "ToAppstream should not return an error")
+		assert.Equal(t, "critical", component.Releases[0].Urgency, "Urgency should come from the criticality")
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		entry := &HPEFirmwareEntry{
+			Filename:  "ilo6.fwpkg",
+			Entry:     &HPECatalogEntry{},
+			Urgencies: map[string]string{"Firmware - iLO": "medium"},
+			payload:   &payload,
+		}
+
+		component, err := entry.ToAppstream()
+		assert.NoError(t, err, "ToAppstream should not return an error")
+		assert.Equal(t, "medium", component.Releases[0].Urgency, "Override should take precedence")
+	})
+}
+
+func TestGetVersionFormat(t *testing.T) {
+	rom := HPECategory{
+		Key:       "2900200",
+		Languages: []HPETranslations{{Lang: "en", XLate: "Firmware - System ROM"}},
+	}
+
+	tests := []struct {
+		name      string
+		pkg       HPEPackage
+		version   string
+		overrides map[string]string
+		expected  string
+	}{
+		{name: "Pair", version: "1.48", expected: lvfs.VersionFormatPair},
+		{name: "Triplet", version: "22.41.1000", expected: lvfs.VersionFormatTriplet},
+		{name: "Plain", version: "U46 2024", expected: lvfs.VersionFormatPlain},
+		{
+			name:      "SwKeyOverride",
+			pkg:       HPEPackage{SwKeys: []HPESwKey{{Name: "u46"}}, Category: []HPECategory{rom}},
+			version:   "2.10",
+			overrides: map[string]string{"U46": lvfs.VersionFormatPlain},
+			expected:  lvfs.VersionFormatPlain,
+		},
+		{
+			name:      "CategoryNameOverride",
+			pkg:       HPEPackage{Category: []HPECategory{rom}},
+			version:   "2.10",
+			overrides: map[string]string{"Firmware - System ROM": lvfs.VersionFormatPlain},
+			expected:  lvfs.VersionFormatPlain,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, getVersionFormat(test.pkg, test.version, test.overrides), "Version format should match")
+		})
+	}
+}
+
+func TestHPEFirmwareEntry_ToAppstream_VersionFormat(t *testing.T) {
+	payload := loadPayload(t, "payload_ilo.json")
+
+	t.Run("Detected", func(t *testing.T) {
+		entry := &HPEFirmwareEntry{Filename: "ilo6.fwpkg", Entry: &HPECatalogEntry{}, payload: &payload}
+
+		component, err := entry.ToAppstream()
+		require.NoError(t, err, "ToAppstream should not return an error")
+		assert.Equal(t, lvfs.VersionFormatPair, component.VersionFormat(), "Version format should be detected")
+		assert.NoError(t, component.Validate(), "Component should be valid")
+	})
+
+	t.Run("RedfishVersion", func(t *testing.T) {
+		rom := loadPayload(t, "payload_ilo.json")
+		rom.Devices.Device[0].Version = "U46 v2.10 (05/22/2024)"
+		entry := &HPEFirmwareEntry{
+			Filename:       "u46.fwpkg",
+			Entry:          &HPECatalogEntry{Version: "U46 v2.10 (05/22/2024)", MinimumActiveVersion: "U46 v1.50 (01/10/2023)"},
+			VersionFormats: map[string]string{"Firmware - iLO": lvfs.VersionFormatTriplet},
+			payload:        &rom,
+		}
+
+		component, err := entry.ToAppstream()
+		require.NoError(t, err, "ToAppstream should not return an error")
+		assert.Equal(t, "2.10", component.Releases[0].Version, "Release version should match the one fwupd reports")
+		assert.Equal(t, "2.10", entry.GetVersion(), "Catalog version should match the release")
+		assert.Equal(t, "1.50", component.Requires.Firmware[0].Version, "Minimum version should match the one fwupd reports")
+		assert.Equal(t, lvfs.VersionFormatTriplet, component.VersionFormat(), "Override should take precedence")
+	})
+}
+
+func TestPackageKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		pkg      HPEPackage
+		expected string
+	}{
+		{
+			name:     "SwKey",
+			pkg:      HPEPackage{SwKeys: []HPESwKey{{Name: " "}, {Name: "ilo6"}}, ID: HPEID{Product: "23c569ea"}},
+			expected: "ilo6",
+		},
+		{
+			name:     "ProductID",
+			pkg:      HPEPackage{ID: HPEID{Product: "23c569ea", Version: "44a7a2b1"}},
+			expected: "23c569ea",
+		},
+		{
+			name:     "Filename",
+			pkg:      HPEPackage{},
+			expected: "U54_2.10_05_21_2024",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, packageKey(test.pkg, "U54_2.10_05_21_2024.fwpkg"), "Package key should match")
+		})
+	}
+}
+
+func TestHPEFirmwareEntry_ToAppstream_WithoutSwKeys(t *testing.T) {
+	payload := loadPayload(t, "payload_ilo.json")
+	payload.Package.SwKeys = nil
+
+	entry := &HPEFirmwareEntry{Filename: "ilo6_148.fwpkg", Entry: &HPECatalogEntry{}, payload: &payload}
+
+	component, err := entry.ToAppstream()
+	assert.NoError(t, err, "ToAppstream should not panic nor fail without sw_keys")
+	assert.Equal(t, componentID("Hewlett Packard Enterprise", payload.Package.ID.Product), component.ID, "ID should fall back to the product ID")
+}
+
+func TestHPEFirmwareEntry_ToAppstream_EmptyPayload(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*HPEPayload)
+	}{
+		{name: "Empty", modify: func(p *HPEPayload) { *p = HPEPayload{} }},
+		{name: "NoDevice", modify: func(p *HPEPayload) { p.Devices.Device = nil }},
+		{name: "NoFirmwareImage", modify: func(p *HPEPayload) { p.Devices.Device[0].FirmwareImages = nil }},
+		{name: "NoRebootDetails", modify: func(p *HPEPayload) { p.Package.Installation.RebootDetails = nil }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload := loadPayload(t, "payload.json")
+			test.modify(&payload)
+			entry := &HPEFirmwareEntry{Filename: "firmware.fwpkg", Entry: &HPECatalogEntry{}, payload: &payload}
+
+			assert.NotPanics(t, func() {
+				_, err := entry.ToAppstream()
+				assert.Error(t, err, "Incomplete payloads should be rejected")
+			})
+		})
+	}
+}
+
+func TestBuildRequires(t *testing.T) {
+	tests := []struct {
+		name           string
+		requirements   []string
+		minimumVersion string
+		expected       lvfs.Requires
+		expectErr      bool
+	}{
+		{
+			name:           "NoRequirement",
+			minimumVersion: "null",
+			expected:       lvfs.Requires{},
+		},
+		{
+			name:           "MinimumActiveVersion",
+			minimumVersion: "16.28.1002",
+			expected: lvfs.Requires{
+				Firmware: []lvfs.Firmware{{Compare: "ge", Version: "16.28.1002"}},
+			},
+		},
+		{
+			name:         "OwnDevice",
+			requirements: []string{"A6B1A447-382A-5A4F-15B3-101D15B30042 >= 22.39.1002"},
+			expected: lvfs.Requires{
+				Firmware: []lvfs.Firmware{{Compare: "ge", Version: "22.39.1002"}},
+			},
+		},
+		{
+			name:         "OtherDevice",
+			requirements: []string{"4764a662-b342-4fc7-9ce9-258c5d99e815>1.40"},
+			expected: lvfs.Requires{
+				Firmware: []lvfs.Firmware{{Compare: "gt", Version: "1.40", Text: "4764a662-b342-4fc7-9ce9-258c5d99e815"}},
+			},
+		},
+		{
+			name:         "OtherPackageVersionFirst",
+			requirements: []string{"1.48 <= ilo6"},
+			expected: lvfs.Requires{
+				ID: []lvfs.ID{{Compare: "ge", Version: "1.48", Text: "com.hewlettpackardenterprise.ilo6"}},
+			},
+		},
+		{
+			name:         "InvalidRequirement",
+			requirements: []string{"requires a recent iLO"},
+			expectErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := loadPayload(t, "payload.json")
+			payload.Package.InstallationDependency.DependencyRequirement.Requires.Requirements = tt.requirements
+
+			requires, err := buildRequires(payload, HPECatalogEntry{MinimumActiveVersion: tt.minimumVersion}, "Hewlett Packard Enterprise")
+			if tt.expectErr {
+				assert.Error(t, err, "Should reject unparseable requirements")
+				return
+			}
+			assert.NoError(t, err, "buildRequires should not return an error")
+			assert.Equal(t, tt.expected, requires, "Requirements should match")
+		})
+	}
+}
+
+func TestHPEFirmwareEntry_ToAppstream_Remote(t *testing.T) {
+	// Build a package with a large firmware image next to payload.json
+	var archive bytes.Buffer
+	zipWriter := zip.NewWriter(&archive)
+	image, err := zipWriter.CreateHeader(&zip.FileHeader{Name: "firmware.bin", Method: zip.Store})
+	assert.NoError(t, err, "Should be able to create firmware image in zip")
+	_, err = image.Write(bytes.Repeat([]byte{0xAA}, 4<<20))
+	assert.NoError(t, err, "Should be able to write firmware image")
+	payloadFile, err := zipWriter.Create("payload.json")
+	assert.NoError(t, err, "Should be able to create payload.json in zip")
+	content, err := os.ReadFile(filepath.Join("testdata", "payload.json"))
+	assert.NoError(t, err, "Should be able to read test payload")
+	_, err = payloadFile.Write(content)
+	assert.NoError(t, err, "Should be able to write payload JSON")
+	assert.NoError(t, zipWriter.Close(), "Should be able to close zip")
+
+	t.Run("RangeRequests", func(t *testing.T) {
+		var served, requests atomic.Int64
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			http.ServeContent(&countingResponseWriter{ResponseWriter: w, count: &served}, r, "test.fwpkg", time.Time{}, bytes.NewReader(archive.Bytes()))
+		}))
+		defer server.Close()
+
+		entry := &HPEFirmwareEntry{
+			Filename:  "test.fwpkg",
+			Entry:     &HPECatalogEntry{},
+			SourceURL: server.URL + "/current/test.fwpkg",
+		}
+
+		component, err := entry.ToAppstream()
+		assert.NoError(t, err, "ToAppstream should not return an error")
+		assert.Equal(t, "Network Device", component.Name.String(), "Component name should match")
+		assert.Less(t, served.Load(), int64(archive.Len()/10), "Only a small part of the package should be downloaded")
+		assert.LessOrEqual(t, requests.Load(), int64(4), "Small reads of the archive should be served from a few requests")
+
+		// The payload is cached for later conversions
+		served.Store(0)
+		_, err = entry.ToAppstream()
+		assert.NoError(t, err, "ToAppstream should not return an error")
+		assert.Zero(t, served.Load(), "Payload should not be fetched twice")
+	})
+
+	t.Run("RangesNotAdvertised", func(t *testing.T) {
+		// Like many CDNs, the server honours Range without sending Accept-Ranges
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.ServeContent(&hidingResponseWriter{ResponseWriter: w, header: "Accept-Ranges"}, r, "test.fwpkg", time.Time{}, bytes.NewReader(archive.Bytes()))
+		}))
+		defer server.Close()
+
+		entry := &HPEFirmwareEntry{
+			Filename:  "test.fwpkg",
+			Entry:     &HPECatalogEntry{},
+			SourceURL: server.URL + "/current/test.fwpkg",
+		}
+
+		component, err := entry.ToAppstream()
+		assert.NoError(t, err, "Range support should be detected without Accept-Ranges")
+		assert.Equal(t, "Network Device", component.Name.String(), "Component name should match")
+	})
+
+	t.Run("NoRangeSupport", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(archive.Bytes())
+		}))
+		defer server.Close()
+
+		entry := &HPEFirmwareEntry{
+			Filename:  "test.fwpkg",
+			Entry:     &HPECatalogEntry{},
+			SourceURL: server.URL + "/current/test.fwpkg",
+		}
+
+		_, err := entry.ToAppstream()
+		assert.ErrorIs(t, err, firmirror.ErrNotRetrieved, "Should ask for the firmware to be retrieved")
+	})
+}
+
+func TestHPEFirmwareEntry_CatalogRelease(t *testing.T) {
+	entry := &HPEFirmwareEntry{
+		Filename: "test.fwpkg",
+		Entry: &HPECatalogEntry{
+			Version: "16.29.1016",
+			Target:  []string{"A6B1A447-382A-5A4F-15B3-101715900346", "null"},
+		},
+	}
+
+	assert.Equal(t, "16.29.1016", entry.GetVersion(), "Version should come from the catalog")
+	assert.Equal(t, []string{"a6b1a447-382a-5a4f-15b3-101715900346"}, entry.GetGUIDs(), "Only valid targets should be returned")
+}
+
+// countingResponseWriter counts the body bytes sent to the client
+type countingResponseWriter struct {
+	http.ResponseWriter
+	count *atomic.Int64
+}
+
+func (w *countingResponseWriter) Write(p []byte) (int, error) {
+	w.count.Add(int64(len(p)))
+	return w.ResponseWriter.Write(p)
+}
+
+// hidingResponseWriter removes a header from the response
+type hidingResponseWriter struct {
+	http.ResponseWriter
+	header string
+}
+
+func (w *hidingResponseWriter) WriteHeader(statusCode int) {
+	w.Header().Del(w.header)
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func TestHPEFirmwareEntry_ToAppstream_Products(t *testing.T) {
+	tmpDir := t.TempDir()
+	mockFirmwarePath := createMockHPEFirmware(t, tmpDir, "payload_nic.json")
+
+	tests := []struct {
+		name     string
+		products []string
+		skipped  bool
+	}{
+		{name: "NoFilter"},
+		{name: "MatchingProduct", products: []string{"DL360 Gen10 Plus"}},
+		{name: "MatchingAnyProduct", products: []string{"DL20 Gen10", "dl380 gen11"}},
+		{name: "ModelRevisionMismatch", products: []string{"DL360 Gen10"}, skipped: true},
+		{name: "OtherProduct", products: []string{"DL560 Gen11"}, skipped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &HPEFirmwareEntry{
+				Filename:     "test-firmware.fwpkg",
+				Entry:        &HPECatalogEntry{},
+				Products:     tt.products,
+				downloadPath: mockFirmwarePath,
+			}
+
+			_, err := entry.ToAppstream()
+			if tt.skipped {
+				assert.ErrorIs(t, err, firmirror.ErrSkipped, "Firmware should be excluded by the products filter")
+			} else {
+				assert.NoError(t, err, "Firmware should match the products filter")
+			}
+		})
+	}
+}
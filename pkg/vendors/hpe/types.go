@@ -2,21 +2,43 @@ package hpe
 
 // HPEVendor implements the Vendor interface for HPE
 type HPEVendor struct {
+	// BaseURL is the URL of the repository, e.g. "https://downloads.linux.hpe.com/SDR/repo/fwpp-gen11"
 	BaseURL string
+	// Release is the release directory of the repository. If empty, uses the "current" release.
+	Release string
+	// Products filters which server models to include, matched against the package supported
+	// products. If nil or empty, includes all products. Example: ["DL360 Gen10 Plus", "DL380 Gen11"]
+	Products []string
+	// DeviceClasses filters which device classes to include. If nil or empty, includes all classes.
+	DeviceClasses []string
+	// Urgencies overrides the urgency of releases, keyed on sw_key name, category key or
+	// category name. Example: {"ilo6": "critical", "Firmware - Network": "low"}
+	Urgencies map[string]string
+	// VersionFormats overrides the detected version format of components, keyed like Urgencies.
+	// Example: {"Firmware - System ROM": "plain"}
+	VersionFormats map[string]string
 }
 
 // HPEVendor implements the Catalog interface for HPE
 type HPECatalog struct {
-	Entries map[string]HPECatalogEntry `json:",inline"`
-	BaseURL string
+	Entries        map[string]HPECatalogEntry `json:",inline"`
+	BaseURL        string
+	Release        string
+	Products       []string
+	Urgencies      map[string]string
+	VersionFormats map[string]string
 }
 
 // HPEFirmwareEntry implements the FirmwareEntry interface for HPE
 type HPEFirmwareEntry struct {
-	Filename     string
-	Entry        *HPECatalogEntry
-	SourceURL    string
-	downloadPath string // Store download path for processing
+	Filename       string
+	Entry          *HPECatalogEntry
+	SourceURL      string
+	Products       []string // Supported products filter, applied once payload.json is read
+	Urgencies      map[string]string
+	VersionFormats map[string]string
+	downloadPath   string      // Store download path for processing
+	payload        *HPEPayload // Cached payload.json, read once
 }
 
 type HPECatalogEntry struct {
@@ -66,6 +88,7 @@ type HPEFirmwareImage struct {
 
 type HPEPackage struct {
 	Category               []HPECategory             `json:"category"`
+	Criticality            string                    `json:"criticality"`
 	Description            []HPETranslations         `json:"description"`
 	Divisions              []HPEDivision             `json:"divisions"`
 	Files                  map[string]any            `json:"files"`
@@ -75,6 +98,7 @@ type HPEPackage struct {
 	ManufacturerName       []HPETranslations         `json:"manufacturer_name"`
 	Name                   []HPETranslations         `json:"name"`
 	Prerequisites          HPEPrerequisites          `json:"prerequisites"`
+	Recommendation         string                    `json:"recommendation"`
 	ReleaseDate            string                    `json:"release_date"`
 	SchemaVersion          string                    `json:"schema_version"`
 	SupportedProducts      []HPESupportedProduct     `json:"supported_products"`